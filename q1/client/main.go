@@ -2,81 +2,310 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"q1/clientlb"
+	"q1/lbpolicy"
 	lbproto "q1/protofiles"
+	"q1/registry"
+	"q1/taskclient"
 	"strconv"
+	"strings"
+	"time"
 
 	"google.golang.org/grpc"
-	// "google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 const (
-	lbServerAddr = "localhost:50319"
+	loadWaitTimeout = 2 * time.Second
+	outcomeReportTimeout = time.Second
 )
 
-func sendRequestToLoadBalancer(client lbproto.LoadBalancingServiceClient, tasktype int) (string, error){
-	req := &lbproto.LoadBalancerRequest{TaskType: int32(tasktype)}
+func sendRequestToLoadBalancer(client lbproto.LoadBalancingServiceClient, tasktype lbproto.TaskType, num int64, zone, region string) (*lbproto.LoadBalancerResponse, error){
+	req := &lbproto.LoadBalancerRequest{TaskType: tasktype, HashKey: lbpolicy.RequestHashKey(int32(tasktype), num), Zone: zone, Region: region}
 
 	resp, err := client.LoadBalancerRPC(context.Background(), req)
 	if err != nil {
 		log.Fatalf("Error while calling LoadBalancerRPC: %v", err)
-		return "", err
+		return nil, err
 	}
 
 	fmt.Println("Response From Load Balancing Server: ", resp.GetBestServer())
-	return resp.GetBestServer(), nil
+	return resp, nil
 }
 
-func sendRequestToBackendServer(client lbproto.BackendServiceClient, taskType int, num int64){
-	req := &lbproto.BackendRequest{TaskType: int32(taskType), Num: num}
+// reportOutcomes returns an interceptor that tells the load balancer how
+// calls to the backend it picked went, for its outlier detection. The load
+// balancer only takes the first outcome reported for a pick.
+func reportOutcomes(lbClient lbproto.LoadBalancingServiceClient, pick *lbproto.LoadBalancerResponse) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		outcome := &lbproto.CallOutcome{
+			ServerAddr: pick.GetBestServer(),
+			Code:       int32(status.Code(err)),
+			Latency:    float32(time.Since(start).Microseconds()) / 1000,
+			PickToken:  pick.GetPickToken(),
+		}
+		if backendReq, ok := req.(*lbproto.BackendRequest); ok {
+			outcome.TaskType = backendReq.GetTaskType()
+		}
+		reportCtx, cancel := context.WithTimeout(context.Background(), outcomeReportTimeout)
+		defer cancel()
+		if _, reportErr := lbClient.ReportOutcome(reportCtx, &lbproto.OutcomeReport{Outcomes: []*lbproto.CallOutcome{outcome}}); reportErr != nil {
+			log.Printf("Client - Could not report the call outcome to the load balancer: %v", reportErr)
+		}
+		return err
+	}
+}
 
-	resp, err := client.BackendRPC(context.Background(), req)
+// sendWithRetries asks the load balancer for a backend and sends the task
+// there, retrying failed calls on other backends and hedging slow ones as
+// config says.
+func sendWithRetries(lbClient lbproto.LoadBalancingServiceClient, config taskclient.Config, taskType lbproto.TaskType, num int64) {
+	client := taskclient.New(lbClient, config)
+	defer client.Close()
+
+	result, err := client.Send(context.Background(), newBackendRequest(taskType, num))
 	if err != nil {
-		log.Fatalf("Error while calling RPC")
+		log.Fatalf("Error while calling RPC: %v", err)
+	}
+	fmt.Println("Response From Load Balancing Server: ", result.Addr)
+	fmt.Println("Response From Backend Server: ", result.Response.GetOutput())
+	if result.Calls > 1 {
+		fmt.Printf("Answered after %d calls\n", result.Calls)
+	}
+}
+
+// newBackendRequest builds the request for a task. An argument of 0, the -n
+// default, asks the backend for the task's default argument.
+func newBackendRequest(taskType lbproto.TaskType, num int64) *lbproto.BackendRequest {
+	return &lbproto.BackendRequest{TaskType: taskType, Num: num, UseDefault: num == 0}
+}
+
+// sendFunc sends a task to a backend, or to the load balancer's proxy.
+type sendFunc func(ctx context.Context, client lbproto.BackendServiceClient, taskType lbproto.TaskType, num int64)
+
+func sendRequestToBackendServer(ctx context.Context, client lbproto.BackendServiceClient, taskType lbproto.TaskType, num int64){
+	req := newBackendRequest(taskType, num)
+
+	resp, err := client.BackendRPC(ctx, req)
+	if err != nil {
+		log.Fatalf("Error while calling RPC: %v", err)
 	}
 
 	fmt.Println("Response From Backend Server: ", resp.GetOutput())
 }
 
+// submitAndWatch submits the task as an asynchronous job and follows it
+// until it finishes.
+func submitAndWatch(ctx context.Context, client lbproto.BackendServiceClient, taskType lbproto.TaskType, num int64) {
+	req := newBackendRequest(taskType, num)
+
+	taskStatus, err := client.SubmitTask(ctx, req)
+	if err != nil {
+		log.Fatalf("Error while submitting task: %v", err)
+	}
+	fmt.Println("Submitted job:", taskStatus.GetJobId())
+	watchJob(ctx, client, taskStatus.GetJobId())
+}
+
+// watchJob prints the progress of a job until it finishes. Interrupting the
+// client leaves the job running; watch it again with --job.
+func watchJob(ctx context.Context, client lbproto.BackendServiceClient, jobID string) {
+	stream, err := client.WatchTask(ctx, &lbproto.TaskID{JobId: jobID})
+	if err != nil {
+		log.Fatalf("Error while watching job %s: %v", jobID, err)
+	}
+	for {
+		taskStatus, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Error while watching job %s: %v", jobID, err)
+		}
+		printJobStatus(taskStatus)
+	}
+}
+
+func printJobStatus(taskStatus *lbproto.TaskStatus) {
+	switch taskStatus.GetState() {
+	case lbproto.TaskState_SUCCEEDED:
+		fmt.Println("Response From Backend Server: ", taskStatus.GetOutput())
+	case lbproto.TaskState_FAILED, lbproto.TaskState_CANCELLED:
+		fmt.Printf("Job %s %v: %s\n", taskStatus.GetJobId(), taskStatus.GetState(), taskStatus.GetError())
+	default:
+		fmt.Printf("Job %s %v, %.0f%% done\n", taskStatus.GetJobId(), taskStatus.GetState(), 100*taskStatus.GetProgress())
+	}
+}
+
+// jobBackendAddr returns the backend named in a job ID.
+func jobBackendAddr(jobID string) string {
+	slash := strings.LastIndexByte(jobID, '/')
+	if slash < 0 {
+		log.Fatalf("Client - Malformed job ID %q", jobID)
+	}
+	return jobID[:slash]
+}
+
+// sendWithClientSideBalancing resolves the backends from the registry and
+// lets the gRPC balancer for policy pick one, using the load reports the
+// load balancer streams instead of a LoadBalancerRPC per request.
+func sendWithClientSideBalancing(lbClient lbproto.LoadBalancingServiceClient, registryConfig registry.Config, policy string, send sendFunc, tasktype lbproto.TaskType, num int64) {
+	if _, err := lbpolicy.New(policy); err != nil {
+		log.Fatalf("Client - %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go clientlb.SubscribeLoad(ctx, lbClient)
+
+	target := clientlb.Scheme + "://" + strings.TrimSuffix(registryConfig.KeyPrefix, "/")
+	conn, err := grpc.NewClient(target,
+		grpc.WithResolvers(clientlb.NewResolverBuilder(registryConfig)),
+		grpc.WithDefaultServiceConfig(clientlb.ServiceConfig(policy)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Client - Could not create client for %s: %v", target, err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-clientlb.LoadReady():
+	case <-time.After(loadWaitTimeout):
+		log.Printf("Client - No load report from the load balancer yet, picking without it")
+	}
+
+	pickCtx := clientlb.WithPickRequest(ctx, lbpolicy.PickRequest{TaskType: int32(tasktype), HashKey: lbpolicy.RequestHashKey(int32(tasktype), num)})
+	send(pickCtx, lbproto.NewBackendServiceClient(conn), tasktype, num)
+}
+
+// parseTaskType accepts a task type by name, in any case (fibonacci,
+// PROOF_OF_WORK), or by number. Numbers the client does not know are passed
+// through for the backend to reject.
+func parseTaskType(arg string) (lbproto.TaskType, error) {
+	if value, exists := lbproto.TaskType_value[strings.ToUpper(arg)]; exists {
+		return lbproto.TaskType(value), nil
+	}
+	value, err := strconv.ParseInt(arg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown task type %q", arg)
+	}
+	return lbproto.TaskType(value), nil
+}
+
 func main(){
-	args := os.Args[1:]
+	proxyMode := flag.Bool("proxy", false, "send the request through the load balancer's proxy instead of asking it for a backend")
+	clientPolicy := flag.String("client-lb", "", "pick the backend in the client with this policy (PF, RR, LL, ...) using the registry and the load balancer's load reports")
+	lbAddr := flag.String("lb-addr", registry.DefaultLoadBalancerAddr, "load balancer address, used when none is elected in the registry")
+	num := flag.Int64("n", 0, "task argument, 0 for the task's default")
+	async := flag.Bool("async", false, "submit the task as a job and watch its progress instead of waiting on one call")
+	watchJobID := flag.String("job", "", "watch the job with this ID instead of sending a task")
+	cancelJobID := flag.String("cancel", "", "cancel the job with this ID instead of sending a task")
+	cacheStatsAddr := flag.String("cache-stats", "", "print the result cache counters of the backend at this address instead of sending a task")
+	retryConfig := taskclient.DefaultConfig()
+	flag.IntVar(&retryConfig.MaxAttempts, "attempts", retryConfig.MaxAttempts, "times to try the task, on a different backend each time while there is one, 1 to disable retries")
+	flag.DurationVar(&retryConfig.InitialBackoff, "retry-backoff", retryConfig.InitialBackoff, "wait before the first retry, doubling with each one")
+	flag.DurationVar(&retryConfig.HedgeDelay, "hedge-after", 0, "send the task to a second backend if the first has not answered after this long, 0 to disable")
+	flag.StringVar(&retryConfig.Zone, "zone", "", "zone the client runs in; the load balancer prefers backends there")
+	flag.StringVar(&retryConfig.Region, "region", "", "region the client runs in")
+	var registryConfig registry.Config
+	registryConfig.AddFlags(flag.CommandLine)
+	flag.Parse()
+	args := flag.Args()
+
+	if *cacheStatsAddr != "" {
+		conn, err := grpc.Dial(*cacheStatsAddr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("Client - Could not connect to %s: %v", *cacheStatsAddr, err)
+		}
+		defer conn.Close()
+		stats, err := lbproto.NewBackendServiceClient(conn).GetCacheStats(context.Background(), &lbproto.Empty{})
+		if err != nil {
+			log.Fatalf("Error while getting cache stats: %v", err)
+		}
+		fmt.Printf("hits %d, misses %d, shared %d, evictions %d, entries %d/%d\n",
+			stats.GetHits(), stats.GetMisses(), stats.GetShared(), stats.GetEvictions(), stats.GetEntries(), stats.GetCapacity())
+		return
+	}
+
+	if jobID := *watchJobID + *cancelJobID; jobID != "" {
+		// Jobs live on the backend named in their ID, reached through the
+		// load balancer in proxy mode
+		addr := jobBackendAddr(jobID)
+		if *proxyMode {
+			addr = registry.FindLoadBalancer(registryConfig, *lbAddr)
+		}
+		conn, err := grpc.Dial(addr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("Client - Could not connect to %s: %v", addr, err)
+		}
+		defer conn.Close()
+		client := lbproto.NewBackendServiceClient(conn)
+		if *cancelJobID != "" {
+			taskStatus, err := client.CancelTask(context.Background(), &lbproto.TaskID{JobId: jobID})
+			if err != nil {
+				log.Fatalf("Error while cancelling job %s: %v", jobID, err)
+			}
+			printJobStatus(taskStatus)
+			return
+		}
+		watchJob(context.Background(), client, jobID)
+		return
+	}
+
 	if len(args) != 1{
 		log.Fatalf("Invalid command line arguments, expected 1")
 	}
-	tasktype, err1 := strconv.Atoi(args[0])
+	tasktype, err1 := parseTaskType(args[0])
 	if err1 != nil {
-		log.Fatalf("Invalid command line arguments")
-	}
-	if tasktype > 3 || tasktype < 0 {
-		log.Fatalf("Invalid tasktype")
+		log.Fatalf("Invalid tasktype: %v", err1)
 	}
 
-	conn, err := grpc.Dial(lbServerAddr, grpc.WithInsecure())
+	conn, err := grpc.Dial(registry.FindLoadBalancer(registryConfig, *lbAddr), grpc.WithInsecure())
 	if err != nil {
 		log.Fatalf("Client - Could not connet to Load Balancing server")
 	}
 	defer conn.Close()
 
+	send := sendFunc(sendRequestToBackendServer)
+	if *async {
+		send = submitAndWatch
+	}
+
+	if *proxyMode {
+		send(context.Background(), lbproto.NewBackendServiceClient(conn), tasktype, *num)
+		return
+	}
+
 	lbClient := lbproto.NewLoadBalancingServiceClient(conn)
 
-	backendAddr, err := sendRequestToLoadBalancer(lbClient, tasktype)
+	if *clientPolicy != "" {
+		sendWithClientSideBalancing(lbClient, registryConfig, *clientPolicy, send, tasktype, *num)
+		return
+	}
+
+	if !*async {
+		retryConfig.Logf = func(format string, args ...any) { log.Printf("Client - "+format, args...) }
+		sendWithRetries(lbClient, retryConfig, tasktype, *num)
+		return
+	}
+
+	pick, err := sendRequestToLoadBalancer(lbClient, tasktype, *num, retryConfig.Zone, retryConfig.Region)
 	if err != nil{
 		log.Fatalf("Client - Error while requesting for backend server: %v", err)
 	}
-	conn2, err2 := grpc.Dial(backendAddr, grpc.WithInsecure())
+	backendAddr := pick.GetBestServer()
+	conn2, err2 := grpc.Dial(backendAddr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(reportOutcomes(lbClient, pick)))
 	if err2 != nil {
 		log.Fatalf("Client - Could not connet to Backend server with Addr: %s, error: %v", backendAddr, err)
 	}
 	
 	backendClient := lbproto.NewBackendServiceClient(conn2)
 
-	if tasktype == 0{
-		sendRequestToBackendServer(backendClient, tasktype, 1e9)
-	} else if tasktype == 1{
-		sendRequestToBackendServer(backendClient, tasktype, 1e6)
-	}else{
-		sendRequestToBackendServer(backendClient, tasktype, 45)
-	}
+	send(context.Background(), backendClient, tasktype, *num)
 }
\ No newline at end of file
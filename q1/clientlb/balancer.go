@@ -0,0 +1,165 @@
+package clientlb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"q1/lbpolicy"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/health" // client-side health checking
+	"google.golang.org/grpc/status"
+)
+
+// BalancerName returns the name the balancer for an lbpolicy policy is
+// registered under, e.g. q1_rr for RR.
+func BalancerName(policy string) string {
+	return "q1_" + strings.ToLower(policy)
+}
+
+// ServiceConfig returns a gRPC service config selecting the balancer for
+// policy, for grpc.WithDefaultServiceConfig. It also turns on health checks
+// of the backends, so unhealthy ones are left out of the picker.
+func ServiceConfig(policy string) string {
+	return fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}], "healthCheckConfig": {"serviceName": ""}}`, BalancerName(policy))
+}
+
+func init() {
+	for _, name := range lbpolicy.Names {
+		balancer.Register(&balancerBuilder{name: name})
+	}
+}
+
+// balancerBuilder builds the balancer for one policy. Each ClientConn gets
+// its own policy and backend state, so two connections do not share a round
+// robin position or in-flight counts.
+type balancerBuilder struct {
+	name string // lbpolicy policy name
+}
+
+func (b *balancerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	policy, _ := lbpolicy.New(b.name)
+	pb := &pickerBuilder{
+		policy:   policy,
+		backends: make(map[string]*lbpolicy.Backend),
+		inFlight: make(map[string]int32),
+	}
+	return base.NewBalancerBuilder(b.Name(), pb, base.Config{HealthCheck: true}).Build(cc, opts)
+}
+
+func (b *balancerBuilder) Name() string {
+	return BalancerName(b.name)
+}
+
+type pickRequestKey struct{}
+
+// WithPickRequest attaches the task type and hash key of a call to ctx so the
+// balancer can pass them to the policy.
+func WithPickRequest(ctx context.Context, req lbpolicy.PickRequest) context.Context {
+	return context.WithValue(ctx, pickRequestKey{}, req)
+}
+
+// pickerBuilder builds a picker over the ready connections of a ClientConn
+// each time they change. The policy and per-backend state outlive the
+// pickers, so round robin position and latency averages survive reconnects.
+type pickerBuilder struct {
+	mutexLock sync.Mutex
+	policy    lbpolicy.Policy
+	backends  map[string]*lbpolicy.Backend
+	inFlight  map[string]int32 // calls this client has in flight per backend
+}
+
+func (pb *pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	pb.mutexLock.Lock()
+	defer pb.mutexLock.Unlock()
+
+	p := &picker{pb: pb, subConns: make(map[string]balancer.SubConn)}
+	order := make(map[string]int, len(info.ReadySCs))
+	for subConn, subConnInfo := range info.ReadySCs {
+		addr := subConnInfo.Address.Addr
+		backend, exists := pb.backends[addr]
+		if !exists {
+			backend = &lbpolicy.Backend{Addr: addr}
+			pb.backends[addr] = backend
+		}
+		backend.Weight = 1
+		if weight, ok := subConnInfo.Address.BalancerAttributes.Value(weightAttrKey{}).(int32); ok {
+			backend.Weight = weight
+		}
+		order[addr], _ = subConnInfo.Address.BalancerAttributes.Value(orderAttrKey{}).(int)
+		p.backends = append(p.backends, backend)
+		p.subConns[addr] = subConn
+	}
+	// ReadySCs is a map; put the backends back in the resolver's order,
+	// which is registration order, as policies expect
+	sort.Slice(p.backends, func(i, j int) bool {
+		return order[p.backends[i].Addr] < order[p.backends[j].Addr]
+	})
+	if watcher, ok := pb.policy.(lbpolicy.MembershipWatcher); ok {
+		watcher.SetMembers(p.backends)
+	}
+	return p
+}
+
+type picker struct {
+	pb       *pickerBuilder
+	backends []*lbpolicy.Backend
+	subConns map[string]balancer.SubConn
+}
+
+func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	req, _ := info.Ctx.Value(pickRequestKey{}).(lbpolicy.PickRequest)
+
+	candidates := make([]*lbpolicy.Backend, 0, len(p.backends))
+	draining := 0
+	for _, backend := range p.backends {
+		if !registered.supports(backend.Addr, req.TaskType) {
+			continue
+		}
+		if loads.draining(backend.Addr) {
+			draining++
+			continue
+		}
+		candidates = append(candidates, backend)
+	}
+	if len(candidates) == 0 {
+		if draining > 0 {
+			return balancer.PickResult{}, status.Errorf(codes.Unavailable, "every backend server for task type %d is draining", req.TaskType)
+		}
+		if registered.anySupports(req.TaskType) {
+			// wait for a capable backend to become ready
+			return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+		}
+		return balancer.PickResult{}, status.Errorf(codes.Unavailable, "no backend server supports task type %d", req.TaskType)
+	}
+
+	pb := p.pb
+	pb.mutexLock.Lock()
+	for _, backend := range candidates {
+		loads.apply(backend, pb.inFlight[backend.Addr])
+	}
+	backend := pb.policy.Pick(req, candidates)
+	pb.inFlight[backend.Addr]++
+	pb.mutexLock.Unlock()
+
+	start := time.Now()
+	done := func(doneInfo balancer.DoneInfo) {
+		pb.mutexLock.Lock()
+		defer pb.mutexLock.Unlock()
+		pb.inFlight[backend.Addr]--
+		if doneInfo.Err == nil {
+			backend.Latency.Observe(float64(time.Since(start).Microseconds())/1000, time.Now())
+		}
+	}
+	return balancer.PickResult{SubConn: p.subConns[backend.Addr], Done: done}, nil
+}
@@ -0,0 +1,154 @@
+package clientlb
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"q1/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+const balancerTestTimeout = 5 * time.Second
+
+// sharedRegistry lets every resolver of a test use the same registry, which
+// the test closes itself.
+type sharedRegistry struct {
+	registry.Registry
+}
+
+func (sharedRegistry) Close() error { return nil }
+
+// startBackends starts n gRPC servers answering health checks and returns
+// their addresses.
+func startBackends(t *testing.T, n int) []string {
+	t.Helper()
+	addrs := make([]string, n)
+	for i := range addrs {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := grpc.NewServer()
+		healthpb.RegisterHealthServer(server, health.NewServer())
+		go server.Serve(listener)
+		t.Cleanup(server.Stop)
+		addrs[i] = listener.Addr().String()
+	}
+	return addrs
+}
+
+// dialRegistry connects to the backends registered in reg, balanced by
+// policy.
+func dialRegistry(t *testing.T, reg registry.Registry, policy string) healthpb.HealthClient {
+	t.Helper()
+	builder := NewResolverBuilder(registry.Config{Kind: "memory"})
+	builder.open = func(registry.Config) (registry.Registry, error) { return sharedRegistry{reg}, nil }
+	conn, err := grpc.NewClient(Scheme+":///services/backend",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(builder),
+		grpc.WithDefaultServiceConfig(ServiceConfig(policy)))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// pickedAddr sends one call through client and returns the backend it went
+// to.
+func pickedAddr(t *testing.T, client healthpb.HealthClient) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), balancerTestTimeout)
+	defer cancel()
+	var p peer.Peer
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p), grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	return p.Addr.String()
+}
+
+// waitForPicks calls through client until it has reached exactly the
+// backends in want.
+func waitForPicks(t *testing.T, client healthpb.HealthClient, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(balancerTestTimeout)
+	for {
+		seen := make(map[string]bool)
+		for i := 0; i < 3*len(want); i++ {
+			seen[pickedAddr(t, client)] = true
+		}
+		matches := len(seen) == len(want)
+		for _, addr := range want {
+			matches = matches && seen[addr]
+		}
+		if matches {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("picked %v, want %v", seen, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestResolverToPicker(t *testing.T) {
+	ctx := context.Background()
+	reg := registry.NewMemoryRegistry()
+	// close after the connections, so their resolvers never see it empty
+	t.Cleanup(func() { reg.Close() })
+	addrs := startBackends(t, 3)
+	slices.Sort(addrs)
+	register := func(addr string) registry.LeaseID {
+		lease, err := reg.Register(ctx, registry.Instance{Addr: addr}, time.Minute)
+		if err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		return lease
+	}
+
+	// the backends registered after the connections start come from the
+	// watch, in the reverse of address order, which the picker must keep
+	order := []string{addrs[2], addrs[1], addrs[0]}
+	lease := register(order[0])
+	first := dialRegistry(t, reg, "PF")
+	a, b := dialRegistry(t, reg, "RR"), dialRegistry(t, reg, "RR")
+	for _, client := range []healthpb.HealthClient{first, a, b} {
+		waitForPicks(t, client, order[0])
+	}
+	register(order[1])
+	register(order[2])
+
+	// two connections with RR each go round in registration order, without
+	// sharing a position
+	waitForPicks(t, a, order...)
+	waitForPicks(t, b, order...)
+	next := make(map[string]string)
+	for i, addr := range order {
+		next[addr] = order[(i+1)%len(order)]
+	}
+	lastA, lastB := pickedAddr(t, a), pickedAddr(t, b)
+	for i := 0; i < 2*len(order); i++ {
+		gotA, gotB := pickedAddr(t, a), pickedAddr(t, b)
+		if gotA != next[lastA] || gotB != next[lastB] {
+			t.Fatalf("RR picked %s after %s and %s after %s, want registration order %v", gotA, lastA, gotB, lastB, order)
+		}
+		lastA, lastB = gotA, gotB
+	}
+	// PF sends everything to the first backend registered
+	waitForPicks(t, first, order[0])
+
+	// a backend that leaves the registry is no longer picked
+	if err := reg.Deregister(ctx, lease); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	waitForPicks(t, a, order[1:]...)
+	waitForPicks(t, first, order[1])
+}
@@ -0,0 +1,99 @@
+package clientlb
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+)
+
+const (
+	subscribeMinBackoff = 100 * time.Millisecond
+	subscribeMaxBackoff = 5 * time.Second
+)
+
+// loads holds the latest load report from the load balancer, shared by all
+// balancers in the process.
+var loads = &loadTable{status: make(map[string]*lbproto.LoadStatus), ready: make(chan struct{})}
+
+type loadTable struct {
+	mutexLock sync.Mutex
+	status    map[string]*lbproto.LoadStatus
+	ready     chan struct{} // closed on the first report
+	received  bool
+}
+
+// LoadReady is closed once the first load report has arrived.
+func LoadReady() <-chan struct{} {
+	return loads.ready
+}
+
+func (t *loadTable) update(report *lbproto.LoadReport) {
+	status := make(map[string]*lbproto.LoadStatus, len(report.GetBackends()))
+	for _, loadStatus := range report.GetBackends() {
+		status[loadStatus.GetServerAddr()] = loadStatus
+	}
+	t.mutexLock.Lock()
+	t.status = status
+	if !t.received {
+		t.received = true
+		close(t.ready)
+	}
+	t.mutexLock.Unlock()
+}
+
+// apply copies the reported load of backend into it. localInFlight is the
+// number of calls this client has in flight to it.
+func (t *loadTable) apply(backend *lbpolicy.Backend, localInFlight int32) {
+	t.mutexLock.Lock()
+	loadStatus := t.status[backend.Addr]
+	t.mutexLock.Unlock()
+
+	backend.Load = loadStatus.GetLoad()
+	backend.InFlight = loadStatus.GetInFlight() + localInFlight
+	backend.QueueDepth = loadStatus.GetQueueDepth()
+	if backend.Latency.Value() == 0 && loadStatus.GetLatency() > 0 {
+		// seed the average until this client has its own samples
+		backend.Latency.Observe(float64(loadStatus.GetLatency()), time.Now())
+	}
+}
+
+// draining reports whether the load balancer says the backend is drained
+// or shutting down.
+func (t *loadTable) draining(addr string) bool {
+	t.mutexLock.Lock()
+	defer t.mutexLock.Unlock()
+	loadStatus := t.status[addr]
+	return loadStatus.GetDraining() || loadStatus.GetDrained()
+}
+
+// SubscribeLoad streams load reports from the load balancer into the table
+// the balancers read until ctx is done, reconnecting with backoff when the
+// stream fails.
+func SubscribeLoad(ctx context.Context, client lbproto.LoadBalancingServiceClient) {
+	backoff := subscribeMinBackoff
+	for ctx.Err() == nil {
+		stream, err := client.SubscribeLoad(ctx, &lbproto.SubscribeLoadRequest{})
+		for err == nil {
+			var report *lbproto.LoadReport
+			report, err = stream.Recv()
+			if err == nil {
+				loads.update(report)
+				backoff = subscribeMinBackoff
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Load subscription failed, retrying in %v: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, subscribeMaxBackoff)
+	}
+}
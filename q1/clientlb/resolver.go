@@ -0,0 +1,196 @@
+// Package clientlb lets a q1 client balance requests itself with grpc.Dial
+// instead of asking the load balancer for a backend per request. It provides
+// a resolver for etcd:///<key prefix> targets backed by the service registry,
+// balancers that run the lbpolicy policies, and a subscription to the load
+// balancer's load reports that feeds those policies.
+package clientlb
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"q1/registry"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme is the resolver scheme, e.g. etcd:///services/backend.
+const Scheme = "etcd"
+
+// weightAttrKey and orderAttrKey are the balancer attributes carrying an
+// address's weight and a number that sorts it in registration order.
+type (
+	weightAttrKey struct{}
+	orderAttrKey  struct{}
+)
+
+// registered holds the latest instances from the resolvers, shared by all
+// balancers in the process. Pickers only see ready connections; this tells
+// them whether a task type nobody ready serves is served at all.
+var registered = &instanceTable{instances: make(map[string]registry.Instance)}
+
+type instanceTable struct {
+	mutexLock sync.Mutex
+	instances map[string]registry.Instance
+}
+
+func (t *instanceTable) update(instances []registry.Instance) {
+	byAddr := make(map[string]registry.Instance, len(instances))
+	for _, inst := range instances {
+		byAddr[inst.Addr] = inst
+	}
+	t.mutexLock.Lock()
+	t.instances = byAddr
+	t.mutexLock.Unlock()
+}
+
+// supports reports whether the instance at addr serves taskType.
+func (t *instanceTable) supports(addr string, taskType int32) bool {
+	t.mutexLock.Lock()
+	defer t.mutexLock.Unlock()
+	return t.instances[addr].Supports(taskType)
+}
+
+// anySupports reports whether any registered instance serves taskType.
+func (t *instanceTable) anySupports(taskType int32) bool {
+	t.mutexLock.Lock()
+	defer t.mutexLock.Unlock()
+	for _, inst := range t.instances {
+		if inst.Supports(taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolverBuilder resolves etcd:///<key prefix> to the backends registered
+// under that prefix and keeps the address list current with a registry
+// watch.
+type ResolverBuilder struct {
+	config registry.Config
+	open   func(registry.Config) (registry.Registry, error) // replaced in tests
+}
+
+// NewResolverBuilder returns a builder that opens a registry with config. For
+// the etcd registry the target path replaces config.KeyPrefix.
+func NewResolverBuilder(config registry.Config) *ResolverBuilder {
+	return &ResolverBuilder{config: config, open: registry.Open}
+}
+
+func (b *ResolverBuilder) Scheme() string {
+	return Scheme
+}
+
+func (b *ResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	config := b.config
+	if endpoint := strings.Trim(target.Endpoint(), "/"); endpoint != "" {
+		config.KeyPrefix = "/" + endpoint + "/"
+	}
+	reg, err := b.open(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &registryResolver{reg: reg, cc: cc, cancel: cancel, done: make(chan struct{}), order: make(map[string]int)}
+	go r.run(ctx)
+	return r, nil
+}
+
+type registryResolver struct {
+	reg    registry.Registry
+	cc     resolver.ClientConn
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// order numbers the addresses in the order they were first resolved.
+	// The balancer keeps the attributes an address first came with, so the
+	// numbers of the remaining addresses never change.
+	order     map[string]int
+	nextOrder int
+}
+
+// run mirrors discoverBackends in the load balancer: list, then watch from
+// the listed revision, and list again if the watch fails.
+func (r *registryResolver) run(ctx context.Context) {
+	defer close(r.done)
+	for ctx.Err() == nil {
+		instances, rev, err := r.reg.List(ctx)
+		if err != nil {
+			r.cc.ReportError(err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		current := make(map[string]registry.Instance)
+		for _, inst := range instances {
+			current[inst.Addr] = inst
+		}
+		r.update(instances)
+
+		for watchResp := range r.reg.Watch(ctx, rev+1) {
+			if watchResp.Err != nil {
+				log.Printf("Resolver - backend watch ended, resyncing: %v", watchResp.Err)
+				break
+			}
+			for _, event := range watchResp.Events {
+				if event.Type == registry.EventPut {
+					if _, exists := current[event.Instance.Addr]; !exists {
+						instances = append(instances, event.Instance)
+					}
+					current[event.Instance.Addr] = event.Instance
+				} else {
+					delete(current, event.Instance.Addr)
+				}
+			}
+			// keep registration order, as the load balancer does
+			updated := instances[:0]
+			for _, inst := range instances {
+				if latest, exists := current[inst.Addr]; exists {
+					updated = append(updated, latest)
+				}
+			}
+			instances = updated
+			r.update(instances)
+		}
+	}
+}
+
+func (r *registryResolver) update(instances []registry.Instance) {
+	addrs := make([]resolver.Address, 0, len(instances))
+	present := make(map[string]bool, len(instances))
+	for _, inst := range instances {
+		present[inst.Addr] = true
+		if _, exists := r.order[inst.Addr]; !exists {
+			r.order[inst.Addr] = r.nextOrder
+			r.nextOrder++
+		}
+		addrs = append(addrs, resolver.Address{
+			Addr:               inst.Addr,
+			BalancerAttributes: attributes.New(weightAttrKey{}, inst.EffectiveWeight()).WithValue(orderAttrKey{}, r.order[inst.Addr]),
+		})
+	}
+	for addr := range r.order {
+		if !present[addr] {
+			delete(r.order, addr)
+		}
+	}
+	registered.update(instances)
+	if err := r.cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		log.Printf("Resolver - failed to update addresses: %v", err)
+	}
+}
+
+func (r *registryResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *registryResolver) Close() {
+	r.cancel()
+	<-r.done
+	r.reg.Close()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the histogram's precision: every power of two is split
+// into 1<<subBucketBits buckets, so a recorded latency is off by at most
+// 1/32 of its value.
+const subBucketBits = 5
+
+// histogram counts latencies in log-linear microsecond buckets, keeping the
+// percentiles of any number of requests in a few kilobytes. It is not safe
+// for concurrent use; each worker keeps its own and they are merged at the
+// end of a run.
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// bucketOf returns the bucket of v microseconds. Values below 2<<subBucketBits
+// have a bucket each; above that every bucket spans 1<<shift values.
+func bucketOf(v uint64) int {
+	const exact = 2 << subBucketBits
+	if v < exact {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	top := int(v >> shift) // in [1<<subBucketBits, 2<<subBucketBits)
+	return exact + (shift-1)<<subBucketBits + top - 1<<subBucketBits
+}
+
+// bucketLimit returns the largest value, in microseconds, in bucket i.
+func bucketLimit(i int) uint64 {
+	const exact = 2 << subBucketBits
+	if i < exact {
+		return uint64(i)
+	}
+	shift := (i-exact)>>subBucketBits + 1
+	top := uint64((i-exact)&(1<<subBucketBits-1) + 1<<subBucketBits)
+	return (top+1)<<shift - 1
+}
+
+func (h *histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := bucketOf(uint64(d.Microseconds()))
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+func (h *histogram) Merge(other *histogram) {
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]int64, len(other.counts)-len(h.counts))...)
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+	h.max = max(h.max, other.max)
+}
+
+func (h *histogram) Count() int64 {
+	return h.count
+}
+
+func (h *histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+func (h *histogram) Max() time.Duration {
+	return h.max
+}
+
+// Quantile returns the latency below which a fraction q of the recorded
+// latencies fall, rounded up to its bucket's limit. It is 0 for an empty
+// histogram.
+func (h *histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.count) + 0.5)
+	rank = min(max(rank, 1), h.count)
+	seen := int64(0)
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return min(time.Duration(bucketLimit(i))*time.Microsecond, h.max)
+		}
+	}
+	return h.max
+}
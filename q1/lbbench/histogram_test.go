@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestBucketLimits(t *testing.T) {
+	for v := uint64(0); v < 1<<20; v++ {
+		i := bucketOf(v)
+		if limit := bucketLimit(i); v > limit {
+			t.Fatalf("%d is above the limit %d of its bucket %d", v, limit, i)
+		}
+		if i > 0 && v <= bucketLimit(i-1) {
+			t.Fatalf("%d is in bucket %d but within the limit of bucket %d", v, i, i-1)
+		}
+	}
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	var h histogram
+	for ms := 1; ms <= 1000; ms++ {
+		h.Record(time.Duration(ms) * time.Millisecond)
+	}
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.50, 500 * time.Millisecond},
+		{0.90, 900 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+		{1, time.Second},
+	} {
+		got := h.Quantile(tc.q)
+		// a bucket spans at most 1/32 of its values
+		if got < tc.want || got > tc.want+tc.want/32 {
+			t.Errorf("Quantile(%v) = %v, want %v within 1/32", tc.q, got, tc.want)
+		}
+	}
+	if h.Mean() != 500500*time.Microsecond {
+		t.Errorf("Mean() = %v, want 500.5ms", h.Mean())
+	}
+	if h.Max() != time.Second {
+		t.Errorf("Max() = %v, want 1s", h.Max())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	var whole, a, b histogram
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 10000; i++ {
+		d := time.Duration(rng.Int64N(int64(5 * time.Second)))
+		whole.Record(d)
+		if i%2 == 0 {
+			a.Record(d)
+		} else {
+			b.Record(d)
+		}
+	}
+	a.Merge(&b)
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		if a.Quantile(q) != whole.Quantile(q) {
+			t.Errorf("merged Quantile(%v) = %v, want %v", q, a.Quantile(q), whole.Quantile(q))
+		}
+	}
+	if a.Count() != whole.Count() || a.Max() != whole.Max() || a.Mean() != whole.Mean() {
+		t.Errorf("merged histogram differs: count %d max %v mean %v, want %d %v %v",
+			a.Count(), a.Max(), a.Mean(), whole.Count(), whole.Max(), whole.Mean())
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := parseMix("sum, fibonacci:30=2,5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mix.String(), "sum,fibonacci:30=2,sort"; got != want {
+		t.Errorf("parseMix(...).String() = %q, want %q", got, want)
+	}
+	// an explicit 0 is sent as the argument, not as a request for the default
+	if mix, err := parseMix("sum:0"); err != nil || mix[0].useDefault || mix.String() != "sum:0" {
+		t.Errorf("parseMix(%q) = %v, %v, want argument 0", "sum:0", mix, err)
+	}
+	for _, spec := range []string{"", "matrix", "sum=0", "fibonacci:x"} {
+		if _, err := parseMix(spec); err == nil {
+			t.Errorf("parseMix(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+// Command lbbench drives the q1 load balancer and backends with a chosen
+// number of workers, request rate, task mix and duration, and reports the
+// latency percentiles and how the requests were spread over the backends.
+// Running it once per policy gives comparable numbers, e.g.
+//
+//	go run ./lbbench --duration=30s --concurrency=10 --label=PF --format=csv --out=bench.csv --append
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"q1/clientlb"
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+	"q1/taskclient"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	loadWaitTimeout = 2 * time.Second
+)
+
+// mixEntry is a task in the mix, sent with argument num, or the backend's
+// default if useDefault is set, in weight out of every total weight
+// requests.
+type mixEntry struct {
+	taskType   lbproto.TaskType
+	num        int64
+	useDefault bool
+	weight     int
+}
+
+func (entry mixEntry) request() *lbproto.BackendRequest {
+	return &lbproto.BackendRequest{TaskType: entry.taskType, Num: entry.num, UseDefault: entry.useDefault}
+}
+
+type taskMix []mixEntry
+
+// parseMix parses a task mix such as "sum,fibonacci:30=2": task types by
+// name or number, each optionally followed by its argument and its weight.
+// Tasks without an argument use the backend's default.
+func parseMix(spec string) (taskMix, error) {
+	var mix taskMix
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		entry := mixEntry{weight: 1}
+		field, weight, hasWeight := strings.Cut(field, "=")
+		if hasWeight {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid weight %q in task mix", weight)
+			}
+			entry.weight = w
+		}
+		name, num, hasNum := strings.Cut(field, ":")
+		if hasNum {
+			n, err := strconv.ParseInt(num, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument %q in task mix", num)
+			}
+			entry.num = n
+		} else {
+			entry.useDefault = true
+		}
+		if value, exists := lbproto.TaskType_value[strings.ToUpper(name)]; exists {
+			entry.taskType = lbproto.TaskType(value)
+		} else if value, err := strconv.ParseInt(name, 10, 32); err == nil {
+			entry.taskType = lbproto.TaskType(value)
+		} else {
+			return nil, fmt.Errorf("unknown task type %q in task mix", name)
+		}
+		mix = append(mix, entry)
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("empty task mix")
+	}
+	return mix, nil
+}
+
+func (mix taskMix) String() string {
+	fields := make([]string, len(mix))
+	for i, entry := range mix {
+		field := strings.ToLower(entry.taskType.String())
+		if !entry.useDefault {
+			field += ":" + strconv.FormatInt(entry.num, 10)
+		}
+		if entry.weight != 1 {
+			field += "=" + strconv.Itoa(entry.weight)
+		}
+		fields[i] = field
+	}
+	return strings.Join(fields, ",")
+}
+
+// pick draws a task from the mix by weight.
+func (mix taskMix) pick(rng *rand.Rand) mixEntry {
+	total := 0
+	for _, entry := range mix {
+		total += entry.weight
+	}
+	n := rng.IntN(total)
+	for _, entry := range mix {
+		if n < entry.weight {
+			return entry
+		}
+		n -= entry.weight
+	}
+	return mix[len(mix)-1]
+}
+
+// requester sends one task the way a client would and returns the backend
+// that served it, or "" if the request never reached one.
+type requester interface {
+	send(ctx context.Context, req *lbproto.BackendRequest) (string, error)
+	Close() error
+}
+
+// servedBy returns the backend named in the response header of a call.
+func servedBy(header metadata.MD) string {
+	if values := header.Get(registry.ServerAddrHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// lookasideRequester asks the load balancer for a backend, then calls it,
+// like the client without flags, with the client's retries and hedging.
+type lookasideRequester struct {
+	client *taskclient.Client
+}
+
+func (r *lookasideRequester) send(ctx context.Context, req *lbproto.BackendRequest) (string, error) {
+	result, err := r.client.Send(ctx, req)
+	return result.Addr, err
+}
+
+func (r *lookasideRequester) Close() error {
+	return r.client.Close()
+}
+
+// backendRequester calls BackendRPC on one connection: the load balancer's
+// proxy, or a channel balanced in the client.
+type backendRequester struct {
+	conn      *grpc.ClientConn
+	clientLB  bool
+	cancelSub context.CancelFunc
+}
+
+func (r *backendRequester) send(ctx context.Context, req *lbproto.BackendRequest) (string, error) {
+	if r.clientLB {
+		tasktype := int32(req.GetTaskType())
+		ctx = clientlb.WithPickRequest(ctx, lbpolicy.PickRequest{TaskType: tasktype, HashKey: lbpolicy.RequestHashKey(tasktype, req.GetNum())})
+	}
+	var header metadata.MD
+	_, err := lbproto.NewBackendServiceClient(r.conn).BackendRPC(ctx, req, grpc.Header(&header))
+	return servedBy(header), err
+}
+
+func (r *backendRequester) Close() error {
+	if r.cancelSub != nil {
+		r.cancelSub()
+	}
+	return r.conn.Close()
+}
+
+// runConfig is what lbbench was asked to do.
+type runConfig struct {
+	label       string
+	proxy       bool
+	clientLB    string
+	concurrency int
+	qps         float64
+	duration    time.Duration
+	timeout     time.Duration
+	mix         taskMix
+	seed        uint64
+	retry       taskclient.Config // lookaside mode only: retries, hedging and locality
+}
+
+// mode names how requests reach the backends.
+func (c runConfig) mode() string {
+	switch {
+	case c.proxy:
+		return "proxy"
+	case c.clientLB != "":
+		return "client-lb " + c.clientLB
+	}
+	return "lookaside"
+}
+
+func newRequester(config runConfig, lbConn *grpc.ClientConn, registryConfig registry.Config) (requester, error) {
+	if config.proxy {
+		return &backendRequester{conn: lbConn}, nil
+	}
+	lbClient := lbproto.NewLoadBalancingServiceClient(lbConn)
+	if config.clientLB == "" {
+		return &lookasideRequester{client: taskclient.New(lbClient, config.retry)}, nil
+	}
+
+	if _, err := lbpolicy.New(config.clientLB); err != nil {
+		return nil, err
+	}
+	subCtx, cancelSub := context.WithCancel(context.Background())
+	go clientlb.SubscribeLoad(subCtx, lbClient)
+	target := clientlb.Scheme + "://" + strings.TrimSuffix(registryConfig.KeyPrefix, "/")
+	conn, err := grpc.NewClient(target,
+		grpc.WithResolvers(clientlb.NewResolverBuilder(registryConfig)),
+		grpc.WithDefaultServiceConfig(clientlb.ServiceConfig(config.clientLB)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		cancelSub()
+		return nil, err
+	}
+	select {
+	case <-clientlb.LoadReady():
+	case <-time.After(loadWaitTimeout):
+		log.Printf("lbbench - No load report from the load balancer yet, picking without it")
+	}
+	return &backendRequester{conn: conn, clientLB: true, cancelSub: cancelSub}, nil
+}
+
+// run sends requests until ctx is done and waits for those in flight. With a
+// target rate, requests are paced across the workers; otherwise each worker
+// sends its next request as soon as the last one returns.
+func run(ctx context.Context, config runConfig, req requester) (*collector, int64, time.Duration) {
+	var missed atomic.Int64
+	var tokens chan struct{}
+	if config.qps > 0 {
+		tokens = make(chan struct{}, config.concurrency)
+		go pace(ctx, config.qps, tokens, &missed)
+	}
+
+	start := time.Now()
+	collectors := make([]*collector, config.concurrency)
+	var wg sync.WaitGroup
+	for i := range collectors {
+		collectors[i] = newCollector()
+		wg.Add(1)
+		go func(results *collector, rng *rand.Rand) {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-tokens:
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+
+				entry := config.mix.pick(rng)
+				reqCtx, cancel := context.WithTimeout(context.Background(), config.timeout)
+				sent := time.Now()
+				backend, err := req.send(reqCtx, entry.request())
+				results.record(entry.taskType, backend, time.Since(sent), err)
+				cancel()
+			}
+		}(collectors[i], rand.New(rand.NewPCG(config.seed, uint64(i))))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	results := newCollector()
+	for _, c := range collectors {
+		results.merge(c)
+	}
+	return results, missed.Load(), elapsed
+}
+
+// pace hands out qps tokens a second until ctx is done. A token no worker is
+// free to take is counted in missed rather than saved up, so a slow run does
+// not turn into a burst.
+func pace(ctx context.Context, qps float64, tokens chan<- struct{}, missed *atomic.Int64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / qps))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			select {
+			case tokens <- struct{}{}:
+			default:
+				missed.Add(1)
+			}
+		}
+	}
+}
+
+func main() {
+	var config runConfig
+	flag.StringVar(&config.label, "label", "", "name for this run in the report, such as the load balancer's policy")
+	flag.BoolVar(&config.proxy, "proxy", false, "send requests through the load balancer's proxy")
+	flag.StringVar(&config.clientLB, "client-lb", "", "pick backends in lbbench with this policy (PF, RR, LL, ...) instead of asking the load balancer")
+	flag.IntVar(&config.concurrency, "concurrency", 10, "number of workers sending requests")
+	flag.Float64Var(&config.qps, "qps", 0, "target requests per second across all workers, 0 to send as fast as the workers can")
+	flag.DurationVar(&config.duration, "duration", 30*time.Second, "how long to send requests for")
+	flag.DurationVar(&config.timeout, "timeout", time.Minute, "deadline of each request")
+	mixSpec := flag.String("mix", "sum,nth_prime,fibonacci", "task mix: task types by name or number, each optionally with :argument and =weight, e.g. sum,fibonacci:30=2")
+	config.retry = taskclient.DefaultConfig()
+	flag.IntVar(&config.retry.MaxAttempts, "attempts", 1, "lookaside mode: times to try each request, on a different backend each time while there is one")
+	flag.DurationVar(&config.retry.HedgeDelay, "hedge-after", 0, "lookaside mode: send a request to a second backend if the first has not answered after this long, 0 to disable")
+	flag.StringVar(&config.retry.Zone, "zone", "", "lookaside mode: zone to send requests from; the load balancer prefers backends there")
+	flag.StringVar(&config.retry.Region, "region", "", "lookaside mode: region to send requests from")
+	flag.Uint64Var(&config.seed, "seed", 1, "seed of the task sequence, the same seed sends the same tasks")
+	lbAddr := flag.String("lb-addr", registry.DefaultLoadBalancerAddr, "load balancer address, used when none is elected in the registry")
+	format := flag.String("format", "text", "report format: text, json or csv")
+	outPath := flag.String("out", "", "write the report to this file instead of standard output")
+	appendOut := flag.Bool("append", false, "append to --out instead of replacing it; a csv header is only written to an empty file")
+	var registryConfig registry.Config
+	registryConfig.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	mix, err := parseMix(*mixSpec)
+	if err != nil {
+		log.Fatalf("lbbench - %v", err)
+	}
+	config.mix = mix
+	if config.concurrency < 1 {
+		log.Fatalf("lbbench - --concurrency must be at least 1")
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		log.Fatalf("lbbench - Unknown format %q, use text, json or csv", *format)
+	}
+
+	lbConn, err := grpc.NewClient(registry.FindLoadBalancer(registryConfig, *lbAddr), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("lbbench - Could not connect to the load balancer: %v", err)
+	}
+	defer lbConn.Close()
+	req, err := newRequester(config, lbConn, registryConfig)
+	if err != nil {
+		log.Fatalf("lbbench - %v", err)
+	}
+	defer req.Close()
+
+	// Ctrl-C ends the run early; the report covers what was sent until then
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, config.duration)
+	defer cancel()
+
+	log.Printf("lbbench - Sending %s for %v with %d workers (%s)", config.mix, config.duration, config.concurrency, config.mode())
+	results, missed, elapsed := run(ctx, config, req)
+	report := newReport(config, results, missed, elapsed)
+
+	var out io.Writer = os.Stdout
+	header := true
+	if *outPath != "" {
+		mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if *appendOut {
+			mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		file, err := os.OpenFile(*outPath, mode, 0644)
+		if err != nil {
+			log.Fatalf("lbbench - %v", err)
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && info.Size() > 0 {
+			header = false
+		}
+		out = file
+	}
+
+	switch *format {
+	case "json":
+		err = report.WriteJSON(out)
+	case "csv":
+		err = report.WriteCSV(out, header)
+	default:
+		err = report.WriteText(out)
+	}
+	if err != nil {
+		log.Fatalf("lbbench - Could not write the report: %v", err)
+	}
+}
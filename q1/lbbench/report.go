@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// groupStats counts the requests of one task type, one backend or the whole
+// run. Only successful requests are in the latency histogram.
+type groupStats struct {
+	requests int64
+	errors   int64
+	latency  histogram
+}
+
+func (g *groupStats) record(latency time.Duration, err error) {
+	g.requests++
+	if err != nil {
+		g.errors++
+		return
+	}
+	g.latency.Record(latency)
+}
+
+func (g *groupStats) merge(other *groupStats) {
+	g.requests += other.requests
+	g.errors += other.errors
+	g.latency.Merge(&other.latency)
+}
+
+// collector gathers the results of one worker.
+type collector struct {
+	total    groupStats
+	tasks    map[lbproto.TaskType]*groupStats
+	backends map[string]*groupStats
+	errors   map[codes.Code]int64
+}
+
+func newCollector() *collector {
+	return &collector{
+		tasks:    make(map[lbproto.TaskType]*groupStats),
+		backends: make(map[string]*groupStats),
+		errors:   make(map[codes.Code]int64),
+	}
+}
+
+// record adds a request. backend is empty when the request failed before
+// reaching one.
+func (c *collector) record(taskType lbproto.TaskType, backend string, latency time.Duration, err error) {
+	c.total.record(latency, err)
+	group(c.tasks, taskType).record(latency, err)
+	if backend != "" {
+		group(c.backends, backend).record(latency, err)
+	}
+	if err != nil {
+		c.errors[status.Code(err)]++
+	}
+}
+
+func (c *collector) merge(other *collector) {
+	c.total.merge(&other.total)
+	for taskType, stats := range other.tasks {
+		group(c.tasks, taskType).merge(stats)
+	}
+	for backend, stats := range other.backends {
+		group(c.backends, backend).merge(stats)
+	}
+	for code, n := range other.errors {
+		c.errors[code] += n
+	}
+}
+
+func group[K comparable](groups map[K]*groupStats, key K) *groupStats {
+	stats, exists := groups[key]
+	if !exists {
+		stats = &groupStats{}
+		groups[key] = stats
+	}
+	return stats
+}
+
+// Report is the outcome of a run. Latencies are in milliseconds and only
+// cover successful requests.
+type Report struct {
+	Label       string           `json:"label,omitempty"`
+	Mode        string           `json:"mode"`
+	Mix         string           `json:"mix"`
+	Concurrency int              `json:"concurrency"`
+	TargetQPS   float64          `json:"targetQps,omitempty"`
+	Seconds     float64          `json:"seconds"`
+	Missed      int64            `json:"missed,omitempty"` // paced requests not sent because every worker was busy
+	Total       GroupReport      `json:"total"`
+	Tasks       []GroupReport    `json:"tasks"`
+	Backends    []GroupReport    `json:"backends"`
+	Errors      map[string]int64 `json:"errors,omitempty"` // by status code
+}
+
+type GroupReport struct {
+	Name       string  `json:"name"`
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	Share      float64 `json:"share"`      // fraction of all requests
+	Throughput float64 `json:"throughput"` // successful requests per second
+	MeanMs     float64 `json:"meanMs"`
+	P50Ms      float64 `json:"p50Ms"`
+	P90Ms      float64 `json:"p90Ms"`
+	P99Ms      float64 `json:"p99Ms"`
+	MaxMs      float64 `json:"maxMs"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func groupReport(name string, stats *groupStats, total int64, elapsed time.Duration) GroupReport {
+	report := GroupReport{
+		Name:     name,
+		Requests: stats.requests,
+		Errors:   stats.errors,
+		MeanMs:   milliseconds(stats.latency.Mean()),
+		P50Ms:    milliseconds(stats.latency.Quantile(0.50)),
+		P90Ms:    milliseconds(stats.latency.Quantile(0.90)),
+		P99Ms:    milliseconds(stats.latency.Quantile(0.99)),
+		MaxMs:    milliseconds(stats.latency.Max()),
+	}
+	if total > 0 {
+		report.Share = float64(stats.requests) / float64(total)
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(stats.latency.Count()) / elapsed.Seconds()
+	}
+	return report
+}
+
+// newReport summarizes the merged results of a run that took elapsed.
+func newReport(config runConfig, results *collector, missed int64, elapsed time.Duration) *Report {
+	total := results.total.requests
+	report := &Report{
+		Label:       config.label,
+		Mode:        config.mode(),
+		Mix:         config.mix.String(),
+		Concurrency: config.concurrency,
+		TargetQPS:   config.qps,
+		Seconds:     elapsed.Seconds(),
+		Missed:      missed,
+		Total:       groupReport("total", &results.total, total, elapsed),
+		Tasks:       []GroupReport{},
+		Backends:    []GroupReport{},
+	}
+
+	taskTypes := make([]lbproto.TaskType, 0, len(results.tasks))
+	for taskType := range results.tasks {
+		taskTypes = append(taskTypes, taskType)
+	}
+	sort.Slice(taskTypes, func(i, j int) bool { return taskTypes[i] < taskTypes[j] })
+	for _, taskType := range taskTypes {
+		report.Tasks = append(report.Tasks, groupReport(taskType.String(), results.tasks[taskType], total, elapsed))
+	}
+
+	backends := make([]string, 0, len(results.backends))
+	for backend := range results.backends {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	for _, backend := range backends {
+		report.Backends = append(report.Backends, groupReport(backend, results.backends[backend], total, elapsed))
+	}
+
+	if len(results.errors) > 0 {
+		report.Errors = make(map[string]int64, len(results.errors))
+		for code, n := range results.errors {
+			report.Errors[code.String()] = n
+		}
+	}
+	return report
+}
+
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%s: %d workers", r.Mode, r.Concurrency)
+	if r.TargetQPS > 0 {
+		fmt.Fprintf(w, " at %g qps", r.TargetQPS)
+	}
+	fmt.Fprintf(w, ", mix %s, %.1fs", r.Mix, r.Seconds)
+	if r.Label != "" {
+		fmt.Fprintf(w, " [%s]", r.Label)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\trequests\terrors\tshare\treq/s\tmean ms\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+	for _, row := range r.rows() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%.2f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			row.Name, row.Requests, row.Errors, 100*row.Share, row.Throughput, row.MeanMs, row.P50Ms, row.P90Ms, row.P99Ms, row.MaxMs)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Missed > 0 {
+		fmt.Fprintf(w, "%d requests not sent on time because every worker was busy\n", r.Missed)
+	}
+	codes := make([]string, 0, len(r.Errors))
+	for code := range r.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "%d requests failed with %s\n", r.Errors[code], code)
+	}
+	return nil
+}
+
+// rows returns the total, then the task types, then the backends.
+func (r *Report) rows() []GroupReport {
+	rows := []GroupReport{r.Total}
+	rows = append(rows, r.Tasks...)
+	return append(rows, r.Backends...)
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// csvHeader names the columns WriteCSV writes.
+var csvHeader = []string{"label", "mode", "mix", "concurrency", "target_qps", "seconds", "group", "name",
+	"requests", "errors", "share", "throughput", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "max_ms"}
+
+// WriteCSV writes a row for the total, each task type and each backend,
+// preceded by csvHeader if header is set. Rows of several runs can go in one
+// file, told apart by their label.
+func (r *Report) WriteCSV(w io.Writer, header bool) error {
+	out := csv.NewWriter(w)
+	if header {
+		out.Write(csvHeader)
+	}
+	groups := []string{"total"}
+	for range r.Tasks {
+		groups = append(groups, "task")
+	}
+	for range r.Backends {
+		groups = append(groups, "backend")
+	}
+	for i, row := range r.rows() {
+		out.Write([]string{
+			r.Label, r.Mode, r.Mix, strconv.Itoa(r.Concurrency), formatFloat(r.TargetQPS), formatFloat(r.Seconds),
+			groups[i], row.Name, strconv.FormatInt(row.Requests, 10), strconv.FormatInt(row.Errors, 10),
+			formatFloat(row.Share), formatFloat(row.Throughput), formatFloat(row.MeanMs),
+			formatFloat(row.P50Ms), formatFloat(row.P90Ms), formatFloat(row.P99Ms), formatFloat(row.MaxMs),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
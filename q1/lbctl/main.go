@@ -0,0 +1,233 @@
+// Command lbctl manages a running q1 load balancer through its AdminService.
+//
+//	lbctl backends              list the backends with their load and health
+//	lbctl stats                 show the load balancer's counters
+//	lbctl policy NAME           switch to policy NAME (PF, RR, LL, ...)
+//	lbctl drain ADDR            stop sending requests to backend ADDR
+//	lbctl resume ADDR           send requests to a drained backend again
+//
+// It talks to the load balancer elected in the registry, or --lb-addr.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	callTimeout       = 5 * time.Second
+	drainPollInterval = 500 * time.Millisecond
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `usage: lbctl [flags] command [args]
+
+commands:
+  backends      list the backends with their load and health
+  stats         show the load balancer's counters
+  policy NAME   switch to policy NAME (PF, RR, LL, P2C, EWMA, WRR, WLL or CH)
+  drain ADDR    stop sending requests to backend ADDR
+  resume ADDR   send requests to a drained backend again
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func listBackends(client lbproto.AdminServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	list, err := client.ListBackends(ctx, &lbproto.Empty{})
+	if err != nil {
+		log.Fatalf("lbctl - ListBackends failed: %v", err)
+	}
+
+	fmt.Printf("policy %s, %d backends\n", list.GetPolicy(), len(list.GetBackends()))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDR\tSTATE\tZONE\tWEIGHT\tTASKS\tLOAD\tIN FLIGHT\tQUEUE\tLATENCY\tLAST REPORT\tPICKS\tERRORS")
+	for _, backend := range list.GetBackends() {
+		tasks := backend.GetTasks()
+		if tasks == "" {
+			tasks = "all"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%.2f %v\t%d\t%d\t%.1fms\t%s\t%d\t%d\n",
+			backend.GetAddr(), describeState(backend), describeLocality(backend), backend.GetWeight(), tasks,
+			backend.GetLoad(), backend.GetMetric(), backend.GetInFlight(), backend.GetQueueDepth(),
+			backend.GetLatency(), describeReport(backend), backend.GetPicks(), backend.GetErrors())
+	}
+	tw.Flush()
+}
+
+func describeState(backend *lbproto.BackendInfo) string {
+	state := "healthy"
+	if !backend.GetHealthy() {
+		state = fmt.Sprintf("unhealthy (%d failed checks)", backend.GetFailedChecks())
+	}
+	if backend.GetDraining() {
+		state += ", draining"
+	}
+	if backend.GetShuttingDown() {
+		state += ", shutting down"
+	}
+	if until := backend.GetEjectedUntil(); until != 0 {
+		state += fmt.Sprintf(", ejected for %v", time.Until(time.Unix(0, until)).Round(time.Second))
+	}
+	return state
+}
+
+func describeLocality(backend *lbproto.BackendInfo) string {
+	switch {
+	case backend.GetZone() == "" && backend.GetRegion() == "":
+		return "-"
+	case backend.GetRegion() == "":
+		return backend.GetZone()
+	}
+	return backend.GetRegion() + "/" + backend.GetZone()
+}
+
+func describeReport(backend *lbproto.BackendInfo) string {
+	if backend.GetLastReport() == 0 {
+		return "never"
+	}
+	age := time.Since(time.Unix(0, backend.GetLastReport())).Round(100 * time.Millisecond)
+	if backend.GetLoadStale() {
+		return fmt.Sprintf("%v ago (stale)", age)
+	}
+	return fmt.Sprintf("%v ago", age)
+}
+
+func showStats(client lbproto.AdminServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	stats, err := client.GetStats(ctx, &lbproto.Empty{})
+	if err != nil {
+		log.Fatalf("lbctl - GetStats failed: %v", err)
+	}
+
+	role := "leader"
+	if !stats.GetLeader() {
+		role = "standby"
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "policy\t%s (%d changes)\n", stats.GetPolicy(), stats.GetPolicyChanges())
+	fmt.Fprintf(tw, "role\t%s\n", role)
+	fmt.Fprintf(tw, "uptime\t%v\n", time.Since(time.Unix(0, stats.GetStartedAt())).Round(time.Second))
+	fmt.Fprintf(tw, "backends\t%d, %d healthy, %d draining\n", stats.GetBackends(), stats.GetHealthy(), stats.GetDraining())
+	fmt.Fprintf(tw, "picks\t%d, %d failed\n", stats.GetPicks(), stats.GetPickErrors())
+	fmt.Fprintf(tw, "proxied\t%d, %d failed\n", stats.GetProxied(), stats.GetProxyErrors())
+	fmt.Fprintf(tw, "spillovers\t%d picks looked past the caller's zone or region\n", stats.GetSpillovers())
+	fmt.Fprintf(tw, "outliers\t%d ejected, %d ejections, %d call outcomes\n", stats.GetEjected(), stats.GetEjections(), stats.GetOutcomes())
+	tw.Flush()
+}
+
+func setPolicy(client lbproto.AdminServiceClient, policy string) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	resp, err := client.SetPolicy(ctx, &lbproto.SetPolicyRequest{Policy: strings.ToUpper(policy)})
+	if err != nil {
+		log.Fatalf("lbctl - SetPolicy failed: %v", err)
+	}
+	fmt.Printf("policy changed from %s to %s\n", resp.GetPrevious(), resp.GetPolicy())
+}
+
+// drainBackend drains or resumes a backend. With wait it then polls the
+// backend until it reports nothing in flight, or until timeout.
+func drainBackend(client lbproto.AdminServiceClient, addr string, resume, wait bool, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	backend, err := client.DrainBackend(ctx, &lbproto.DrainBackendRequest{Addr: addr, Resume: resume})
+	if err != nil {
+		log.Fatalf("lbctl - DrainBackend failed: %v", err)
+	}
+	if resume {
+		fmt.Printf("%s resumed\n", addr)
+		return
+	}
+	fmt.Printf("%s draining, %d requests in flight\n", addr, backend.GetInFlight())
+	if !wait {
+		return
+	}
+
+	deadline := time.Now().Add(timeout)
+	for backend.GetInFlight() > 0 || backend.GetQueueDepth() > 0 {
+		if time.Now().After(deadline) {
+			log.Fatalf("lbctl - %s still has %d requests in flight after %v", addr, backend.GetInFlight(), timeout)
+		}
+		time.Sleep(drainPollInterval)
+		backend = findBackend(client, addr)
+	}
+	fmt.Printf("%s drained\n", addr)
+}
+
+func findBackend(client lbproto.AdminServiceClient, addr string) *lbproto.BackendInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	list, err := client.ListBackends(ctx, &lbproto.Empty{})
+	if err != nil {
+		log.Fatalf("lbctl - ListBackends failed: %v", err)
+	}
+	for _, backend := range list.GetBackends() {
+		if backend.GetAddr() == addr {
+			return backend
+		}
+	}
+	// the backend left the registry, so nothing more is sent to it
+	return &lbproto.BackendInfo{Addr: addr}
+}
+
+func main() {
+	lbAddr := flag.String("lb-addr", registry.DefaultLoadBalancerAddr, "load balancer address, used when none is elected in the registry")
+	wait := flag.Bool("wait", false, "drain: wait until the backend reports no requests in flight")
+	waitTimeout := flag.Duration("wait-timeout", 5*time.Minute, "drain: how long --wait waits")
+	var registryConfig registry.Config
+	registryConfig.AddFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, args := args[0], args[1:]
+	wantArgs := map[string]int{"backends": 0, "stats": 0, "policy": 1, "drain": 1, "resume": 1}
+	n, known := wantArgs[command]
+	if !known {
+		log.Fatalf("lbctl - Unknown command %q, run lbctl -h for the list", command)
+	}
+	if len(args) != n {
+		log.Fatalf("lbctl - %s takes %d arguments, got %d", command, n, len(args))
+	}
+
+	conn, err := grpc.NewClient(registry.FindLoadBalancer(registryConfig, *lbAddr), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("lbctl - Could not connect to the load balancer: %v", err)
+	}
+	defer conn.Close()
+	client := lbproto.NewAdminServiceClient(conn)
+
+	switch command {
+	case "backends":
+		listBackends(client)
+	case "stats":
+		showStats(client)
+	case "policy":
+		setPolicy(client, args[0])
+	case "drain":
+		drainBackend(client, args[0], false, *wait, *waitTimeout)
+	case "resume":
+		drainBackend(client, args[0], true, false, 0)
+	}
+}
@@ -0,0 +1,135 @@
+package lbpolicy
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ringReplicas is the number of points each unit of backend weight gets on
+// the hash ring. More points spread keys more evenly.
+const ringReplicas = 100
+
+// HashRing maps keys to backends with consistent hashing. Each backend owns
+// the arcs ending at its points, so adding or removing a backend only moves
+// the keys on that backend's arcs.
+type HashRing struct {
+	points []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash uint64
+	addr string
+}
+
+func NewHashRing(backends []*Backend) *HashRing {
+	ring := &HashRing{}
+	for _, backend := range backends {
+		for i := 0; i < int(backend.Weight)*ringReplicas; i++ {
+			ring.points = append(ring.points, ringPoint{hash: hashString(backend.Addr + "#" + strconv.Itoa(i)), addr: backend.Addr})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i].hash < ring.points[j].hash
+	})
+	return ring
+}
+
+// Get returns the address of the backend owning key, or "" if the ring is
+// empty.
+func (ring *HashRing) Get(key uint64) string {
+	return ring.Next(key, func(string) bool { return true })
+}
+
+// Next returns the address of the first backend clockwise from key's point
+// that accept takes, or "" if it takes none. With every backend accepted it
+// is the owner of key; the keys of a backend that is not accepted go to the
+// next ones on the ring, and come back when it is accepted again.
+func (ring *HashRing) Next(key uint64, accept func(addr string) bool) string {
+	if len(ring.points) == 0 {
+		return ""
+	}
+	key = mix64(key)
+	start := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i].hash >= key
+	})
+	for n := 0; n < len(ring.points); n++ {
+		point := ring.points[(start+n)%len(ring.points)] // wrap around
+		if accept(point.addr) {
+			return point.addr
+		}
+	}
+	return ""
+}
+
+// consistentHash routes requests with the same HashKey to the same backend,
+// so results cached on a backend are reused. Requests without a key are
+// spread at random. The ring holds every registered backend, not only the
+// candidates of a pick, so it is rebuilt only when the backends or their
+// weights change; a key whose owner is not a candidate goes to the next
+// candidate clockwise.
+type consistentHash struct {
+	ring    *HashRing
+	members string // backends the ring was built for
+}
+
+func (ch *consistentHash) SetMembers(backends []*Backend) {
+	members := ringMembers(backends)
+	if ch.ring == nil || members != ch.members {
+		ch.ring, ch.members = NewHashRing(backends), members
+	}
+}
+
+func (ch *consistentHash) Pick(req PickRequest, backends []*Backend) *Backend {
+	if ch.ring == nil {
+		ch.SetMembers(backends) // the caller did not tell the members
+	}
+	key := req.HashKey
+	if key == 0 {
+		key = rand.Uint64()
+	}
+	candidates := make(map[string]*Backend, len(backends))
+	for _, backend := range backends {
+		candidates[backend.Addr] = backend
+	}
+	addr := ch.ring.Next(key, func(addr string) bool {
+		_, candidate := candidates[addr]
+		return candidate
+	})
+	if backend, candidate := candidates[addr]; candidate {
+		return backend
+	}
+	return backends[0] // only if the caller left the candidates out of SetMembers
+}
+
+// ringMembers identifies a backend set, including weights, for deciding when
+// the ring has to be rebuilt.
+func ringMembers(backends []*Backend) string {
+	var sb strings.Builder
+	for _, backend := range backends {
+		sb.WriteString(backend.Addr)
+		sb.WriteByte('/')
+		sb.WriteString(strconv.Itoa(int(backend.Weight)))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return mix64(h.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer. It spreads similar inputs, such as
+// sequential keys or point names, evenly over the ring.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
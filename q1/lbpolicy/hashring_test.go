@@ -0,0 +1,142 @@
+package lbpolicy
+
+import (
+	"fmt"
+	"testing"
+)
+
+const ringTestKeys = 100000
+
+func ringTestBackends(n int) []*Backend {
+	backends := make([]*Backend, n)
+	for i := range backends {
+		backends[i] = &Backend{Addr: fmt.Sprintf("localhost:%d", 50400+i), Weight: 1}
+	}
+	return backends
+}
+
+func ringAssignments(ring *HashRing) []string {
+	owners := make([]string, ringTestKeys)
+	for key := range owners {
+		owners[key] = ring.Get(uint64(key))
+	}
+	return owners
+}
+
+func TestHashRingAddBackendRemapsOnlyToNewBackend(t *testing.T) {
+	backends := ringTestBackends(10)
+	before := ringAssignments(NewHashRing(backends))
+	added := &Backend{Addr: "localhost:50499", Weight: 1}
+	after := ringAssignments(NewHashRing(append(backends, added)))
+
+	remapped := 0
+	for key := range before {
+		if before[key] == after[key] {
+			continue
+		}
+		remapped++
+		if after[key] != added.Addr {
+			t.Fatalf("key %d moved from %s to %s, want it on the new backend or unchanged", key, before[key], after[key])
+		}
+	}
+
+	// The new backend should take about 1/11 of the keys
+	fraction := float64(remapped) / ringTestKeys
+	t.Logf("remapped %.2f%% of keys after adding a backend", fraction*100)
+	if fraction < 0.5/11 || fraction > 1.5/11 {
+		t.Errorf("remapped fraction = %.3f, want about %.3f", fraction, 1.0/11)
+	}
+}
+
+func TestHashRingRemoveBackendRemapsOnlyItsKeys(t *testing.T) {
+	backends := ringTestBackends(10)
+	before := ringAssignments(NewHashRing(backends))
+	removed := backends[3]
+	remaining := append(append([]*Backend{}, backends[:3]...), backends[4:]...)
+	after := ringAssignments(NewHashRing(remaining))
+
+	remapped := 0
+	for key := range before {
+		if before[key] == after[key] {
+			continue
+		}
+		remapped++
+		if before[key] != removed.Addr {
+			t.Fatalf("key %d moved from %s to %s, only keys of the removed backend should move", key, before[key], after[key])
+		}
+	}
+
+	fraction := float64(remapped) / ringTestKeys
+	t.Logf("remapped %.2f%% of keys after removing a backend", fraction*100)
+	if fraction < 0.5/10 || fraction > 1.5/10 {
+		t.Errorf("remapped fraction = %.3f, want about %.3f", fraction, 1.0/10)
+	}
+}
+
+func TestHashRingSpreadsKeysByWeight(t *testing.T) {
+	backends := ringTestBackends(4)
+	backends[0].Weight = 2
+	counts := make(map[string]int)
+	for _, owner := range ringAssignments(NewHashRing(backends)) {
+		counts[owner]++
+	}
+
+	// weights 2, 1, 1, 1 give shares of 40% and 20%
+	for i, backend := range backends {
+		want := float64(backend.Weight) / 5
+		share := float64(counts[backend.Addr]) / ringTestKeys
+		if share < want*0.75 || share > want*1.25 {
+			t.Errorf("backend %d got %.3f of the keys, want about %.3f", i, share, want)
+		}
+	}
+}
+
+func TestConsistentHashPolicyIsSticky(t *testing.T) {
+	backends := ringTestBackends(5)
+	policy := &consistentHash{}
+	req := PickRequest{TaskType: 2, HashKey: 12345}
+
+	first := policy.Pick(req, backends)
+	for i := 0; i < 10; i++ {
+		if got := policy.Pick(req, backends); got != first {
+			t.Fatalf("pick %d = %s, want %s for the same key", i, got.Addr, first.Addr)
+		}
+	}
+}
+
+func TestConsistentHashPolicySkipsNonCandidates(t *testing.T) {
+	backends := ringTestBackends(5)
+	policy := &consistentHash{}
+	policy.SetMembers(backends)
+	ring := policy.ring
+
+	owners := make([]*Backend, 1000)
+	for key := range owners {
+		owners[key] = policy.Pick(PickRequest{HashKey: uint64(key + 1)}, backends)
+	}
+	// leave backends[2] out of the candidates, as when it is draining
+	candidates := append(append([]*Backend{}, backends[:2]...), backends[3:]...)
+	for key, owner := range owners {
+		got := policy.Pick(PickRequest{HashKey: uint64(key + 1)}, candidates)
+		if owner != backends[2] && got != owner {
+			t.Fatalf("key %d moved from %s to %s, only keys of the left out backend should move", key, owner.Addr, got.Addr)
+		}
+		if got == backends[2] {
+			t.Fatalf("key %d picked %s, which is not a candidate", key, got.Addr)
+		}
+	}
+	if policy.ring != ring {
+		t.Error("ring rebuilt for a pick over fewer candidates, want it kept while the members are the same")
+	}
+	for key, owner := range owners {
+		if got := policy.Pick(PickRequest{HashKey: uint64(key + 1)}, backends); got != owner {
+			t.Fatalf("key %d on %s once every backend is a candidate again, want %s", key, got.Addr, owner.Addr)
+		}
+	}
+
+	backends[0].Weight = 2
+	policy.SetMembers(backends)
+	if policy.ring == ring {
+		t.Error("ring kept after a weight change, want it rebuilt")
+	}
+}
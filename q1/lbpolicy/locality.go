@@ -0,0 +1,67 @@
+package lbpolicy
+
+// Locality tiers, from nearest to the caller to farthest.
+const (
+	TierZone = iota
+	TierRegion
+	TierAll
+)
+
+// LocalBackends narrows backends to the ones nearest the caller of req: those
+// in its zone, else those in its region, else all of them. A tier is passed
+// over for the next, wider one when it has no backend or when its backends
+// carry, on average, at least spillover requests in flight per unit of
+// weight; spillover 0 passes over empty tiers only. The wider tier still
+// includes the nearer backends, so the policy can keep using the ones with
+// room. It returns the backends and their tier, TierAll when req has no
+// locality.
+func LocalBackends(req PickRequest, backends []*Backend, spillover float64) ([]*Backend, int) {
+	if req.Zone == "" && req.Region == "" {
+		return backends, TierAll
+	}
+	inZone := func(b *Backend) bool {
+		return req.Zone != "" && b.Zone == req.Zone && (req.Region == "" || b.Region == req.Region)
+	}
+	inRegion := func(b *Backend) bool {
+		return req.Region != "" && b.Region == req.Region
+	}
+	for tier, member := range []func(*Backend) bool{inZone, inRegion} {
+		var local []*Backend
+		for _, b := range backends {
+			if member(b) {
+				local = append(local, b)
+			}
+		}
+		if len(local) > 0 && !overloaded(local, spillover) {
+			return local, tier
+		}
+	}
+	return backends, TierAll
+}
+
+// NearestTier is the tier LocalBackends returns for req when its nearest
+// backends have room: TierZone if the caller gave its zone, TierRegion if
+// only its region, else TierAll.
+func (req PickRequest) NearestTier() int {
+	switch {
+	case req.Zone != "":
+		return TierZone
+	case req.Region != "":
+		return TierRegion
+	}
+	return TierAll
+}
+
+// overloaded reports whether backends carry at least spillover requests in
+// flight per unit of weight.
+func overloaded(backends []*Backend, spillover float64) bool {
+	if spillover <= 0 {
+		return false
+	}
+	inFlight, weight := 0, 0
+	for _, b := range backends {
+		inFlight += int(b.InFlight)
+		weight += int(b.Weight)
+	}
+	return float64(inFlight) >= spillover*float64(weight)
+}
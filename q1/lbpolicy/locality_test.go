@@ -0,0 +1,72 @@
+package lbpolicy
+
+import (
+	"slices"
+	"testing"
+)
+
+func localityTestBackends() []*Backend {
+	return []*Backend{
+		{Addr: "a1", Weight: 1, Zone: "a", Region: "east"},
+		{Addr: "a2", Weight: 1, Zone: "a", Region: "east"},
+		{Addr: "b1", Weight: 2, Zone: "b", Region: "east"},
+		{Addr: "c1", Weight: 1, Zone: "c", Region: "west"},
+		{Addr: "x1", Weight: 1},
+	}
+}
+
+func backendAddrs(backends []*Backend) []string {
+	var out []string
+	for _, b := range backends {
+		out = append(out, b.Addr)
+	}
+	return out
+}
+
+func TestLocalBackendsTiers(t *testing.T) {
+	backends := localityTestBackends()
+	tests := []struct {
+		req      PickRequest
+		want     []string
+		wantTier int
+	}{
+		{PickRequest{}, []string{"a1", "a2", "b1", "c1", "x1"}, TierAll},
+		{PickRequest{Zone: "a", Region: "east"}, []string{"a1", "a2"}, TierZone},
+		{PickRequest{Zone: "a"}, []string{"a1", "a2"}, TierZone},
+		// a zone with the caller's name in another region is not local
+		{PickRequest{Zone: "c", Region: "east"}, []string{"a1", "a2", "b1"}, TierRegion},
+		{PickRequest{Region: "west"}, []string{"c1"}, TierRegion},
+		{PickRequest{Zone: "d", Region: "south"}, []string{"a1", "a2", "b1", "c1", "x1"}, TierAll},
+	}
+	for _, tc := range tests {
+		got, tier := LocalBackends(tc.req, backends, 0)
+		if !slices.Equal(backendAddrs(got), tc.want) || tier != tc.wantTier {
+			t.Errorf("%+v: got %v in tier %d, want %v in tier %d", tc.req, backendAddrs(got), tier, tc.want, tc.wantTier)
+		}
+	}
+}
+
+func TestLocalBackendsSpillover(t *testing.T) {
+	backends := localityTestBackends()
+	req := PickRequest{Zone: "a", Region: "east"}
+
+	// zone a has 2 units of weight: 3 in flight is under 2 per unit
+	backends[0].InFlight, backends[1].InFlight = 2, 1
+	if got, tier := LocalBackends(req, backends, 2); tier != TierZone {
+		t.Fatalf("spilled to %v (tier %d) under the threshold", backendAddrs(got), tier)
+	}
+	backends[1].InFlight = 2
+	got, tier := LocalBackends(req, backends, 2)
+	if tier != TierRegion || !slices.Equal(backendAddrs(got), []string{"a1", "a2", "b1"}) {
+		t.Fatalf("at the threshold got %v in tier %d, want the region", backendAddrs(got), tier)
+	}
+	// the region has 4 units of weight
+	backends[2].InFlight = 4
+	if _, tier := LocalBackends(req, backends, 2); tier != TierAll {
+		t.Errorf("overloaded region gave tier %d, want all", tier)
+	}
+	// without a threshold only an empty zone spills over
+	if _, tier := LocalBackends(req, backends, 0); tier != TierZone {
+		t.Errorf("spillover 0 gave tier %d, want the zone", tier)
+	}
+}
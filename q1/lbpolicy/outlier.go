@@ -0,0 +1,293 @@
+package lbpolicy
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// slowStartMinFraction is the share of its traffic a backend gets at the
+// start of slow start.
+const slowStartMinFraction = 0.1
+
+// OutlierConfig configures an OutlierDetector, after Envoy's outlier
+// detection.
+type OutlierConfig struct {
+	// ConsecutiveErrors ejects a backend after this many failed calls in a
+	// row. 0 disables it.
+	ConsecutiveErrors int
+	// LatencyFactor ejects a backend whose mean latency for a task type over
+	// an Interval is more than this many times the median of the other
+	// backends serving that type. Task types are judged apart, so a backend
+	// serving only the heavy ones is not taken for a slow one. 0 disables
+	// it.
+	LatencyFactor float64
+	// MinRequests is the number of calls of a task type a backend needs in
+	// an Interval to be judged, or to count towards the median, on latency.
+	MinRequests int
+	Interval    time.Duration
+	// An ejection lasts BaseEjectionTime times the number of times the
+	// backend has been ejected, up to MaxEjectionTime. The count drops by
+	// one for every Interval without an ejection.
+	BaseEjectionTime time.Duration
+	MaxEjectionTime  time.Duration
+	// MaxEjectionPercent caps the share of backends ejected at once. One
+	// backend may always be ejected, as long as another one is left.
+	MaxEjectionPercent int
+	// SlowStart is how long a returning backend takes to get back to its
+	// full share of requests.
+	SlowStart time.Duration
+}
+
+// OutlierEvent is an ejection, or a backend coming back from one.
+type OutlierEvent struct {
+	Addr    string
+	Ejected bool          // false when the backend returns
+	Reason  string        // why it was ejected
+	For     time.Duration // how long it is ejected
+}
+
+func (ev OutlierEvent) String() string {
+	if !ev.Ejected {
+		return fmt.Sprintf("backend server %s is back from ejection", ev.Addr)
+	}
+	return fmt.Sprintf("ejecting backend server %s for %v: %s", ev.Addr, ev.For, ev.Reason)
+}
+
+// OutlierDetector ejects backends whose calls fail or are much slower than
+// the others'. Like policies it is not safe for concurrent use.
+type OutlierDetector struct {
+	config    OutlierConfig
+	hosts     map[string]*outlierHost
+	lastSweep time.Time
+}
+
+type outlierHost struct {
+	consecutiveErrors int
+	latency           map[int32]*latencyStats // successful calls this interval, by task type
+	ejections         int                     // multiplier of the next ejection time
+	ejectedUntil      time.Time
+	returned          bool // ejectedUntil has passed and the return was reported
+}
+
+type latencyStats struct {
+	sum   time.Duration
+	count int
+}
+
+func NewOutlierDetector(config OutlierConfig) *OutlierDetector {
+	return &OutlierDetector{config: config, hosts: make(map[string]*outlierHost)}
+}
+
+// OutlierFailure reports whether a call that ended with code counts against
+// the backend. Errors the caller caused, such as an invalid argument or
+// giving up on the call, do not. Neither does ResourceExhausted: it is the
+// backend's admission control turning work away while it is busy, and
+// ejecting it would only push its load onto the others until they fill up
+// and are ejected in turn. Clients retry such calls elsewhere.
+func OutlierFailure(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DataLoss,
+		codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// Add starts tracking a backend. Adding a tracked backend does nothing.
+func (d *OutlierDetector) Add(addr string) {
+	if _, exists := d.hosts[addr]; !exists {
+		d.hosts[addr] = &outlierHost{latency: make(map[int32]*latencyStats), returned: true}
+	}
+}
+
+func (d *OutlierDetector) Remove(addr string) {
+	delete(d.hosts, addr)
+}
+
+// Record adds the outcome of a call of taskType to addr, returning the
+// ejection it causes, if any.
+func (d *OutlierDetector) Record(addr string, taskType int32, failed bool, latency time.Duration, now time.Time) *OutlierEvent {
+	host, exists := d.hosts[addr]
+	if !exists {
+		return nil
+	}
+	if !failed {
+		host.consecutiveErrors = 0
+		stats, exists := host.latency[taskType]
+		if !exists {
+			stats = &latencyStats{}
+			host.latency[taskType] = stats
+		}
+		stats.sum += latency
+		stats.count++
+		return nil
+	}
+	host.consecutiveErrors++
+	if d.config.ConsecutiveErrors <= 0 || host.consecutiveErrors < d.config.ConsecutiveErrors || d.ejected(host, now) {
+		return nil
+	}
+	return d.eject(addr, host, now, fmt.Sprintf("%d consecutive errors", host.consecutiveErrors))
+}
+
+// Ejected reports whether addr is ejected at now.
+func (d *OutlierDetector) Ejected(addr string, now time.Time) bool {
+	host, exists := d.hosts[addr]
+	return exists && d.ejected(host, now)
+}
+
+// EjectedUntil returns when the ejection of addr ends, zero if it is not
+// ejected.
+func (d *OutlierDetector) EjectedUntil(addr string, now time.Time) time.Time {
+	host, exists := d.hosts[addr]
+	if !exists || !d.ejected(host, now) {
+		return time.Time{}
+	}
+	return host.ejectedUntil
+}
+
+func (d *OutlierDetector) ejected(host *outlierHost, now time.Time) bool {
+	return now.Before(host.ejectedUntil)
+}
+
+// Admit decides whether a backend takes part in a pick. A backend in slow
+// start is admitted to a share of picks growing linearly from
+// slowStartMinFraction to all over SlowStart; others always are.
+func (d *OutlierDetector) Admit(addr string, now time.Time) bool {
+	host, exists := d.hosts[addr]
+	if !exists || host.ejectedUntil.IsZero() || d.config.SlowStart <= 0 {
+		return true
+	}
+	elapsed := now.Sub(host.ejectedUntil)
+	if elapsed >= d.config.SlowStart {
+		return true
+	}
+	fraction := max(slowStartMinFraction, float64(elapsed)/float64(d.config.SlowStart))
+	return rand.Float64() < fraction
+}
+
+// Sweep runs the periodic analysis once every Interval: it ejects backends
+// that were slow over the interval, reports backends whose ejection has
+// ended and lets the ejection count of the others decay.
+func (d *OutlierDetector) Sweep(now time.Time) []OutlierEvent {
+	if d.config.Interval <= 0 || now.Sub(d.lastSweep) < d.config.Interval {
+		return nil
+	}
+	d.lastSweep = now
+
+	var events []OutlierEvent
+	addrs := make([]string, 0, len(d.hosts))
+	for addr := range d.hosts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		host := d.hosts[addr]
+		if !host.returned && !d.ejected(host, now) {
+			host.returned = true
+			events = append(events, OutlierEvent{Addr: addr})
+		}
+	}
+	events = append(events, d.ejectSlow(addrs, now)...)
+
+	for _, addr := range addrs {
+		host := d.hosts[addr]
+		if !d.ejected(host, now) && host.ejections > 0 && now.Sub(host.ejectedUntil) >= d.config.Interval {
+			host.ejections--
+		}
+		clear(host.latency)
+	}
+	return events
+}
+
+// ejectSlow ejects the backends whose mean latency this interval for a task
+// type is more than LatencyFactor times the median of the others serving it.
+func (d *OutlierDetector) ejectSlow(addrs []string, now time.Time) []OutlierEvent {
+	if d.config.LatencyFactor <= 0 {
+		return nil
+	}
+	var taskTypes []int32
+	for _, host := range d.hosts {
+		for taskType := range host.latency {
+			if !slices.Contains(taskTypes, taskType) {
+				taskTypes = append(taskTypes, taskType)
+			}
+		}
+	}
+	slices.Sort(taskTypes)
+
+	var events []OutlierEvent
+	for _, taskType := range taskTypes {
+		means := make(map[string]time.Duration)
+		for _, addr := range addrs {
+			host := d.hosts[addr]
+			stats, exists := host.latency[taskType]
+			if exists && stats.count > 0 && stats.count >= d.config.MinRequests && !d.ejected(host, now) {
+				means[addr] = stats.sum / time.Duration(stats.count)
+			}
+		}
+		for _, addr := range addrs {
+			mean, judged := means[addr]
+			if !judged || d.ejected(d.hosts[addr], now) {
+				continue
+			}
+			others := make([]time.Duration, 0, len(means)-1)
+			for other, otherMean := range means {
+				if other != addr {
+					others = append(others, otherMean)
+				}
+			}
+			if len(others) == 0 {
+				continue
+			}
+			median := medianDuration(others)
+			if float64(mean) <= d.config.LatencyFactor*float64(median) {
+				continue
+			}
+			reason := fmt.Sprintf("mean latency %v for task type %d is over %g times the others' median %v",
+				mean.Round(time.Millisecond), taskType, d.config.LatencyFactor, median.Round(time.Millisecond))
+			if ev := d.eject(addr, d.hosts[addr], now, reason); ev != nil {
+				events = append(events, *ev)
+			}
+		}
+	}
+	return events
+}
+
+// eject ejects a backend unless MaxEjectionPercent would be exceeded.
+func (d *OutlierDetector) eject(addr string, host *outlierHost, now time.Time, reason string) *OutlierEvent {
+	ejected := 0
+	for _, other := range d.hosts {
+		if d.ejected(other, now) {
+			ejected++
+		}
+	}
+	withinCap := (ejected+1)*100 <= d.config.MaxEjectionPercent*len(d.hosts)
+	if !withinCap && (ejected > 0 || len(d.hosts) < 2) {
+		return nil
+	}
+
+	host.ejections++
+	duration := d.config.BaseEjectionTime * time.Duration(host.ejections)
+	if d.config.MaxEjectionTime > 0 {
+		duration = min(duration, d.config.MaxEjectionTime)
+	}
+	host.ejectedUntil = now.Add(duration)
+	host.returned = false
+	host.consecutiveErrors = 0
+	return &OutlierEvent{Addr: addr, Ejected: true, Reason: reason, For: duration}
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	n := len(durations)
+	if n%2 == 1 {
+		return durations[n/2]
+	}
+	return (durations[n/2-1] + durations[n/2]) / 2
+}
@@ -0,0 +1,218 @@
+package lbpolicy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+func outlierTestConfig() OutlierConfig {
+	return OutlierConfig{
+		ConsecutiveErrors:  3,
+		LatencyFactor:      3,
+		MinRequests:        5,
+		Interval:           10 * time.Second,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionTime:    90 * time.Second,
+		MaxEjectionPercent: 50,
+		SlowStart:          20 * time.Second,
+	}
+}
+
+func outlierTestDetector(n int) (*OutlierDetector, []string) {
+	d := NewOutlierDetector(outlierTestConfig())
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("localhost:%d", 50400+i)
+		d.Add(addrs[i])
+	}
+	return d, addrs
+}
+
+func TestOutlierConsecutiveErrorsEject(t *testing.T) {
+	d, addrs := outlierTestDetector(4)
+	now := time.Unix(1000, 0)
+
+	d.Record(addrs[0], 0, true, 0, now)
+	d.Record(addrs[0], 0, true, 0, now)
+	d.Record(addrs[0], 0, false, time.Millisecond, now) // a success resets the count
+	d.Record(addrs[0], 0, true, 0, now)
+	if ev := d.Record(addrs[0], 0, true, 0, now); ev != nil || d.Ejected(addrs[0], now) {
+		t.Fatalf("ejected after 2 consecutive errors, want 3")
+	}
+	ev := d.Record(addrs[0], 0, true, 0, now)
+	if ev == nil || !ev.Ejected || ev.For != 30*time.Second {
+		t.Fatalf("third consecutive error gave %v, want an ejection for 30s", ev)
+	}
+	if !d.Ejected(addrs[0], now.Add(29*time.Second)) || d.Ejected(addrs[0], now.Add(30*time.Second)) {
+		t.Errorf("ejection should last exactly 30s")
+	}
+}
+
+func TestOutlierResourceExhaustedDoesNotEject(t *testing.T) {
+	d, addrs := outlierTestDetector(4)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 10; i++ {
+		if ev := d.Record(addrs[0], 0, OutlierFailure(codes.ResourceExhausted), 0, now); ev != nil {
+			t.Fatalf("rejection %d by a busy backend gave %v", i+1, ev)
+		}
+	}
+	if d.Ejected(addrs[0], now) {
+		t.Errorf("a backend turning work away was ejected")
+	}
+	if !OutlierFailure(codes.Unavailable) {
+		t.Errorf("Unavailable should count against the backend")
+	}
+}
+
+func TestOutlierEjectionTimeGrowsAndDecays(t *testing.T) {
+	d, addrs := outlierTestDetector(4)
+	now := time.Unix(1000, 0)
+	ejectFor := func() time.Duration {
+		for i := 0; i < 3; i++ {
+			if ev := d.Record(addrs[0], 0, true, 0, now); ev != nil {
+				return ev.For
+			}
+		}
+		t.Fatalf("no ejection after 3 errors")
+		return 0
+	}
+
+	for _, want := range []time.Duration{30 * time.Second, 60 * time.Second, 90 * time.Second, 90 * time.Second} {
+		if got := ejectFor(); got != want {
+			t.Fatalf("ejection time = %v, want %v", got, want)
+		}
+		now = d.EjectedUntil(addrs[0], now)
+	}
+
+	// every interval after the ejection without another one lowers the count
+	for i := 0; i < 4; i++ {
+		now = now.Add(10 * time.Second)
+		d.Sweep(now)
+	}
+	if got := ejectFor(); got != 30*time.Second {
+		t.Errorf("ejection time after decay = %v, want 30s", got)
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	d, addrs := outlierTestDetector(4)
+	now := time.Unix(1000, 0)
+	ejected := 0
+	for _, addr := range addrs {
+		for i := 0; i < 3; i++ {
+			d.Record(addr, 0, true, 0, now)
+		}
+		if d.Ejected(addr, now) {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("%d of 4 backends ejected, want 50%%", ejected)
+	}
+
+	// one backend may be ejected even if that is over the cap, but never
+	// the last one
+	d, addrs = outlierTestDetector(1)
+	for i := 0; i < 3; i++ {
+		d.Record(addrs[0], 0, true, 0, now)
+	}
+	if d.Ejected(addrs[0], now) {
+		t.Errorf("the only backend was ejected")
+	}
+	d, addrs = outlierTestDetector(2)
+	d.config.MaxEjectionPercent = 10
+	for i := 0; i < 3; i++ {
+		d.Record(addrs[1], 0, true, 0, now)
+	}
+	if !d.Ejected(addrs[1], now) {
+		t.Errorf("one of two backends should be ejectable whatever the cap")
+	}
+}
+
+func TestOutlierLatencyEjection(t *testing.T) {
+	d, addrs := outlierTestDetector(3)
+	now := time.Unix(1000, 0)
+	d.Sweep(now)
+	for i := 0; i < 10; i++ {
+		d.Record(addrs[0], 0, false, 10*time.Millisecond, now)
+		d.Record(addrs[1], 0, false, 12*time.Millisecond, now)
+		d.Record(addrs[2], 0, false, 50*time.Millisecond, now)
+	}
+
+	if events := d.Sweep(now.Add(5 * time.Second)); events != nil {
+		t.Fatalf("sweep before the interval ended gave %v", events)
+	}
+	events := d.Sweep(now.Add(10 * time.Second))
+	if len(events) != 1 || events[0].Addr != addrs[2] || !events[0].Ejected {
+		t.Fatalf("sweep gave %v, want %s ejected", events, addrs[2])
+	}
+
+	// too few samples are not judged
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 4; i++ {
+		d.Record(addrs[0], 0, false, 10*time.Millisecond, now)
+		d.Record(addrs[1], 0, false, 100*time.Millisecond, now)
+	}
+	if events := d.Sweep(now.Add(10 * time.Second)); len(events) != 0 {
+		t.Errorf("sweep with 4 samples per backend gave %v, want none", events)
+	}
+}
+
+func TestOutlierLatencyByTaskType(t *testing.T) {
+	d, addrs := outlierTestDetector(3)
+	now := time.Unix(1000, 0)
+	d.Sweep(now)
+	// addrs[2] serves only the heavy task type 2, and is as fast at it as
+	// addrs[1]
+	for i := 0; i < 10; i++ {
+		d.Record(addrs[0], 0, false, time.Millisecond, now)
+		d.Record(addrs[1], 0, false, time.Millisecond, now)
+		d.Record(addrs[1], 2, false, 200*time.Millisecond, now)
+		d.Record(addrs[2], 2, false, 210*time.Millisecond, now)
+	}
+	if events := d.Sweep(now.Add(10 * time.Second)); len(events) != 0 {
+		t.Errorf("sweep gave %v, want no ejection of a backend serving heavier tasks", events)
+	}
+}
+
+func TestOutlierReturnAndSlowStart(t *testing.T) {
+	d, addrs := outlierTestDetector(2)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		d.Record(addrs[0], 0, true, 0, now)
+	}
+	back := d.EjectedUntil(addrs[0], now)
+
+	events := d.Sweep(back)
+	if len(events) != 1 || events[0].Addr != addrs[0] || events[0].Ejected {
+		t.Fatalf("sweep after the ejection gave %v, want %s back", events, addrs[0])
+	}
+
+	admitted := func(at time.Time) float64 {
+		n := 0
+		for i := 0; i < 10000; i++ {
+			if d.Admit(addrs[0], at) {
+				n++
+			}
+		}
+		return float64(n) / 10000
+	}
+	for _, tc := range []struct {
+		after time.Duration
+		want  float64
+	}{
+		{0, slowStartMinFraction},
+		{10 * time.Second, 0.5},
+		{20 * time.Second, 1},
+	} {
+		if got := admitted(back.Add(tc.after)); got < tc.want-0.03 || got > tc.want+0.03 {
+			t.Errorf("%v into slow start admitted %.3f of picks, want about %.2f", tc.after, got, tc.want)
+		}
+	}
+	if !d.Admit(addrs[1], now) {
+		t.Errorf("a backend that was never ejected should always be admitted")
+	}
+}
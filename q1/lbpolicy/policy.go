@@ -0,0 +1,253 @@
+// Package lbpolicy holds the load balancing policies shared by the q1 load
+// balancer and the client-side gRPC balancer.
+package lbpolicy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backend is a policy's view of one backend server.
+type Backend struct {
+	Addr   string
+	Weight int32   // registered relative capacity, at least 1
+	Load   float32 // last reported load
+	Zone   string  // registered locality, empty if unknown
+	Region string
+
+	// InFlight is the number of requests the backend last reported as in
+	// flight plus the requests sent to it since that report.
+	InFlight int32
+	// QueueDepth is the number of requests the backend last reported as
+	// waiting for a worker. A backend with a queue has no idle worker.
+	QueueDepth int32
+	Latency    PeakEWMA
+}
+
+// Policy chooses the backend for a request. Pick is given a non-empty list
+// of candidates in registration order. Policies are not safe for concurrent
+// use; callers serialize Pick with their own lock.
+type Policy interface {
+	Pick(req PickRequest, backends []*Backend) *Backend
+}
+
+// MembershipWatcher is implemented by policies that keep state over every
+// registered backend rather than only the candidates of a pick. Callers pass
+// SetMembers all registered backends when they start using the policy and
+// whenever one joins, leaves or changes weight, under the lock they hold for
+// Pick.
+type MembershipWatcher interface {
+	SetMembers(backends []*Backend)
+}
+
+// PickRequest describes the request a backend is picked for.
+type PickRequest struct {
+	TaskType int32
+	HashKey  uint64 // 0 if the client did not send one
+	Zone     string // the caller's locality, empty if unknown
+	Region   string
+}
+
+// RequestHashKey identifies a task and its argument. Clients send it with
+// LoadBalancerRequest so the CH policy sends repeated requests to the backend
+// that already served them.
+func RequestHashKey(tasktype int32, num int64) uint64 {
+	var buf [12]byte
+	binary.LittleEndian.PutUint32(buf[:4], uint32(tasktype))
+	binary.LittleEndian.PutUint64(buf[4:], uint64(num))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// Names lists the policies New accepts.
+var Names = []string{"PF", "RR", "LL", "P2C", "EWMA", "WRR", "WLL", "CH"}
+
+func New(name string) (Policy, error) {
+	switch name {
+	case "PF": // Pick First Policy
+		return pickFirst{}, nil
+	case "RR": // Round Robin Policy
+		return &roundRobin{}, nil
+	case "LL": // Least Load Policy
+		return leastLoad{}, nil
+	case "P2C": // Power of Two Choices Policy
+		return powerOfTwoChoices{}, nil
+	case "EWMA": // Peak EWMA Latency Policy
+		return peakEWMALatency{}, nil
+	case "WRR": // Weighted Round Robin Policy
+		return &weightedRoundRobin{currentWeights: make(map[string]int64)}, nil
+	case "WLL": // Weighted Least Load Policy
+		return weightedLeastLoad{}, nil
+	case "CH": // Consistent Hash Policy
+		return &consistentHash{}, nil
+	}
+	return nil, fmt.Errorf("invalid load balancing policy %q, use one of %v", name, Names)
+}
+
+type pickFirst struct{}
+
+func (pickFirst) Pick(req PickRequest, backends []*Backend) *Backend {
+	return backends[0]
+}
+
+type roundRobin struct {
+	next int
+}
+
+func (rr *roundRobin) Pick(req PickRequest, backends []*Backend) *Backend {
+	backend := backends[rr.next%len(backends)]
+	rr.next = (rr.next + 1) % len(backends)
+	return backend
+}
+
+// leastLoad picks the backend with the shortest queue, and among those the
+// one with the lowest load. Load only says how busy the workers are; queued
+// requests wait however low the load looks.
+type leastLoad struct{}
+
+func (leastLoad) Pick(req PickRequest, backends []*Backend) *Backend {
+	best := backends[0]
+	for _, backend := range backends {
+		if backend.QueueDepth < best.QueueDepth ||
+			backend.QueueDepth == best.QueueDepth && backend.Load < best.Load {
+			best = backend
+		}
+	}
+	return best
+}
+
+// weightedRoundRobin is nginx's smooth weighted round robin: every pick adds
+// each backend's weight to its current weight, picks the largest and
+// subtracts the total weight from it. Backends with weights 5, 1, 1 are
+// picked a, a, b, a, c, a, a rather than a five times in a row.
+type weightedRoundRobin struct {
+	currentWeights map[string]int64
+}
+
+func (wrr *weightedRoundRobin) Pick(req PickRequest, backends []*Backend) *Backend {
+	if len(wrr.currentWeights) > len(backends) {
+		wrr.prune(backends)
+	}
+	var best *Backend
+	total := int64(0)
+	for _, backend := range backends {
+		wrr.currentWeights[backend.Addr] += int64(backend.Weight)
+		total += int64(backend.Weight)
+		if best == nil || wrr.currentWeights[backend.Addr] > wrr.currentWeights[best.Addr] {
+			best = backend
+		}
+	}
+	wrr.currentWeights[best.Addr] -= total
+	return best
+}
+
+// prune forgets the current weight of servers that have left.
+func (wrr *weightedRoundRobin) prune(backends []*Backend) {
+	present := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		present[backend.Addr] = true
+	}
+	for addr := range wrr.currentWeights {
+		if !present[addr] {
+			delete(wrr.currentWeights, addr)
+		}
+	}
+}
+
+// weightedLeastLoad picks the backend with the lowest load per unit of
+// weight, so a server with weight 2 is as loaded at 80% CPU as a server with
+// weight 1 at 40%. Like leastLoad it compares queue depth first, also per
+// unit of weight.
+type weightedLeastLoad struct{}
+
+func (weightedLeastLoad) Pick(req PickRequest, backends []*Backend) *Backend {
+	best := backends[0]
+	for _, backend := range backends {
+		queue, bestQueue := float32(backend.QueueDepth)/backend.weight(), float32(best.QueueDepth)/best.weight()
+		if queue < bestQueue ||
+			queue == bestQueue && backend.Load/backend.weight() < best.Load/best.weight() {
+			best = backend
+		}
+	}
+	return best
+}
+
+// weight returns the backend's weight, counting a weight below 1 as 1 like
+// the registry does, so a division by it never gives NaN.
+func (b *Backend) weight() float32 {
+	return float32(max(b.Weight, 1))
+}
+
+// powerOfTwoChoices samples two distinct backends at random and picks the one
+// with the lower reported load, breaking ties by requests in flight. Unlike
+// least load it does not send every request to the same server between two
+// load reports.
+type powerOfTwoChoices struct{}
+
+func (powerOfTwoChoices) Pick(req PickRequest, backends []*Backend) *Backend {
+	if len(backends) == 1 {
+		return backends[0]
+	}
+	i := rand.IntN(len(backends))
+	j := rand.IntN(len(backends) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := backends[i], backends[j]
+	if b.Load < a.Load || (b.Load == a.Load && b.InFlight < a.InFlight) {
+		return b
+	}
+	return a
+}
+
+// peakEWMALatency picks the backend with the lowest peak-EWMA latency scaled
+// by its requests in flight. Backends without latency samples cost nothing,
+// so new servers get traffic straight away.
+type peakEWMALatency struct{}
+
+func (peakEWMALatency) Pick(req PickRequest, backends []*Backend) *Backend {
+	best, bestCost := backends[0], math.Inf(1)
+	for _, backend := range backends {
+		cost := backend.Latency.Value() * float64(backend.InFlight+1)
+		if cost < bestCost {
+			best, bestCost = backend, cost
+		}
+	}
+	return best
+}
+
+// peakEWMADecay is the time constant of PeakEWMA.
+const peakEWMADecay = 10 * time.Second
+
+// PeakEWMA is an exponentially weighted moving average of latency that jumps
+// straight to any sample above the average and decays towards lower samples,
+// so a backend that slows down is avoided at once.
+type PeakEWMA struct {
+	value float64 // milliseconds
+	stamp time.Time
+}
+
+func (e *PeakEWMA) Observe(sample float64, now time.Time) {
+	if sample > e.value || e.stamp.IsZero() {
+		e.value = sample
+	} else {
+		w := math.Exp(-now.Sub(e.stamp).Seconds() / peakEWMADecay.Seconds())
+		e.value = e.value*w + sample*(1-w)
+	}
+	e.stamp = now
+}
+
+func (e *PeakEWMA) Value() float64 {
+	return e.value
+}
+
+// Set replaces the average with value as of now, such as one mirrored from
+// another load balancer that already averaged it.
+func (e *PeakEWMA) Set(value float64, now time.Time) {
+	e.value, e.stamp = value, now
+}
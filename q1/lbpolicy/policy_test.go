@@ -0,0 +1,185 @@
+package lbpolicy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// pickCounts picks n times from backends and counts the picks per address.
+func pickCounts(t *testing.T, policy Policy, n int, backends []*Backend) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[policy.Pick(PickRequest{}, backends).Addr]++
+	}
+	return counts
+}
+
+func TestPowerOfTwoChoices(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []*Backend
+		want     string // the only backend picked
+		never    string // a backend never picked, when several are
+	}{
+		{
+			name:     "single backend",
+			backends: []*Backend{{Addr: "a", Load: 0.9}},
+			want:     "a",
+		},
+		{
+			name:     "less loaded of two",
+			backends: []*Backend{{Addr: "a", Load: 0.9}, {Addr: "b", Load: 0.1}},
+			want:     "b",
+		},
+		{
+			name:     "fewer in flight at equal load",
+			backends: []*Backend{{Addr: "a", Load: 0.5, InFlight: 1}, {Addr: "b", Load: 0.5, InFlight: 3}},
+			want:     "a",
+		},
+		{
+			// every sample of two includes a less loaded backend than c
+			name:     "most loaded of three",
+			backends: []*Backend{{Addr: "a", Load: 0.2}, {Addr: "b", Load: 0.3}, {Addr: "c", Load: 0.9}},
+			never:    "c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, _ := New("P2C")
+			counts := pickCounts(t, policy, 100, tt.backends)
+			if tt.want != "" && counts[tt.want] != 100 {
+				t.Errorf("picked %v, want only %s", counts, tt.want)
+			}
+			if tt.never != "" && (counts[tt.never] != 0 || len(counts) < 2) {
+				t.Errorf("picked %v, want every backend but %s", counts, tt.never)
+			}
+		})
+	}
+}
+
+func TestPeakEWMA(t *testing.T) {
+	start := time.Unix(1000, 0)
+	decayed := func(from, to float64, after time.Duration) float64 {
+		w := math.Exp(-after.Seconds() / peakEWMADecay.Seconds())
+		return from*w + to*(1-w)
+	}
+	type sample struct {
+		ms    float64
+		after time.Duration // since start
+	}
+	tests := []struct {
+		name    string
+		samples []sample
+		want    float64
+	}{
+		{"first sample", []sample{{40, 0}}, 40},
+		{"peak taken at once", []sample{{10, 0}, {100, time.Second}}, 100},
+		{"lower sample decays", []sample{{100, 0}, {10, peakEWMADecay}}, decayed(100, 10, peakEWMADecay)},
+		{"decay grows with time", []sample{{100, 0}, {10, 5 * peakEWMADecay}}, decayed(100, 10, 5*peakEWMADecay)},
+		{"lower sample at the same time", []sample{{100, 0}, {10, 0}}, 100},
+		{"peak after decay", []sample{{100, 0}, {10, peakEWMADecay}, {60, 2 * peakEWMADecay}}, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e PeakEWMA
+			for _, s := range tt.samples {
+				e.Observe(s.ms, start.Add(s.after))
+			}
+			if got := e.Value(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeakEWMALatencyPick(t *testing.T) {
+	now := time.Unix(1000, 0)
+	slow, fast, busy := &Backend{Addr: "slow"}, &Backend{Addr: "fast"}, &Backend{Addr: "busy", InFlight: 9}
+	slow.Latency.Observe(100, now)
+	fast.Latency.Observe(20, now)
+	busy.Latency.Observe(20, now)
+	policy, _ := New("EWMA")
+	if got := policy.Pick(PickRequest{}, []*Backend{slow, busy, fast}).Addr; got != "fast" {
+		t.Errorf("picked %s, want fast", got)
+	}
+	// 20ms with 10 requests in flight costs more than 100ms with none
+	if got := policy.Pick(PickRequest{}, []*Backend{busy, slow}).Addr; got != "slow" {
+		t.Errorf("picked %s, want slow", got)
+	}
+	// a backend without samples costs nothing
+	if got := policy.Pick(PickRequest{}, []*Backend{fast, {Addr: "new"}}).Addr; got != "new" {
+		t.Errorf("picked %s, want new", got)
+	}
+}
+
+func TestWeightedRoundRobinSequence(t *testing.T) {
+	backends := []*Backend{{Addr: "a", Weight: 5}, {Addr: "b", Weight: 1}, {Addr: "c", Weight: 1}}
+	policy, _ := New("WRR")
+	var got []string
+	for i := 0; i < 14; i++ {
+		got = append(got, policy.Pick(PickRequest{}, backends).Addr)
+	}
+	// the cycle of 7 repeats once every backend is back to weight 0
+	want := []string{"a", "a", "b", "a", "c", "a", "a", "a", "a", "b", "a", "c", "a", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("picked %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWeightedLeastLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []*Backend
+		want     string
+	}{
+		{
+			name:     "load per unit of weight",
+			backends: []*Backend{{Addr: "a", Weight: 1, Load: 0.4}, {Addr: "b", Weight: 2, Load: 0.6}},
+			want:     "b",
+		},
+		{
+			name:     "equal load per unit of weight keeps the first",
+			backends: []*Backend{{Addr: "a", Weight: 1, Load: 0.4}, {Addr: "b", Weight: 2, Load: 0.8}},
+			want:     "a",
+		},
+		{
+			name:     "queue per unit of weight first",
+			backends: []*Backend{{Addr: "a", Weight: 1, QueueDepth: 1}, {Addr: "b", Weight: 4, QueueDepth: 2, Load: 0.9}},
+			want:     "b",
+		},
+		{
+			name:     "zero weight counts as 1",
+			backends: []*Backend{{Addr: "a", Weight: 0}, {Addr: "b", Weight: 1, Load: 0.1}},
+			want:     "a",
+		},
+		{
+			name:     "zero weight loaded",
+			backends: []*Backend{{Addr: "a", Weight: 0, Load: 0.6}, {Addr: "b", Weight: 2, Load: 0.8}},
+			want:     "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, _ := New("WLL")
+			if got := policy.Pick(PickRequest{}, tt.backends).Addr; got != tt.want {
+				t.Errorf("picked %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedLeastLoadWeightChange(t *testing.T) {
+	a, b := &Backend{Addr: "a", Weight: 1, Load: 0.3}, &Backend{Addr: "b", Weight: 1, Load: 0.5}
+	policy, _ := New("WLL")
+	if got := policy.Pick(PickRequest{}, []*Backend{a, b}).Addr; got != "a" {
+		t.Fatalf("picked %s, want a", got)
+	}
+	b.Weight = 2
+	if got := policy.Pick(PickRequest{}, []*Backend{a, b}).Addr; got != "b" {
+		t.Errorf("picked %s after b's weight doubled, want b", got)
+	}
+}
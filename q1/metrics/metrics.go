@@ -0,0 +1,73 @@
+// Package metrics holds what the q1 load balancer and backend servers share
+// to expose Prometheus metrics: a registry per process, the /metrics HTTP
+// endpoint and a gRPC stats handler that counts active connections.
+package metrics
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/stats"
+)
+
+// NewRegistry returns a registry with the Go runtime and process collectors.
+// The binaries use their own registry rather than the default one, which the
+// embedded etcd fills with its own metrics.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return reg
+}
+
+// Serve serves the metrics of reg on http://addr/metrics in the background
+// and returns the address it listens on, which tells the port when addr
+// ends in :0. Metrics are not essential, so callers log a failure and run
+// without them.
+func Serve(addr string, reg *prometheus.Registry) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// ConnCounter is a gRPC server stats handler that keeps gauge at the number
+// of open client connections.
+type ConnCounter struct {
+	gauge prometheus.Gauge
+}
+
+func NewConnCounter(gauge prometheus.Gauge) *ConnCounter {
+	return &ConnCounter{gauge: gauge}
+}
+
+func (c *ConnCounter) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (c *ConnCounter) HandleConn(ctx context.Context, s stats.ConnStats) {
+	switch s.(type) {
+	case *stats.ConnBegin:
+		c.gauge.Inc()
+	case *stats.ConnEnd:
+		c.gauge.Dec()
+	}
+}
+
+func (c *ConnCounter) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (c *ConnCounter) HandleRPC(ctx context.Context, s stats.RPCStats) {}
@@ -20,9 +20,125 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+// What LoadStatus.load measures
+type LoadMetric int32
+
+const (
+	LoadMetric_CPU         LoadMetric = 0
+	LoadMetric_IN_FLIGHT   LoadMetric = 1
+	LoadMetric_QUEUE_DEPTH LoadMetric = 2
+	LoadMetric_COMPOSITE   LoadMetric = 3
+)
+
+var LoadMetric_name = map[int32]string{
+	0: "CPU",
+	1: "IN_FLIGHT",
+	2: "QUEUE_DEPTH",
+	3: "COMPOSITE",
+}
+
+var LoadMetric_value = map[string]int32{
+	"CPU":         0,
+	"IN_FLIGHT":   1,
+	"QUEUE_DEPTH": 2,
+	"COMPOSITE":   3,
+}
+
+func (x LoadMetric) String() string {
+	return proto.EnumName(LoadMetric_name, int32(x))
+}
+
+func (LoadMetric) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{0}
+}
+
+// The tasks a backend can run, each taking one integer argument
+type TaskType int32
+
+const (
+	TaskType_SUM             TaskType = 0
+	TaskType_NTH_PRIME       TaskType = 1
+	TaskType_FIBONACCI       TaskType = 2
+	TaskType_MATRIX_MULTIPLY TaskType = 3
+	TaskType_PROOF_OF_WORK   TaskType = 4
+	TaskType_SORT            TaskType = 5
+)
+
+var TaskType_name = map[int32]string{
+	0: "SUM",
+	1: "NTH_PRIME",
+	2: "FIBONACCI",
+	3: "MATRIX_MULTIPLY",
+	4: "PROOF_OF_WORK",
+	5: "SORT",
+}
+
+var TaskType_value = map[string]int32{
+	"SUM":             0,
+	"NTH_PRIME":       1,
+	"FIBONACCI":       2,
+	"MATRIX_MULTIPLY": 3,
+	"PROOF_OF_WORK":   4,
+	"SORT":            5,
+}
+
+func (x TaskType) String() string {
+	return proto.EnumName(TaskType_name, int32(x))
+}
+
+func (TaskType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{1}
+}
+
+type TaskState int32
+
+const (
+	TaskState_QUEUED    TaskState = 0
+	TaskState_RUNNING   TaskState = 1
+	TaskState_SUCCEEDED TaskState = 2
+	TaskState_FAILED    TaskState = 3
+	TaskState_CANCELLED TaskState = 4
+)
+
+var TaskState_name = map[int32]string{
+	0: "QUEUED",
+	1: "RUNNING",
+	2: "SUCCEEDED",
+	3: "FAILED",
+	4: "CANCELLED",
+}
+
+var TaskState_value = map[string]int32{
+	"QUEUED":    0,
+	"RUNNING":   1,
+	"SUCCEEDED": 2,
+	"FAILED":    3,
+	"CANCELLED": 4,
+}
+
+func (x TaskState) String() string {
+	return proto.EnumName(TaskState_name, int32(x))
+}
+
+func (TaskState) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{2}
+}
+
 type LoadStatus struct {
-	ServerAddr           string   `protobuf:"bytes,1,opt,name=serverAddr,proto3" json:"serverAddr,omitempty"`
-	Load                 float32  `protobuf:"fixed32,2,opt,name=load,proto3" json:"load,omitempty"`
+	ServerAddr string     `protobuf:"bytes,1,opt,name=serverAddr,proto3" json:"serverAddr,omitempty"`
+	Load       float32    `protobuf:"fixed32,2,opt,name=load,proto3" json:"load,omitempty"`
+	InFlight   int32      `protobuf:"varint,3,opt,name=inFlight,proto3" json:"inFlight,omitempty"`
+	Latency    float32    `protobuf:"fixed32,4,opt,name=latency,proto3" json:"latency,omitempty"`
+	Metric     LoadMetric `protobuf:"varint,5,opt,name=metric,proto3,enum=lbproto.LoadMetric" json:"metric,omitempty"`
+	Seq        uint64     `protobuf:"varint,6,opt,name=seq,proto3" json:"seq,omitempty"`
+	Timestamp  int64      `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	QueueDepth int32      `protobuf:"varint,8,opt,name=queueDepth,proto3" json:"queueDepth,omitempty"`
+	Draining   bool       `protobuf:"varint,9,opt,name=draining,proto3" json:"draining,omitempty"`
+	// In a LoadReport only: whether the backend is drained with lbctl, and
+	// when the load balancer last got a report from it, unix nanoseconds, 0
+	// if never
+	Drained              bool     `protobuf:"varint,10,opt,name=drained,proto3" json:"drained,omitempty"`
+	ReportedAt           int64    `protobuf:"varint,11,opt,name=reportedAt,proto3" json:"reportedAt,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -67,6 +183,69 @@ func (m *LoadStatus) GetLoad() float32 {
 	return 0
 }
 
+func (m *LoadStatus) GetInFlight() int32 {
+	if m != nil {
+		return m.InFlight
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetLatency() float32 {
+	if m != nil {
+		return m.Latency
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetMetric() LoadMetric {
+	if m != nil {
+		return m.Metric
+	}
+	return LoadMetric_CPU
+}
+
+func (m *LoadStatus) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetQueueDepth() int32 {
+	if m != nil {
+		return m.QueueDepth
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
+
+func (m *LoadStatus) GetDrained() bool {
+	if m != nil {
+		return m.Drained
+	}
+	return false
+}
+
+func (m *LoadStatus) GetReportedAt() int64 {
+	if m != nil {
+		return m.ReportedAt
+	}
+	return 0
+}
+
 type Empty struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -99,8 +278,9 @@ func (m *Empty) XXX_DiscardUnknown() {
 var xxx_messageInfo_Empty proto.InternalMessageInfo
 
 type BackendRequest struct {
-	TaskType             int32    `protobuf:"varint,1,opt,name=taskType,proto3" json:"taskType,omitempty"`
+	TaskType             TaskType `protobuf:"varint,1,opt,name=taskType,proto3,enum=lbproto.TaskType" json:"taskType,omitempty"`
 	Num                  int64    `protobuf:"varint,2,opt,name=num,proto3" json:"num,omitempty"`
+	UseDefault           bool     `protobuf:"varint,3,opt,name=useDefault,proto3" json:"useDefault,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -131,11 +311,11 @@ func (m *BackendRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_BackendRequest proto.InternalMessageInfo
 
-func (m *BackendRequest) GetTaskType() int32 {
+func (m *BackendRequest) GetTaskType() TaskType {
 	if m != nil {
 		return m.TaskType
 	}
-	return 0
+	return TaskType_SUM
 }
 
 func (m *BackendRequest) GetNum() int64 {
@@ -145,6 +325,13 @@ func (m *BackendRequest) GetNum() int64 {
 	return 0
 }
 
+func (m *BackendRequest) GetUseDefault() bool {
+	if m != nil {
+		return m.UseDefault
+	}
+	return false
+}
+
 type BackendResponse struct {
 	Output               int64    `protobuf:"varint,1,opt,name=output,proto3" json:"output,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -184,8 +371,225 @@ func (m *BackendResponse) GetOutput() int64 {
 	return 0
 }
 
+type TaskID struct {
+	JobId                string   `protobuf:"bytes,1,opt,name=jobId,proto3" json:"jobId,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TaskID) Reset()         { *m = TaskID{} }
+func (m *TaskID) String() string { return proto.CompactTextString(m) }
+func (*TaskID) ProtoMessage()    {}
+func (*TaskID) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{4}
+}
+
+func (m *TaskID) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskID.Unmarshal(m, b)
+}
+func (m *TaskID) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskID.Marshal(b, m, deterministic)
+}
+func (m *TaskID) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskID.Merge(m, src)
+}
+func (m *TaskID) XXX_Size() int {
+	return xxx_messageInfo_TaskID.Size(m)
+}
+func (m *TaskID) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskID.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskID proto.InternalMessageInfo
+
+func (m *TaskID) GetJobId() string {
+	if m != nil {
+		return m.JobId
+	}
+	return ""
+}
+
+type TaskStatus struct {
+	JobId                string    `protobuf:"bytes,1,opt,name=jobId,proto3" json:"jobId,omitempty"`
+	TaskType             TaskType  `protobuf:"varint,2,opt,name=taskType,proto3,enum=lbproto.TaskType" json:"taskType,omitempty"`
+	Num                  int64     `protobuf:"varint,3,opt,name=num,proto3" json:"num,omitempty"`
+	State                TaskState `protobuf:"varint,4,opt,name=state,proto3,enum=lbproto.TaskState" json:"state,omitempty"`
+	Progress             float32   `protobuf:"fixed32,5,opt,name=progress,proto3" json:"progress,omitempty"`
+	Output               int64     `protobuf:"varint,6,opt,name=output,proto3" json:"output,omitempty"`
+	Error                string    `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	ExpiresAt            int64     `protobuf:"varint,8,opt,name=expiresAt,proto3" json:"expiresAt,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *TaskStatus) Reset()         { *m = TaskStatus{} }
+func (m *TaskStatus) String() string { return proto.CompactTextString(m) }
+func (*TaskStatus) ProtoMessage()    {}
+func (*TaskStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{5}
+}
+
+func (m *TaskStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskStatus.Unmarshal(m, b)
+}
+func (m *TaskStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskStatus.Marshal(b, m, deterministic)
+}
+func (m *TaskStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskStatus.Merge(m, src)
+}
+func (m *TaskStatus) XXX_Size() int {
+	return xxx_messageInfo_TaskStatus.Size(m)
+}
+func (m *TaskStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskStatus proto.InternalMessageInfo
+
+func (m *TaskStatus) GetJobId() string {
+	if m != nil {
+		return m.JobId
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetTaskType() TaskType {
+	if m != nil {
+		return m.TaskType
+	}
+	return TaskType_SUM
+}
+
+func (m *TaskStatus) GetNum() int64 {
+	if m != nil {
+		return m.Num
+	}
+	return 0
+}
+
+func (m *TaskStatus) GetState() TaskState {
+	if m != nil {
+		return m.State
+	}
+	return TaskState_QUEUED
+}
+
+func (m *TaskStatus) GetProgress() float32 {
+	if m != nil {
+		return m.Progress
+	}
+	return 0
+}
+
+func (m *TaskStatus) GetOutput() int64 {
+	if m != nil {
+		return m.Output
+	}
+	return 0
+}
+
+func (m *TaskStatus) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetExpiresAt() int64 {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return 0
+}
+
+type CacheStats struct {
+	Hits                 uint64   `protobuf:"varint,1,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses               uint64   `protobuf:"varint,2,opt,name=misses,proto3" json:"misses,omitempty"`
+	Shared               uint64   `protobuf:"varint,3,opt,name=shared,proto3" json:"shared,omitempty"`
+	Evictions            uint64   `protobuf:"varint,4,opt,name=evictions,proto3" json:"evictions,omitempty"`
+	Entries              int32    `protobuf:"varint,5,opt,name=entries,proto3" json:"entries,omitempty"`
+	Capacity             int32    `protobuf:"varint,6,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CacheStats) Reset()         { *m = CacheStats{} }
+func (m *CacheStats) String() string { return proto.CompactTextString(m) }
+func (*CacheStats) ProtoMessage()    {}
+func (*CacheStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{6}
+}
+
+func (m *CacheStats) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CacheStats.Unmarshal(m, b)
+}
+func (m *CacheStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CacheStats.Marshal(b, m, deterministic)
+}
+func (m *CacheStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CacheStats.Merge(m, src)
+}
+func (m *CacheStats) XXX_Size() int {
+	return xxx_messageInfo_CacheStats.Size(m)
+}
+func (m *CacheStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_CacheStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CacheStats proto.InternalMessageInfo
+
+func (m *CacheStats) GetHits() uint64 {
+	if m != nil {
+		return m.Hits
+	}
+	return 0
+}
+
+func (m *CacheStats) GetMisses() uint64 {
+	if m != nil {
+		return m.Misses
+	}
+	return 0
+}
+
+func (m *CacheStats) GetShared() uint64 {
+	if m != nil {
+		return m.Shared
+	}
+	return 0
+}
+
+func (m *CacheStats) GetEvictions() uint64 {
+	if m != nil {
+		return m.Evictions
+	}
+	return 0
+}
+
+func (m *CacheStats) GetEntries() int32 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
+func (m *CacheStats) GetCapacity() int32 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
 type LoadBalancerRequest struct {
-	TaskType             int32    `protobuf:"varint,1,opt,name=taskType,proto3" json:"taskType,omitempty"`
+	TaskType             TaskType `protobuf:"varint,1,opt,name=taskType,proto3,enum=lbproto.TaskType" json:"taskType,omitempty"`
+	HashKey              uint64   `protobuf:"varint,2,opt,name=hashKey,proto3" json:"hashKey,omitempty"`
+	Exclude              []string `protobuf:"bytes,3,rep,name=exclude,proto3" json:"exclude,omitempty"`
+	Zone                 string   `protobuf:"bytes,4,opt,name=zone,proto3" json:"zone,omitempty"`
+	Region               string   `protobuf:"bytes,5,opt,name=region,proto3" json:"region,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -195,7 +599,7 @@ func (m *LoadBalancerRequest) Reset()         { *m = LoadBalancerRequest{} }
 func (m *LoadBalancerRequest) String() string { return proto.CompactTextString(m) }
 func (*LoadBalancerRequest) ProtoMessage()    {}
 func (*LoadBalancerRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e21e8d2be603a5c0, []int{4}
+	return fileDescriptor_e21e8d2be603a5c0, []int{7}
 }
 
 func (m *LoadBalancerRequest) XXX_Unmarshal(b []byte) error {
@@ -216,15 +620,44 @@ func (m *LoadBalancerRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_LoadBalancerRequest proto.InternalMessageInfo
 
-func (m *LoadBalancerRequest) GetTaskType() int32 {
+func (m *LoadBalancerRequest) GetTaskType() TaskType {
 	if m != nil {
 		return m.TaskType
 	}
+	return TaskType_SUM
+}
+
+func (m *LoadBalancerRequest) GetHashKey() uint64 {
+	if m != nil {
+		return m.HashKey
+	}
 	return 0
 }
 
+func (m *LoadBalancerRequest) GetExclude() []string {
+	if m != nil {
+		return m.Exclude
+	}
+	return nil
+}
+
+func (m *LoadBalancerRequest) GetZone() string {
+	if m != nil {
+		return m.Zone
+	}
+	return ""
+}
+
+func (m *LoadBalancerRequest) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
 type LoadBalancerResponse struct {
 	BestServer           string   `protobuf:"bytes,1,opt,name=bestServer,proto3" json:"bestServer,omitempty"`
+	PickToken            uint64   `protobuf:"varint,2,opt,name=pickToken,proto3" json:"pickToken,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -234,7 +667,7 @@ func (m *LoadBalancerResponse) Reset()         { *m = LoadBalancerResponse{} }
 func (m *LoadBalancerResponse) String() string { return proto.CompactTextString(m) }
 func (*LoadBalancerResponse) ProtoMessage()    {}
 func (*LoadBalancerResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e21e8d2be603a5c0, []int{5}
+	return fileDescriptor_e21e8d2be603a5c0, []int{8}
 }
 
 func (m *LoadBalancerResponse) XXX_Unmarshal(b []byte) error {
@@ -262,13 +695,732 @@ func (m *LoadBalancerResponse) GetBestServer() string {
 	return ""
 }
 
+func (m *LoadBalancerResponse) GetPickToken() uint64 {
+	if m != nil {
+		return m.PickToken
+	}
+	return 0
+}
+
+type SubscribeLoadRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeLoadRequest) Reset()         { *m = SubscribeLoadRequest{} }
+func (m *SubscribeLoadRequest) String() string { return proto.CompactTextString(m) }
+func (*SubscribeLoadRequest) ProtoMessage()    {}
+func (*SubscribeLoadRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{9}
+}
+
+func (m *SubscribeLoadRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeLoadRequest.Unmarshal(m, b)
+}
+func (m *SubscribeLoadRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeLoadRequest.Marshal(b, m, deterministic)
+}
+func (m *SubscribeLoadRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeLoadRequest.Merge(m, src)
+}
+func (m *SubscribeLoadRequest) XXX_Size() int {
+	return xxx_messageInfo_SubscribeLoadRequest.Size(m)
+}
+func (m *SubscribeLoadRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeLoadRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeLoadRequest proto.InternalMessageInfo
+
+type CallOutcome struct {
+	ServerAddr           string   `protobuf:"bytes,1,opt,name=serverAddr,proto3" json:"serverAddr,omitempty"`
+	Code                 int32    `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Latency              float32  `protobuf:"fixed32,3,opt,name=latency,proto3" json:"latency,omitempty"`
+	TaskType             TaskType `protobuf:"varint,4,opt,name=taskType,proto3,enum=lbproto.TaskType" json:"taskType,omitempty"`
+	PickToken            uint64   `protobuf:"varint,5,opt,name=pickToken,proto3" json:"pickToken,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CallOutcome) Reset()         { *m = CallOutcome{} }
+func (m *CallOutcome) String() string { return proto.CompactTextString(m) }
+func (*CallOutcome) ProtoMessage()    {}
+func (*CallOutcome) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{10}
+}
+
+func (m *CallOutcome) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CallOutcome.Unmarshal(m, b)
+}
+func (m *CallOutcome) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CallOutcome.Marshal(b, m, deterministic)
+}
+func (m *CallOutcome) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CallOutcome.Merge(m, src)
+}
+func (m *CallOutcome) XXX_Size() int {
+	return xxx_messageInfo_CallOutcome.Size(m)
+}
+func (m *CallOutcome) XXX_DiscardUnknown() {
+	xxx_messageInfo_CallOutcome.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CallOutcome proto.InternalMessageInfo
+
+func (m *CallOutcome) GetServerAddr() string {
+	if m != nil {
+		return m.ServerAddr
+	}
+	return ""
+}
+
+func (m *CallOutcome) GetCode() int32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *CallOutcome) GetLatency() float32 {
+	if m != nil {
+		return m.Latency
+	}
+	return 0
+}
+
+func (m *CallOutcome) GetTaskType() TaskType {
+	if m != nil {
+		return m.TaskType
+	}
+	return TaskType_SUM
+}
+
+func (m *CallOutcome) GetPickToken() uint64 {
+	if m != nil {
+		return m.PickToken
+	}
+	return 0
+}
+
+type OutcomeReport struct {
+	Outcomes             []*CallOutcome `protobuf:"bytes,1,rep,name=outcomes,proto3" json:"outcomes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *OutcomeReport) Reset()         { *m = OutcomeReport{} }
+func (m *OutcomeReport) String() string { return proto.CompactTextString(m) }
+func (*OutcomeReport) ProtoMessage()    {}
+func (*OutcomeReport) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{11}
+}
+
+func (m *OutcomeReport) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OutcomeReport.Unmarshal(m, b)
+}
+func (m *OutcomeReport) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OutcomeReport.Marshal(b, m, deterministic)
+}
+func (m *OutcomeReport) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OutcomeReport.Merge(m, src)
+}
+func (m *OutcomeReport) XXX_Size() int {
+	return xxx_messageInfo_OutcomeReport.Size(m)
+}
+func (m *OutcomeReport) XXX_DiscardUnknown() {
+	xxx_messageInfo_OutcomeReport.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OutcomeReport proto.InternalMessageInfo
+
+func (m *OutcomeReport) GetOutcomes() []*CallOutcome {
+	if m != nil {
+		return m.Outcomes
+	}
+	return nil
+}
+
+type LoadReport struct {
+	// latency is the load balancer's peak-EWMA latency of the backend
+	Backends             []*LoadStatus `protobuf:"bytes,1,rep,name=backends,proto3" json:"backends,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *LoadReport) Reset()         { *m = LoadReport{} }
+func (m *LoadReport) String() string { return proto.CompactTextString(m) }
+func (*LoadReport) ProtoMessage()    {}
+func (*LoadReport) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{12}
+}
+
+func (m *LoadReport) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoadReport.Unmarshal(m, b)
+}
+func (m *LoadReport) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoadReport.Marshal(b, m, deterministic)
+}
+func (m *LoadReport) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoadReport.Merge(m, src)
+}
+func (m *LoadReport) XXX_Size() int {
+	return xxx_messageInfo_LoadReport.Size(m)
+}
+func (m *LoadReport) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoadReport.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoadReport proto.InternalMessageInfo
+
+func (m *LoadReport) GetBackends() []*LoadStatus {
+	if m != nil {
+		return m.Backends
+	}
+	return nil
+}
+
+type SetPolicyRequest struct {
+	Policy               string   `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetPolicyRequest) Reset()         { *m = SetPolicyRequest{} }
+func (m *SetPolicyRequest) String() string { return proto.CompactTextString(m) }
+func (*SetPolicyRequest) ProtoMessage()    {}
+func (*SetPolicyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{13}
+}
+
+func (m *SetPolicyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetPolicyRequest.Unmarshal(m, b)
+}
+func (m *SetPolicyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetPolicyRequest.Marshal(b, m, deterministic)
+}
+func (m *SetPolicyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetPolicyRequest.Merge(m, src)
+}
+func (m *SetPolicyRequest) XXX_Size() int {
+	return xxx_messageInfo_SetPolicyRequest.Size(m)
+}
+func (m *SetPolicyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetPolicyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetPolicyRequest proto.InternalMessageInfo
+
+func (m *SetPolicyRequest) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+type SetPolicyResponse struct {
+	Previous             string   `protobuf:"bytes,1,opt,name=previous,proto3" json:"previous,omitempty"`
+	Policy               string   `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetPolicyResponse) Reset()         { *m = SetPolicyResponse{} }
+func (m *SetPolicyResponse) String() string { return proto.CompactTextString(m) }
+func (*SetPolicyResponse) ProtoMessage()    {}
+func (*SetPolicyResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{14}
+}
+
+func (m *SetPolicyResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetPolicyResponse.Unmarshal(m, b)
+}
+func (m *SetPolicyResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetPolicyResponse.Marshal(b, m, deterministic)
+}
+func (m *SetPolicyResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetPolicyResponse.Merge(m, src)
+}
+func (m *SetPolicyResponse) XXX_Size() int {
+	return xxx_messageInfo_SetPolicyResponse.Size(m)
+}
+func (m *SetPolicyResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetPolicyResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetPolicyResponse proto.InternalMessageInfo
+
+func (m *SetPolicyResponse) GetPrevious() string {
+	if m != nil {
+		return m.Previous
+	}
+	return ""
+}
+
+func (m *SetPolicyResponse) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+type DrainBackendRequest struct {
+	Addr                 string   `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Resume               bool     `protobuf:"varint,2,opt,name=resume,proto3" json:"resume,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DrainBackendRequest) Reset()         { *m = DrainBackendRequest{} }
+func (m *DrainBackendRequest) String() string { return proto.CompactTextString(m) }
+func (*DrainBackendRequest) ProtoMessage()    {}
+func (*DrainBackendRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{15}
+}
+
+func (m *DrainBackendRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DrainBackendRequest.Unmarshal(m, b)
+}
+func (m *DrainBackendRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DrainBackendRequest.Marshal(b, m, deterministic)
+}
+func (m *DrainBackendRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DrainBackendRequest.Merge(m, src)
+}
+func (m *DrainBackendRequest) XXX_Size() int {
+	return xxx_messageInfo_DrainBackendRequest.Size(m)
+}
+func (m *DrainBackendRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DrainBackendRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DrainBackendRequest proto.InternalMessageInfo
+
+func (m *DrainBackendRequest) GetAddr() string {
+	if m != nil {
+		return m.Addr
+	}
+	return ""
+}
+
+func (m *DrainBackendRequest) GetResume() bool {
+	if m != nil {
+		return m.Resume
+	}
+	return false
+}
+
+// The load balancer's view of a backend
+type BackendInfo struct {
+	Addr                 string     `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Weight               int32      `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	Tasks                string     `protobuf:"bytes,3,opt,name=tasks,proto3" json:"tasks,omitempty"`
+	Load                 float32    `protobuf:"fixed32,4,opt,name=load,proto3" json:"load,omitempty"`
+	Metric               LoadMetric `protobuf:"varint,5,opt,name=metric,proto3,enum=lbproto.LoadMetric" json:"metric,omitempty"`
+	InFlight             int32      `protobuf:"varint,6,opt,name=inFlight,proto3" json:"inFlight,omitempty"`
+	QueueDepth           int32      `protobuf:"varint,7,opt,name=queueDepth,proto3" json:"queueDepth,omitempty"`
+	Latency              float32    `protobuf:"fixed32,8,opt,name=latency,proto3" json:"latency,omitempty"`
+	LastReport           int64      `protobuf:"varint,9,opt,name=lastReport,proto3" json:"lastReport,omitempty"`
+	LoadStale            bool       `protobuf:"varint,10,opt,name=loadStale,proto3" json:"loadStale,omitempty"`
+	Healthy              bool       `protobuf:"varint,11,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	FailedChecks         int32      `protobuf:"varint,12,opt,name=failedChecks,proto3" json:"failedChecks,omitempty"`
+	Draining             bool       `protobuf:"varint,13,opt,name=draining,proto3" json:"draining,omitempty"`
+	Picks                uint64     `protobuf:"varint,14,opt,name=picks,proto3" json:"picks,omitempty"`
+	Errors               uint64     `protobuf:"varint,15,opt,name=errors,proto3" json:"errors,omitempty"`
+	ShuttingDown         bool       `protobuf:"varint,16,opt,name=shuttingDown,proto3" json:"shuttingDown,omitempty"`
+	EjectedUntil         int64      `protobuf:"varint,17,opt,name=ejectedUntil,proto3" json:"ejectedUntil,omitempty"`
+	Zone                 string     `protobuf:"bytes,18,opt,name=zone,proto3" json:"zone,omitempty"`
+	Region               string     `protobuf:"bytes,19,opt,name=region,proto3" json:"region,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *BackendInfo) Reset()         { *m = BackendInfo{} }
+func (m *BackendInfo) String() string { return proto.CompactTextString(m) }
+func (*BackendInfo) ProtoMessage()    {}
+func (*BackendInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{16}
+}
+
+func (m *BackendInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BackendInfo.Unmarshal(m, b)
+}
+func (m *BackendInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BackendInfo.Marshal(b, m, deterministic)
+}
+func (m *BackendInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BackendInfo.Merge(m, src)
+}
+func (m *BackendInfo) XXX_Size() int {
+	return xxx_messageInfo_BackendInfo.Size(m)
+}
+func (m *BackendInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_BackendInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BackendInfo proto.InternalMessageInfo
+
+func (m *BackendInfo) GetAddr() string {
+	if m != nil {
+		return m.Addr
+	}
+	return ""
+}
+
+func (m *BackendInfo) GetWeight() int32 {
+	if m != nil {
+		return m.Weight
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetTasks() string {
+	if m != nil {
+		return m.Tasks
+	}
+	return ""
+}
+
+func (m *BackendInfo) GetLoad() float32 {
+	if m != nil {
+		return m.Load
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetMetric() LoadMetric {
+	if m != nil {
+		return m.Metric
+	}
+	return LoadMetric_CPU
+}
+
+func (m *BackendInfo) GetInFlight() int32 {
+	if m != nil {
+		return m.InFlight
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetQueueDepth() int32 {
+	if m != nil {
+		return m.QueueDepth
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetLatency() float32 {
+	if m != nil {
+		return m.Latency
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetLastReport() int64 {
+	if m != nil {
+		return m.LastReport
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetLoadStale() bool {
+	if m != nil {
+		return m.LoadStale
+	}
+	return false
+}
+
+func (m *BackendInfo) GetHealthy() bool {
+	if m != nil {
+		return m.Healthy
+	}
+	return false
+}
+
+func (m *BackendInfo) GetFailedChecks() int32 {
+	if m != nil {
+		return m.FailedChecks
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
+
+func (m *BackendInfo) GetPicks() uint64 {
+	if m != nil {
+		return m.Picks
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetErrors() uint64 {
+	if m != nil {
+		return m.Errors
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetShuttingDown() bool {
+	if m != nil {
+		return m.ShuttingDown
+	}
+	return false
+}
+
+func (m *BackendInfo) GetEjectedUntil() int64 {
+	if m != nil {
+		return m.EjectedUntil
+	}
+	return 0
+}
+
+func (m *BackendInfo) GetZone() string {
+	if m != nil {
+		return m.Zone
+	}
+	return ""
+}
+
+func (m *BackendInfo) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+type BackendList struct {
+	Policy               string         `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+	Backends             []*BackendInfo `protobuf:"bytes,2,rep,name=backends,proto3" json:"backends,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *BackendList) Reset()         { *m = BackendList{} }
+func (m *BackendList) String() string { return proto.CompactTextString(m) }
+func (*BackendList) ProtoMessage()    {}
+func (*BackendList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{17}
+}
+
+func (m *BackendList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BackendList.Unmarshal(m, b)
+}
+func (m *BackendList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BackendList.Marshal(b, m, deterministic)
+}
+func (m *BackendList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BackendList.Merge(m, src)
+}
+func (m *BackendList) XXX_Size() int {
+	return xxx_messageInfo_BackendList.Size(m)
+}
+func (m *BackendList) XXX_DiscardUnknown() {
+	xxx_messageInfo_BackendList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BackendList proto.InternalMessageInfo
+
+func (m *BackendList) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+func (m *BackendList) GetBackends() []*BackendInfo {
+	if m != nil {
+		return m.Backends
+	}
+	return nil
+}
+
+type LoadBalancerStats struct {
+	Policy               string   `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+	Leader               bool     `protobuf:"varint,2,opt,name=leader,proto3" json:"leader,omitempty"`
+	StartedAt            int64    `protobuf:"varint,3,opt,name=startedAt,proto3" json:"startedAt,omitempty"`
+	Picks                uint64   `protobuf:"varint,4,opt,name=picks,proto3" json:"picks,omitempty"`
+	PickErrors           uint64   `protobuf:"varint,5,opt,name=pickErrors,proto3" json:"pickErrors,omitempty"`
+	Proxied              uint64   `protobuf:"varint,6,opt,name=proxied,proto3" json:"proxied,omitempty"`
+	ProxyErrors          uint64   `protobuf:"varint,7,opt,name=proxyErrors,proto3" json:"proxyErrors,omitempty"`
+	PolicyChanges        uint64   `protobuf:"varint,8,opt,name=policyChanges,proto3" json:"policyChanges,omitempty"`
+	Backends             int32    `protobuf:"varint,9,opt,name=backends,proto3" json:"backends,omitempty"`
+	Healthy              int32    `protobuf:"varint,10,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Draining             int32    `protobuf:"varint,11,opt,name=draining,proto3" json:"draining,omitempty"`
+	Ejected              int32    `protobuf:"varint,12,opt,name=ejected,proto3" json:"ejected,omitempty"`
+	Ejections            uint64   `protobuf:"varint,13,opt,name=ejections,proto3" json:"ejections,omitempty"`
+	Outcomes             uint64   `protobuf:"varint,14,opt,name=outcomes,proto3" json:"outcomes,omitempty"`
+	Spillovers           uint64   `protobuf:"varint,15,opt,name=spillovers,proto3" json:"spillovers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoadBalancerStats) Reset()         { *m = LoadBalancerStats{} }
+func (m *LoadBalancerStats) String() string { return proto.CompactTextString(m) }
+func (*LoadBalancerStats) ProtoMessage()    {}
+func (*LoadBalancerStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e21e8d2be603a5c0, []int{18}
+}
+
+func (m *LoadBalancerStats) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoadBalancerStats.Unmarshal(m, b)
+}
+func (m *LoadBalancerStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoadBalancerStats.Marshal(b, m, deterministic)
+}
+func (m *LoadBalancerStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoadBalancerStats.Merge(m, src)
+}
+func (m *LoadBalancerStats) XXX_Size() int {
+	return xxx_messageInfo_LoadBalancerStats.Size(m)
+}
+func (m *LoadBalancerStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoadBalancerStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoadBalancerStats proto.InternalMessageInfo
+
+func (m *LoadBalancerStats) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+func (m *LoadBalancerStats) GetLeader() bool {
+	if m != nil {
+		return m.Leader
+	}
+	return false
+}
+
+func (m *LoadBalancerStats) GetStartedAt() int64 {
+	if m != nil {
+		return m.StartedAt
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetPicks() uint64 {
+	if m != nil {
+		return m.Picks
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetPickErrors() uint64 {
+	if m != nil {
+		return m.PickErrors
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetProxied() uint64 {
+	if m != nil {
+		return m.Proxied
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetProxyErrors() uint64 {
+	if m != nil {
+		return m.ProxyErrors
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetPolicyChanges() uint64 {
+	if m != nil {
+		return m.PolicyChanges
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetBackends() int32 {
+	if m != nil {
+		return m.Backends
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetHealthy() int32 {
+	if m != nil {
+		return m.Healthy
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetDraining() int32 {
+	if m != nil {
+		return m.Draining
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetEjected() int32 {
+	if m != nil {
+		return m.Ejected
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetEjections() uint64 {
+	if m != nil {
+		return m.Ejections
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetOutcomes() uint64 {
+	if m != nil {
+		return m.Outcomes
+	}
+	return 0
+}
+
+func (m *LoadBalancerStats) GetSpillovers() uint64 {
+	if m != nil {
+		return m.Spillovers
+	}
+	return 0
+}
+
 func init() {
+	proto.RegisterEnum("lbproto.LoadMetric", LoadMetric_name, LoadMetric_value)
+	proto.RegisterEnum("lbproto.TaskType", TaskType_name, TaskType_value)
+	proto.RegisterEnum("lbproto.TaskState", TaskState_name, TaskState_value)
 	proto.RegisterType((*LoadStatus)(nil), "lbproto.LoadStatus")
 	proto.RegisterType((*Empty)(nil), "lbproto.Empty")
 	proto.RegisterType((*BackendRequest)(nil), "lbproto.BackendRequest")
 	proto.RegisterType((*BackendResponse)(nil), "lbproto.BackendResponse")
+	proto.RegisterType((*TaskID)(nil), "lbproto.TaskID")
+	proto.RegisterType((*TaskStatus)(nil), "lbproto.TaskStatus")
+	proto.RegisterType((*CacheStats)(nil), "lbproto.CacheStats")
 	proto.RegisterType((*LoadBalancerRequest)(nil), "lbproto.LoadBalancerRequest")
 	proto.RegisterType((*LoadBalancerResponse)(nil), "lbproto.LoadBalancerResponse")
+	proto.RegisterType((*SubscribeLoadRequest)(nil), "lbproto.SubscribeLoadRequest")
+	proto.RegisterType((*CallOutcome)(nil), "lbproto.CallOutcome")
+	proto.RegisterType((*OutcomeReport)(nil), "lbproto.OutcomeReport")
+	proto.RegisterType((*LoadReport)(nil), "lbproto.LoadReport")
+	proto.RegisterType((*SetPolicyRequest)(nil), "lbproto.SetPolicyRequest")
+	proto.RegisterType((*SetPolicyResponse)(nil), "lbproto.SetPolicyResponse")
+	proto.RegisterType((*DrainBackendRequest)(nil), "lbproto.DrainBackendRequest")
+	proto.RegisterType((*BackendInfo)(nil), "lbproto.BackendInfo")
+	proto.RegisterType((*BackendList)(nil), "lbproto.BackendList")
+	proto.RegisterType((*LoadBalancerStats)(nil), "lbproto.LoadBalancerStats")
 }
 
 func init() {
@@ -276,26 +1428,107 @@ func init() {
 }
 
 var fileDescriptor_e21e8d2be603a5c0 = []byte{
-	// 329 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x52, 0xcd, 0x4f, 0xfa, 0x30,
-	0x18, 0x0e, 0xf0, 0x03, 0x7e, 0xbe, 0x09, 0xa0, 0xc5, 0x28, 0x59, 0x14, 0xc9, 0x4e, 0x78, 0x81,
-	0x80, 0xc6, 0xa3, 0x1f, 0x10, 0x0f, 0x26, 0xc6, 0x60, 0xf1, 0xe4, 0xc5, 0x74, 0xec, 0x85, 0x2c,
-	0x8c, 0xb6, 0xb4, 0x9d, 0x09, 0xff, 0xbd, 0x59, 0x19, 0x1b, 0x8b, 0x9a, 0x78, 0x7b, 0xfa, 0xbc,
-	0x79, 0x9f, 0x8f, 0xb6, 0x70, 0x21, 0x95, 0x30, 0x62, 0x1e, 0x84, 0xa8, 0xfb, 0xa1, 0x60, 0xfe,
-	0x87, 0xc7, 0x42, 0xc6, 0x67, 0x01, 0x5f, 0xf4, 0xec, 0x84, 0x54, 0x43, 0xcf, 0x02, 0xf7, 0x1e,
-	0xe0, 0x59, 0x30, 0x7f, 0x6a, 0x98, 0x89, 0x34, 0x69, 0x03, 0x68, 0x54, 0x9f, 0xa8, 0x1e, 0x7c,
-	0x5f, 0xb5, 0x0a, 0x9d, 0x42, 0xf7, 0x80, 0xee, 0x31, 0x84, 0xc0, 0xbf, 0x58, 0xae, 0x55, 0xec,
-	0x14, 0xba, 0x45, 0x6a, 0xb1, 0x5b, 0x85, 0xf2, 0xe3, 0x4a, 0x9a, 0x8d, 0x7b, 0x0b, 0xf5, 0x11,
-	0x9b, 0x2d, 0x91, 0xfb, 0x14, 0xd7, 0x11, 0x6a, 0x43, 0x1c, 0xf8, 0x6f, 0x98, 0x5e, 0xbe, 0x6d,
-	0x24, 0x5a, 0xb1, 0x32, 0x4d, 0xcf, 0xe4, 0x10, 0x4a, 0x3c, 0x5a, 0x59, 0xa5, 0x12, 0x8d, 0xa1,
-	0x7b, 0x09, 0x8d, 0x74, 0x5f, 0x4b, 0xc1, 0x35, 0x92, 0x13, 0xa8, 0x88, 0xc8, 0xc8, 0xc8, 0xd8,
-	0xf5, 0x12, 0x4d, 0x4e, 0xee, 0x00, 0x9a, 0x71, 0xea, 0x91, 0x6d, 0x85, 0xea, 0x0f, 0x7e, 0xee,
-	0x0d, 0x1c, 0xe7, 0x57, 0x12, 0x8b, 0x36, 0x80, 0x87, 0xda, 0x4c, 0x6d, 0xc9, 0x5d, 0xe5, 0x8c,
-	0x19, 0xbe, 0xa6, 0xad, 0x62, 0x22, 0x98, 0x21, 0xb9, 0x03, 0xd8, 0xe5, 0x9c, 0x8c, 0xc9, 0x69,
-	0x2f, 0xb9, 0xca, 0x5e, 0xbe, 0xbc, 0xd3, 0xfa, 0x3e, 0xd8, 0x5a, 0x0e, 0xe7, 0xfb, 0x51, 0x02,
-	0xbe, 0xd8, 0x09, 0xbf, 0x40, 0x23, 0x17, 0x71, 0x32, 0x26, 0x67, 0xa9, 0xc8, 0x0f, 0x7d, 0x9d,
-	0xf3, 0x5f, 0xa6, 0x89, 0xcf, 0x13, 0x1c, 0x51, 0x94, 0x42, 0x19, 0xfb, 0xc2, 0x89, 0xc9, 0x35,
-	0xd4, 0x32, 0x32, 0xb6, 0x68, 0xe6, 0x44, 0xb6, 0x1f, 0xc1, 0xa9, 0xa7, 0xa4, 0x7d, 0xdb, 0x51,
-	0xe3, 0xbd, 0xb6, 0x1e, 0xf4, 0xb3, 0x5f, 0xe5, 0x55, 0x2c, 0xbe, 0xfa, 0x0a, 0x00, 0x00, 0xff,
-	0xff, 0x0b, 0x64, 0xb9, 0x22, 0x6a, 0x02, 0x00, 0x00,
+	// 1631 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0xcd, 0x72, 0xe3, 0x4a,
+	0x15, 0xbe, 0xb2, 0xe4, 0xbf, 0x93, 0x38, 0x51, 0x3a, 0xa9, 0x20, 0x5c, 0x97, 0xe0, 0x72, 0xb1,
+	0x30, 0x43, 0x31, 0x33, 0xe4, 0x0e, 0x50, 0x50, 0x45, 0x51, 0x8e, 0xed, 0x64, 0x5c, 0x37, 0xb1,
+	0x4d, 0xdb, 0xae, 0x01, 0x36, 0x29, 0x59, 0xea, 0xb1, 0x35, 0x91, 0x25, 0x47, 0xdd, 0x9a, 0x3b,
+	0x66, 0x3d, 0x0f, 0xc2, 0x82, 0x05, 0x6f, 0xc1, 0x9a, 0xe7, 0xa0, 0x8a, 0x37, 0x60, 0x4f, 0xf5,
+	0x8f, 0xfe, 0x3c, 0x4e, 0x11, 0xee, 0xae, 0xbf, 0xa3, 0xd3, 0xdd, 0xe7, 0xe7, 0xeb, 0x73, 0x8e,
+	0xe0, 0xc7, 0x9b, 0x28, 0x64, 0xe1, 0x7b, 0xcf, 0x27, 0xf4, 0x95, 0x1f, 0xda, 0xee, 0xfd, 0xc2,
+	0xf6, 0xed, 0xc0, 0xf1, 0x82, 0xe5, 0x4b, 0xf1, 0x05, 0x55, 0xfd, 0x85, 0x58, 0xb4, 0xff, 0x59,
+	0x02, 0xb8, 0x0d, 0x6d, 0x77, 0xca, 0x6c, 0x16, 0x53, 0x74, 0x01, 0x40, 0x49, 0xf4, 0x91, 0x44,
+	0x5d, 0xd7, 0x8d, 0x2c, 0xad, 0xa5, 0x75, 0xea, 0x38, 0x27, 0x41, 0x08, 0x0c, 0x7e, 0x9e, 0x55,
+	0x6a, 0x69, 0x9d, 0x12, 0x16, 0x6b, 0xd4, 0x84, 0x9a, 0x17, 0x5c, 0xfb, 0xde, 0x72, 0xc5, 0x2c,
+	0xbd, 0xa5, 0x75, 0xca, 0x38, 0xc5, 0xc8, 0x82, 0xaa, 0x6f, 0x33, 0x12, 0x38, 0x5b, 0xcb, 0x10,
+	0x5b, 0x12, 0x88, 0x7e, 0x06, 0x95, 0x35, 0x61, 0x91, 0xe7, 0x58, 0xe5, 0x96, 0xd6, 0x39, 0xba,
+	0x3c, 0x7d, 0xa9, 0x4c, 0x7a, 0xc9, 0xcd, 0xb9, 0x13, 0x9f, 0xb0, 0x52, 0x41, 0x26, 0xe8, 0x94,
+	0x3c, 0x5a, 0x95, 0x96, 0xd6, 0x31, 0x30, 0x5f, 0xa2, 0xaf, 0xa1, 0xce, 0xbc, 0x35, 0xa1, 0xcc,
+	0x5e, 0x6f, 0xac, 0x6a, 0x4b, 0xeb, 0xe8, 0x38, 0x13, 0x70, 0x37, 0x1e, 0x63, 0x12, 0x93, 0x3e,
+	0xd9, 0xb0, 0x95, 0x55, 0x13, 0x46, 0xe5, 0x24, 0xdc, 0x64, 0x37, 0xb2, 0xbd, 0xc0, 0x0b, 0x96,
+	0x56, 0xbd, 0xa5, 0x75, 0x6a, 0x38, 0xc5, 0xdc, 0x64, 0xb1, 0x26, 0xae, 0x05, 0xe2, 0x53, 0x02,
+	0xf9, 0xa9, 0x11, 0xd9, 0x84, 0x11, 0x23, 0x6e, 0x97, 0x59, 0x07, 0xe2, 0xd2, 0x9c, 0xa4, 0x5d,
+	0x85, 0xf2, 0x60, 0xbd, 0x61, 0xdb, 0xf6, 0x23, 0x1c, 0x5d, 0xd9, 0xce, 0x03, 0x09, 0x5c, 0x4c,
+	0x1e, 0x63, 0x42, 0x19, 0xfa, 0x39, 0xd4, 0x98, 0x4d, 0x1f, 0x66, 0xdb, 0x0d, 0x11, 0x51, 0x3d,
+	0xba, 0x3c, 0x49, 0xfd, 0x9d, 0xa9, 0x0f, 0x38, 0x55, 0xe1, 0xfe, 0x06, 0xf1, 0x5a, 0x44, 0x59,
+	0xc7, 0x7c, 0xc9, 0xef, 0x8e, 0x29, 0xe9, 0x93, 0xf7, 0x76, 0xec, 0xcb, 0x30, 0xd7, 0x70, 0x4e,
+	0xd2, 0xfe, 0x29, 0x1c, 0xa7, 0x57, 0xd2, 0x4d, 0x18, 0x50, 0x82, 0xce, 0xa1, 0x12, 0xc6, 0x6c,
+	0x13, 0x33, 0x71, 0xa3, 0x8e, 0x15, 0x6a, 0x5f, 0x40, 0x85, 0x5f, 0x39, 0xec, 0xa3, 0x33, 0x28,
+	0x7f, 0x08, 0x17, 0x43, 0x57, 0x25, 0x5a, 0x82, 0xf6, 0x7f, 0x34, 0x00, 0xae, 0xa0, 0x28, 0xb1,
+	0x57, 0xa9, 0xe0, 0x50, 0xe9, 0xd9, 0x0e, 0xe9, 0x99, 0x43, 0x1d, 0x28, 0x53, 0x66, 0x33, 0x22,
+	0x78, 0x71, 0x74, 0x89, 0x0a, 0xbb, 0xf9, 0xd5, 0x04, 0x4b, 0x05, 0x9e, 0xac, 0x4d, 0x14, 0x2e,
+	0x23, 0x42, 0xa9, 0xe0, 0x4a, 0x09, 0xa7, 0x38, 0xe7, 0x63, 0x25, 0xef, 0x23, 0x37, 0x9a, 0x44,
+	0x51, 0x18, 0x09, 0x6a, 0xd4, 0xb1, 0x04, 0x9c, 0x34, 0xe4, 0xd3, 0xc6, 0x8b, 0x08, 0xed, 0x32,
+	0xc1, 0x0a, 0x1d, 0x67, 0x82, 0xf6, 0xdf, 0x34, 0x80, 0x9e, 0xed, 0xac, 0x08, 0xbf, 0x9d, 0x72,
+	0xaa, 0xaf, 0x3c, 0x46, 0x85, 0xdb, 0x06, 0x16, 0x6b, 0x7e, 0xdd, 0xda, 0xa3, 0x94, 0x50, 0xe1,
+	0xb3, 0x81, 0x15, 0xe2, 0x72, 0xba, 0xb2, 0x23, 0xe2, 0x0a, 0x0f, 0x0d, 0xac, 0x90, 0xb8, 0xf0,
+	0xa3, 0xe7, 0x30, 0x2f, 0x0c, 0xa8, 0x70, 0xd4, 0xc0, 0x99, 0x80, 0x33, 0x8d, 0x04, 0x2c, 0xf2,
+	0x88, 0xf4, 0xab, 0x8c, 0x13, 0xc8, 0x5d, 0x76, 0xec, 0x8d, 0xed, 0x78, 0x6c, 0x2b, 0x1c, 0x2b,
+	0xe3, 0x14, 0x73, 0x33, 0x4f, 0xf9, 0x13, 0xb9, 0x12, 0x4f, 0x9a, 0x44, 0xdf, 0x93, 0x62, 0x16,
+	0x54, 0x57, 0x36, 0x5d, 0x7d, 0x4b, 0xb6, 0xca, 0x97, 0x04, 0x0a, 0xb3, 0x3e, 0x39, 0x7e, 0xec,
+	0x12, 0x4b, 0x6f, 0xe9, 0x9d, 0x3a, 0x4e, 0x20, 0x0f, 0xc9, 0x5f, 0xc2, 0x40, 0xa6, 0xac, 0x8e,
+	0xc5, 0x9a, 0xbb, 0x1e, 0x91, 0xa5, 0x17, 0x06, 0xc2, 0x87, 0x3a, 0x56, 0xa8, 0x3d, 0x83, 0xb3,
+	0xa2, 0x95, 0x8a, 0x95, 0x17, 0x00, 0x0b, 0x42, 0xd9, 0x54, 0xd4, 0x94, 0xa4, 0xc2, 0x64, 0x12,
+	0x1e, 0xb2, 0x8d, 0xe7, 0x3c, 0xcc, 0xc2, 0x07, 0x12, 0x28, 0xcb, 0x32, 0x41, 0xfb, 0x1c, 0xce,
+	0xa6, 0xf1, 0x82, 0x3a, 0x91, 0xb7, 0x20, 0xfc, 0x78, 0xe5, 0x7c, 0xfb, 0xef, 0x1a, 0x1c, 0xf4,
+	0x6c, 0xdf, 0x1f, 0xc7, 0xcc, 0x09, 0xd7, 0xe4, 0x39, 0x75, 0xcc, 0x09, 0x5d, 0x49, 0xdd, 0x32,
+	0x16, 0xeb, 0x7c, 0xad, 0xd2, 0x8b, 0xb5, 0x2a, 0x1f, 0x5a, 0xe3, 0x7f, 0x87, 0xb6, 0xe0, 0x42,
+	0x79, 0xd7, 0x85, 0x2e, 0x34, 0x94, 0x95, 0x58, 0x94, 0x0e, 0xf4, 0x1a, 0x6a, 0xa1, 0x14, 0x70,
+	0xb2, 0xe9, 0x9d, 0x83, 0xcb, 0xb3, 0xf4, 0xf4, 0x9c, 0x4f, 0x38, 0xd5, 0x6a, 0xff, 0x4e, 0xd6,
+	0x6c, 0xb5, 0xff, 0x15, 0xd4, 0x16, 0xf2, 0xe9, 0x27, 0xfb, 0x8b, 0xb5, 0x54, 0xbe, 0x63, 0x9c,
+	0x2a, 0xb5, 0x5f, 0x80, 0x39, 0x25, 0x6c, 0x12, 0xfa, 0x9e, 0xb3, 0x4d, 0xd8, 0x73, 0x0e, 0x95,
+	0x8d, 0x10, 0xa8, 0x60, 0x29, 0xd4, 0xbe, 0x81, 0x93, 0x9c, 0xae, 0xca, 0xa1, 0x78, 0x91, 0xe4,
+	0xa3, 0x17, 0xc6, 0x54, 0xa9, 0xa7, 0x38, 0x77, 0x50, 0xa9, 0x70, 0x50, 0x17, 0x4e, 0xfb, 0xbc,
+	0x8e, 0xee, 0x14, 0x46, 0x04, 0x86, 0x9d, 0xa5, 0x48, 0xac, 0x25, 0xa5, 0x68, 0xbc, 0x96, 0xe9,
+	0xa9, 0x61, 0x85, 0xda, 0x7f, 0x35, 0xe0, 0x40, 0x6d, 0x1f, 0x06, 0xef, 0xc3, 0xa7, 0xf6, 0x7e,
+	0x47, 0x44, 0x2b, 0x92, 0xa9, 0x55, 0x88, 0x17, 0x04, 0x9e, 0x1f, 0x2a, 0x52, 0x5b, 0xc7, 0x12,
+	0xa4, 0xed, 0xcc, 0xc8, 0xb5, 0xb3, 0xff, 0xab, 0x31, 0xe5, 0x7b, 0x5f, 0x65, 0xa7, 0xf7, 0x15,
+	0x9b, 0x50, 0xf5, 0x8b, 0x26, 0x94, 0xe3, 0x5b, 0xad, 0xc8, 0xb7, 0x0b, 0x00, 0xdf, 0xa6, 0x4c,
+	0xe6, 0x57, 0x34, 0x28, 0x1d, 0xe7, 0x24, 0x9c, 0x60, 0xbe, 0x4c, 0xac, 0x4f, 0x54, 0x93, 0xca,
+	0x04, 0xe2, 0x65, 0x13, 0xdb, 0x67, 0xab, 0xad, 0xe8, 0x51, 0x35, 0x9c, 0x40, 0xd4, 0x86, 0xc3,
+	0xf7, 0xb6, 0xe7, 0x13, 0xb7, 0xb7, 0x22, 0xce, 0x03, 0xb5, 0x0e, 0x85, 0x4d, 0x05, 0x59, 0xa1,
+	0x35, 0x36, 0x76, 0x5a, 0xe3, 0x19, 0x94, 0x39, 0x8f, 0xa9, 0x75, 0x24, 0x48, 0x2d, 0x01, 0x0f,
+	0xb9, 0x28, 0xaf, 0xd4, 0x3a, 0x96, 0xc5, 0x4f, 0x22, 0x7e, 0x1b, 0x5d, 0xc5, 0x8c, 0x79, 0xc1,
+	0xb2, 0x1f, 0x7e, 0x17, 0x58, 0xa6, 0x38, 0xad, 0x20, 0xe3, 0x3a, 0xe4, 0x03, 0x71, 0x18, 0x71,
+	0xe7, 0x01, 0xf3, 0x7c, 0xeb, 0x44, 0xf8, 0x5a, 0x90, 0xa5, 0x55, 0x07, 0xed, 0xad, 0x3a, 0xa7,
+	0x85, 0xaa, 0xf3, 0x2e, 0x65, 0xc8, 0xad, 0xf7, 0x34, 0xab, 0xf9, 0x93, 0x4b, 0x9f, 0x4c, 0x69,
+	0xe7, 0xc9, 0xe5, 0x18, 0x96, 0x7b, 0x33, 0xff, 0xd0, 0xe1, 0x24, 0x5f, 0xcf, 0x64, 0x8f, 0x78,
+	0xea, 0xfc, 0x73, 0xa8, 0xf8, 0xc4, 0x76, 0x49, 0x94, 0x30, 0x58, 0x22, 0x9e, 0x38, 0xca, 0x6c,
+	0x35, 0x40, 0xc8, 0x66, 0x98, 0x09, 0xb2, 0xf0, 0x1a, 0xf9, 0xf0, 0x5e, 0x00, 0xf0, 0xc5, 0x40,
+	0x86, 0x58, 0x96, 0x93, 0x9c, 0x84, 0xa7, 0x7b, 0x13, 0x85, 0x9f, 0x3c, 0xe2, 0xaa, 0xf9, 0x28,
+	0x81, 0xa8, 0x05, 0x07, 0x7c, 0xb9, 0x55, 0x5b, 0xab, 0xe2, 0x6b, 0x5e, 0x84, 0x7e, 0x02, 0x0d,
+	0x69, 0x71, 0x6f, 0x65, 0x07, 0x4b, 0x42, 0x05, 0x11, 0x0d, 0x5c, 0x14, 0x72, 0x4a, 0xa4, 0xd1,
+	0xaa, 0x4b, 0x92, 0x27, 0x38, 0x4f, 0x36, 0x90, 0x3d, 0x4c, 0xc1, 0x02, 0x91, 0x0e, 0xe4, 0xae,
+	0xfc, 0x8c, 0xa5, 0x52, 0xac, 0x38, 0x98, 0x40, 0xd1, 0x31, 0x3f, 0x10, 0xd9, 0x20, 0xad, 0x86,
+	0xea, 0x98, 0x89, 0x80, 0x9f, 0x99, 0x96, 0x4a, 0xc9, 0xc1, 0x14, 0x8b, 0x92, 0xbf, 0xf1, 0x7c,
+	0x3f, 0xfc, 0x48, 0x52, 0x2a, 0xe6, 0x24, 0x2f, 0xfa, 0x00, 0xd9, 0x03, 0x46, 0x55, 0xd0, 0x7b,
+	0x93, 0xb9, 0xf9, 0x15, 0x6a, 0x40, 0x7d, 0x38, 0xba, 0xbf, 0xbe, 0x1d, 0xde, 0xbc, 0x9d, 0x99,
+	0x1a, 0x3a, 0x86, 0x83, 0x3f, 0xcc, 0x07, 0xf3, 0xc1, 0x7d, 0x7f, 0x30, 0x99, 0xbd, 0x35, 0x4b,
+	0xfc, 0x7b, 0x6f, 0x7c, 0x37, 0x19, 0x4f, 0x87, 0xb3, 0x81, 0xa9, 0xbf, 0x70, 0xa0, 0x96, 0x54,
+	0x7c, 0x7e, 0xc6, 0x74, 0x7e, 0x27, 0xcf, 0x18, 0xcd, 0xde, 0xde, 0x4f, 0xf0, 0xf0, 0x6e, 0x60,
+	0x6a, 0x1c, 0x5e, 0x0f, 0xaf, 0xc6, 0xa3, 0x6e, 0xaf, 0x37, 0x34, 0x4b, 0xe8, 0x14, 0x8e, 0xef,
+	0xba, 0x33, 0x3c, 0xfc, 0xe3, 0xfd, 0xdd, 0xfc, 0x76, 0x36, 0x9c, 0xdc, 0xfe, 0xc9, 0xd4, 0xd1,
+	0x09, 0x34, 0x26, 0x78, 0x3c, 0xbe, 0xbe, 0x1f, 0x5f, 0xdf, 0xbf, 0x1b, 0xe3, 0x6f, 0x4d, 0x03,
+	0xd5, 0xc0, 0x98, 0x8e, 0xf1, 0xcc, 0x2c, 0xbf, 0x18, 0x41, 0x3d, 0x9d, 0x82, 0x10, 0x40, 0x45,
+	0x58, 0xd4, 0x37, 0xbf, 0x42, 0x07, 0x50, 0xc5, 0xf3, 0xd1, 0x68, 0x38, 0xba, 0x91, 0xd7, 0x4c,
+	0xe7, 0xbd, 0xde, 0x60, 0xd0, 0x1f, 0xf4, 0xcd, 0x12, 0xd7, 0xbb, 0xee, 0x0e, 0x6f, 0x07, 0x7d,
+	0x53, 0x17, 0x46, 0x77, 0x47, 0xbd, 0xc1, 0x2d, 0x87, 0xc6, 0xe5, 0xbf, 0x4a, 0xe9, 0x40, 0xca,
+	0xbb, 0xac, 0xe7, 0x10, 0xf4, 0x7b, 0x00, 0x25, 0xc1, 0x93, 0x1e, 0xfa, 0xc1, 0x2e, 0xfb, 0x55,
+	0x79, 0x6e, 0x5a, 0x5f, 0x7e, 0x50, 0x3d, 0xe0, 0xb7, 0x00, 0xd3, 0x78, 0xb1, 0xf6, 0x18, 0xb7,
+	0xf4, 0xe9, 0x03, 0x4e, 0xbf, 0x98, 0xeb, 0x62, 0x8a, 0x7e, 0x09, 0x8d, 0x1b, 0xc2, 0x72, 0x82,
+	0xe3, 0x82, 0xd6, 0xb0, 0xbf, 0x7f, 0xdb, 0x37, 0x50, 0x7f, 0x67, 0x33, 0x67, 0x25, 0x6e, 0x7c,
+	0xd6, 0x96, 0xd7, 0x1a, 0xba, 0xe4, 0x43, 0x5d, 0xe0, 0x10, 0xff, 0xf9, 0xbb, 0xd0, 0x1b, 0x61,
+	0x5f, 0x6e, 0x16, 0x3c, 0x4a, 0xb5, 0xc4, 0x80, 0x9f, 0xdb, 0x95, 0x29, 0x5d, 0xfe, 0x5b, 0xcb,
+	0x8f, 0x3c, 0x5e, 0xb0, 0x4c, 0x62, 0x3d, 0x82, 0xe3, 0xc2, 0x28, 0x34, 0xe9, 0xa1, 0xaf, 0x0b,
+	0x4d, 0x65, 0x67, 0x94, 0x6b, 0xfe, 0xe8, 0x89, 0xaf, 0x2a, 0xf4, 0x03, 0x68, 0x14, 0x86, 0x20,
+	0x94, 0xe9, 0xef, 0x1b, 0x8e, 0x9a, 0xc5, 0x0e, 0x26, 0x7b, 0xc8, 0x6b, 0x0d, 0xfd, 0x1a, 0x1a,
+	0x72, 0x9d, 0x0c, 0x4d, 0xe7, 0xa9, 0x5e, 0x61, 0x40, 0x69, 0xee, 0x78, 0x7f, 0xf9, 0xb9, 0x04,
+	0x87, 0x5d, 0x77, 0xed, 0x05, 0x89, 0x83, 0x57, 0x50, 0x4f, 0x87, 0x04, 0xf4, 0xc3, 0xcc, 0x98,
+	0x9d, 0x21, 0xa3, 0xd9, 0xdc, 0xf7, 0x49, 0x39, 0xf5, 0x06, 0x0e, 0x79, 0xc9, 0xbe, 0x4a, 0x0a,
+	0xcb, 0x6e, 0xc8, 0xbf, 0x28, 0xd0, 0x5c, 0x1b, 0x5d, 0xc1, 0x61, 0x7e, 0xaa, 0xc8, 0xc5, 0x75,
+	0xcf, 0xb0, 0xd1, 0xdc, 0x5b, 0xe4, 0xd1, 0xaf, 0xa0, 0x76, 0x43, 0xd8, 0xfe, 0x44, 0x37, 0xf7,
+	0x66, 0x42, 0xe6, 0xfb, 0xb3, 0x06, 0x27, 0x32, 0x42, 0xfc, 0x5b, 0x12, 0x8b, 0x37, 0xd0, 0xc8,
+	0x84, 0x3c, 0xd5, 0xfb, 0x86, 0xb1, 0xdd, 0x90, 0xa2, 0xdf, 0x80, 0x99, 0x3b, 0x8a, 0x45, 0xc4,
+	0x5e, 0x3f, 0x6b, 0x63, 0x47, 0xbb, 0x3a, 0xfe, 0x73, 0xe3, 0xf1, 0x17, 0xaf, 0xb2, 0x1f, 0xfe,
+	0x45, 0x45, 0xac, 0xbf, 0xf9, 0xef, 0x00, 0xfb, 0x92, 0xe3, 0x97, 0x05, 0x10, 0x00, 0x00,
 }
@@ -19,7 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	BackendService_BackendRPC_FullMethodName = "/lbproto.BackendService/BackendRPC"
+	BackendService_BackendRPC_FullMethodName    = "/lbproto.BackendService/BackendRPC"
+	BackendService_SubmitTask_FullMethodName    = "/lbproto.BackendService/SubmitTask"
+	BackendService_GetTaskStatus_FullMethodName = "/lbproto.BackendService/GetTaskStatus"
+	BackendService_WatchTask_FullMethodName     = "/lbproto.BackendService/WatchTask"
+	BackendService_CancelTask_FullMethodName    = "/lbproto.BackendService/CancelTask"
+	BackendService_GetCacheStats_FullMethodName = "/lbproto.BackendService/GetCacheStats"
 )
 
 // BackendServiceClient is the client API for BackendService service.
@@ -27,6 +32,16 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type BackendServiceClient interface {
 	BackendRPC(ctx context.Context, in *BackendRequest, opts ...grpc.CallOption) (*BackendResponse, error)
+	// Asynchronous jobs, for tasks too long to hold a call open. The job
+	// runs on the backend that accepted it; its ID names that backend.
+	SubmitTask(ctx context.Context, in *BackendRequest, opts ...grpc.CallOption) (*TaskStatus, error)
+	GetTaskStatus(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*TaskStatus, error)
+	// Sends the status on every change and periodically while the job runs,
+	// ending after the final status
+	WatchTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TaskStatus], error)
+	CancelTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*TaskStatus, error)
+	// Counters of the backend's result cache
+	GetCacheStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CacheStats, error)
 }
 
 type backendServiceClient struct {
@@ -47,11 +62,80 @@ func (c *backendServiceClient) BackendRPC(ctx context.Context, in *BackendReques
 	return out, nil
 }
 
+func (c *backendServiceClient) SubmitTask(ctx context.Context, in *BackendRequest, opts ...grpc.CallOption) (*TaskStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TaskStatus)
+	err := c.cc.Invoke(ctx, BackendService_SubmitTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *backendServiceClient) GetTaskStatus(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*TaskStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TaskStatus)
+	err := c.cc.Invoke(ctx, BackendService_GetTaskStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *backendServiceClient) WatchTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TaskStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BackendService_ServiceDesc.Streams[0], BackendService_WatchTask_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TaskID, TaskStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BackendService_WatchTaskClient = grpc.ServerStreamingClient[TaskStatus]
+
+func (c *backendServiceClient) CancelTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*TaskStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TaskStatus)
+	err := c.cc.Invoke(ctx, BackendService_CancelTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *backendServiceClient) GetCacheStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CacheStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CacheStats)
+	err := c.cc.Invoke(ctx, BackendService_GetCacheStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BackendServiceServer is the server API for BackendService service.
 // All implementations must embed UnimplementedBackendServiceServer
 // for forward compatibility.
 type BackendServiceServer interface {
 	BackendRPC(context.Context, *BackendRequest) (*BackendResponse, error)
+	// Asynchronous jobs, for tasks too long to hold a call open. The job
+	// runs on the backend that accepted it; its ID names that backend.
+	SubmitTask(context.Context, *BackendRequest) (*TaskStatus, error)
+	GetTaskStatus(context.Context, *TaskID) (*TaskStatus, error)
+	// Sends the status on every change and periodically while the job runs,
+	// ending after the final status
+	WatchTask(*TaskID, grpc.ServerStreamingServer[TaskStatus]) error
+	CancelTask(context.Context, *TaskID) (*TaskStatus, error)
+	// Counters of the backend's result cache
+	GetCacheStats(context.Context, *Empty) (*CacheStats, error)
 	mustEmbedUnimplementedBackendServiceServer()
 }
 
@@ -65,6 +149,21 @@ type UnimplementedBackendServiceServer struct{}
 func (UnimplementedBackendServiceServer) BackendRPC(context.Context, *BackendRequest) (*BackendResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BackendRPC not implemented")
 }
+func (UnimplementedBackendServiceServer) SubmitTask(context.Context, *BackendRequest) (*TaskStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTask not implemented")
+}
+func (UnimplementedBackendServiceServer) GetTaskStatus(context.Context, *TaskID) (*TaskStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTaskStatus not implemented")
+}
+func (UnimplementedBackendServiceServer) WatchTask(*TaskID, grpc.ServerStreamingServer[TaskStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTask not implemented")
+}
+func (UnimplementedBackendServiceServer) CancelTask(context.Context, *TaskID) (*TaskStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelTask not implemented")
+}
+func (UnimplementedBackendServiceServer) GetCacheStats(context.Context, *Empty) (*CacheStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCacheStats not implemented")
+}
 func (UnimplementedBackendServiceServer) mustEmbedUnimplementedBackendServiceServer() {}
 func (UnimplementedBackendServiceServer) testEmbeddedByValue()                        {}
 
@@ -104,6 +203,89 @@ func _BackendService_BackendRPC_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BackendService_SubmitTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BackendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BackendServiceServer).SubmitTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BackendService_SubmitTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BackendServiceServer).SubmitTask(ctx, req.(*BackendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BackendService_GetTaskStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BackendServiceServer).GetTaskStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BackendService_GetTaskStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BackendServiceServer).GetTaskStatus(ctx, req.(*TaskID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BackendService_WatchTask_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TaskID)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BackendServiceServer).WatchTask(m, &grpc.GenericServerStream[TaskID, TaskStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BackendService_WatchTaskServer = grpc.ServerStreamingServer[TaskStatus]
+
+func _BackendService_CancelTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BackendServiceServer).CancelTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BackendService_CancelTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BackendServiceServer).CancelTask(ctx, req.(*TaskID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BackendService_GetCacheStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BackendServiceServer).GetCacheStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BackendService_GetCacheStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BackendServiceServer).GetCacheStats(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BackendService_ServiceDesc is the grpc.ServiceDesc for BackendService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,13 +297,37 @@ var BackendService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "BackendRPC",
 			Handler:    _BackendService_BackendRPC_Handler,
 		},
+		{
+			MethodName: "SubmitTask",
+			Handler:    _BackendService_SubmitTask_Handler,
+		},
+		{
+			MethodName: "GetTaskStatus",
+			Handler:    _BackendService_GetTaskStatus_Handler,
+		},
+		{
+			MethodName: "CancelTask",
+			Handler:    _BackendService_CancelTask_Handler,
+		},
+		{
+			MethodName: "GetCacheStats",
+			Handler:    _BackendService_GetCacheStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTask",
+			Handler:       _BackendService_WatchTask_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protofiles/load_balancing.proto",
 }
 
 const (
 	LoadBalancingService_LoadBalancerRPC_FullMethodName = "/lbproto.LoadBalancingService/LoadBalancerRPC"
+	LoadBalancingService_SubscribeLoad_FullMethodName   = "/lbproto.LoadBalancingService/SubscribeLoad"
+	LoadBalancingService_ReportOutcome_FullMethodName   = "/lbproto.LoadBalancingService/ReportOutcome"
 )
 
 // LoadBalancingServiceClient is the client API for LoadBalancingService service.
@@ -129,6 +335,13 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type LoadBalancingServiceClient interface {
 	LoadBalancerRPC(ctx context.Context, in *LoadBalancerRequest, opts ...grpc.CallOption) (*LoadBalancerResponse, error)
+	// Streams the load of every backend, for clients balancing on their own
+	SubscribeLoad(ctx context.Context, in *SubscribeLoadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LoadReport], error)
+	// Tells the load balancer how calls to the backends it picked went, for
+	// outlier detection. Every pick is reported once; a pick that was not
+	// used, or whose call was cancelled, is reported as CANCELLED so that it
+	// is released without being judged
+	ReportOutcome(ctx context.Context, in *OutcomeReport, opts ...grpc.CallOption) (*Empty, error)
 }
 
 type loadBalancingServiceClient struct {
@@ -149,11 +362,47 @@ func (c *loadBalancingServiceClient) LoadBalancerRPC(ctx context.Context, in *Lo
 	return out, nil
 }
 
+func (c *loadBalancingServiceClient) SubscribeLoad(ctx context.Context, in *SubscribeLoadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LoadReport], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LoadBalancingService_ServiceDesc.Streams[0], LoadBalancingService_SubscribeLoad_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeLoadRequest, LoadReport]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LoadBalancingService_SubscribeLoadClient = grpc.ServerStreamingClient[LoadReport]
+
+func (c *loadBalancingServiceClient) ReportOutcome(ctx context.Context, in *OutcomeReport, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, LoadBalancingService_ReportOutcome_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LoadBalancingServiceServer is the server API for LoadBalancingService service.
 // All implementations must embed UnimplementedLoadBalancingServiceServer
 // for forward compatibility.
 type LoadBalancingServiceServer interface {
 	LoadBalancerRPC(context.Context, *LoadBalancerRequest) (*LoadBalancerResponse, error)
+	// Streams the load of every backend, for clients balancing on their own
+	SubscribeLoad(*SubscribeLoadRequest, grpc.ServerStreamingServer[LoadReport]) error
+	// Tells the load balancer how calls to the backends it picked went, for
+	// outlier detection. Every pick is reported once; a pick that was not
+	// used, or whose call was cancelled, is reported as CANCELLED so that it
+	// is released without being judged
+	ReportOutcome(context.Context, *OutcomeReport) (*Empty, error)
 	mustEmbedUnimplementedLoadBalancingServiceServer()
 }
 
@@ -167,6 +416,12 @@ type UnimplementedLoadBalancingServiceServer struct{}
 func (UnimplementedLoadBalancingServiceServer) LoadBalancerRPC(context.Context, *LoadBalancerRequest) (*LoadBalancerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LoadBalancerRPC not implemented")
 }
+func (UnimplementedLoadBalancingServiceServer) SubscribeLoad(*SubscribeLoadRequest, grpc.ServerStreamingServer[LoadReport]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeLoad not implemented")
+}
+func (UnimplementedLoadBalancingServiceServer) ReportOutcome(context.Context, *OutcomeReport) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportOutcome not implemented")
+}
 func (UnimplementedLoadBalancingServiceServer) mustEmbedUnimplementedLoadBalancingServiceServer() {}
 func (UnimplementedLoadBalancingServiceServer) testEmbeddedByValue()                              {}
 
@@ -206,6 +461,35 @@ func _LoadBalancingService_LoadBalancerRPC_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LoadBalancingService_SubscribeLoad_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeLoadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LoadBalancingServiceServer).SubscribeLoad(m, &grpc.GenericServerStream[SubscribeLoadRequest, LoadReport]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LoadBalancingService_SubscribeLoadServer = grpc.ServerStreamingServer[LoadReport]
+
+func _LoadBalancingService_ReportOutcome_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OutcomeReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadBalancingServiceServer).ReportOutcome(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoadBalancingService_ReportOutcome_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadBalancingServiceServer).ReportOutcome(ctx, req.(*OutcomeReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // LoadBalancingService_ServiceDesc is the grpc.ServiceDesc for LoadBalancingService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -217,13 +501,250 @@ var LoadBalancingService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "LoadBalancerRPC",
 			Handler:    _LoadBalancingService_LoadBalancerRPC_Handler,
 		},
+		{
+			MethodName: "ReportOutcome",
+			Handler:    _LoadBalancingService_ReportOutcome_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeLoad",
+			Handler:       _LoadBalancingService_SubscribeLoad_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "protofiles/load_balancing.proto",
+}
+
+const (
+	AdminService_SetPolicy_FullMethodName    = "/lbproto.AdminService/SetPolicy"
+	AdminService_ListBackends_FullMethodName = "/lbproto.AdminService/ListBackends"
+	AdminService_DrainBackend_FullMethodName = "/lbproto.AdminService/DrainBackend"
+	AdminService_GetStats_FullMethodName     = "/lbproto.AdminService/GetStats"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Operator calls on the load balancer, see lbctl
+type AdminServiceClient interface {
+	// Switches the policy for all later picks, keeping the backend state
+	SetPolicy(ctx context.Context, in *SetPolicyRequest, opts ...grpc.CallOption) (*SetPolicyResponse, error)
+	ListBackends(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*BackendList, error)
+	// Stops (or with resume, restarts) picking a backend; requests already
+	// sent to it run to completion
+	DrainBackend(ctx context.Context, in *DrainBackendRequest, opts ...grpc.CallOption) (*BackendInfo, error)
+	GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*LoadBalancerStats, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) SetPolicy(ctx context.Context, in *SetPolicyRequest, opts ...grpc.CallOption) (*SetPolicyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetPolicyResponse)
+	err := c.cc.Invoke(ctx, AdminService_SetPolicy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListBackends(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*BackendList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BackendList)
+	err := c.cc.Invoke(ctx, AdminService_ListBackends_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DrainBackend(ctx context.Context, in *DrainBackendRequest, opts ...grpc.CallOption) (*BackendInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BackendInfo)
+	err := c.cc.Invoke(ctx, AdminService_DrainBackend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*LoadBalancerStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoadBalancerStats)
+	err := c.cc.Invoke(ctx, AdminService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility.
+//
+// Operator calls on the load balancer, see lbctl
+type AdminServiceServer interface {
+	// Switches the policy for all later picks, keeping the backend state
+	SetPolicy(context.Context, *SetPolicyRequest) (*SetPolicyResponse, error)
+	ListBackends(context.Context, *Empty) (*BackendList, error)
+	// Stops (or with resume, restarts) picking a backend; requests already
+	// sent to it run to completion
+	DrainBackend(context.Context, *DrainBackendRequest) (*BackendInfo, error)
+	GetStats(context.Context, *Empty) (*LoadBalancerStats, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) SetPolicy(context.Context, *SetPolicyRequest) (*SetPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPolicy not implemented")
+}
+func (UnimplementedAdminServiceServer) ListBackends(context.Context, *Empty) (*BackendList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBackends not implemented")
+}
+func (UnimplementedAdminServiceServer) DrainBackend(context.Context, *DrainBackendRequest) (*BackendInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrainBackend not implemented")
+}
+func (UnimplementedAdminServiceServer) GetStats(context.Context, *Empty) (*LoadBalancerStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+func (UnimplementedAdminServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_SetPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetPolicy(ctx, req.(*SetPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListBackends_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListBackends(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListBackends_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListBackends(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DrainBackend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainBackendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DrainBackend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_DrainBackend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DrainBackend(ctx, req.(*DrainBackendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetStats(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lbproto.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetPolicy",
+			Handler:    _AdminService_SetPolicy_Handler,
+		},
+		{
+			MethodName: "ListBackends",
+			Handler:    _AdminService_ListBackends_Handler,
+		},
+		{
+			MethodName: "DrainBackend",
+			Handler:    _AdminService_DrainBackend_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _AdminService_GetStats_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protofiles/load_balancing.proto",
 }
 
 const (
-	ReportLoadService_ReportLoadRPC_FullMethodName = "/lbproto.ReportLoadService/ReportLoadRPC"
+	ReportLoadService_ReportLoadRPC_FullMethodName    = "/lbproto.ReportLoadService/ReportLoadRPC"
+	ReportLoadService_ReportLoadStream_FullMethodName = "/lbproto.ReportLoadService/ReportLoadStream"
 )
 
 // ReportLoadServiceClient is the client API for ReportLoadService service.
@@ -231,6 +752,8 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ReportLoadServiceClient interface {
 	ReportLoadRPC(ctx context.Context, in *LoadStatus, opts ...grpc.CallOption) (*Empty, error)
+	// Long-lived stream of reports from one backend
+	ReportLoadStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LoadStatus, Empty], error)
 }
 
 type reportLoadServiceClient struct {
@@ -251,11 +774,26 @@ func (c *reportLoadServiceClient) ReportLoadRPC(ctx context.Context, in *LoadSta
 	return out, nil
 }
 
+func (c *reportLoadServiceClient) ReportLoadStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LoadStatus, Empty], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReportLoadService_ServiceDesc.Streams[0], ReportLoadService_ReportLoadStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LoadStatus, Empty]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReportLoadService_ReportLoadStreamClient = grpc.ClientStreamingClient[LoadStatus, Empty]
+
 // ReportLoadServiceServer is the server API for ReportLoadService service.
 // All implementations must embed UnimplementedReportLoadServiceServer
 // for forward compatibility.
 type ReportLoadServiceServer interface {
 	ReportLoadRPC(context.Context, *LoadStatus) (*Empty, error)
+	// Long-lived stream of reports from one backend
+	ReportLoadStream(grpc.ClientStreamingServer[LoadStatus, Empty]) error
 	mustEmbedUnimplementedReportLoadServiceServer()
 }
 
@@ -269,6 +807,9 @@ type UnimplementedReportLoadServiceServer struct{}
 func (UnimplementedReportLoadServiceServer) ReportLoadRPC(context.Context, *LoadStatus) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReportLoadRPC not implemented")
 }
+func (UnimplementedReportLoadServiceServer) ReportLoadStream(grpc.ClientStreamingServer[LoadStatus, Empty]) error {
+	return status.Errorf(codes.Unimplemented, "method ReportLoadStream not implemented")
+}
 func (UnimplementedReportLoadServiceServer) mustEmbedUnimplementedReportLoadServiceServer() {}
 func (UnimplementedReportLoadServiceServer) testEmbeddedByValue()                           {}
 
@@ -308,6 +849,13 @@ func _ReportLoadService_ReportLoadRPC_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ReportLoadService_ReportLoadStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReportLoadServiceServer).ReportLoadStream(&grpc.GenericServerStream[LoadStatus, Empty]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReportLoadService_ReportLoadStreamServer = grpc.ClientStreamingServer[LoadStatus, Empty]
+
 // ReportLoadService_ServiceDesc is the grpc.ServiceDesc for ReportLoadService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -320,6 +868,12 @@ var ReportLoadService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ReportLoadService_ReportLoadRPC_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReportLoadStream",
+			Handler:       _ReportLoadService_ReportLoadStream_Handler,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "protofiles/load_balancing.proto",
 }
@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// LeaderElectionPrefix is the etcd prefix load balancer instances campaign
+// under. The value of the oldest key is the address of the active instance.
+const LeaderElectionPrefix = "/services/load_balancer/leader"
+
+// DefaultLoadBalancerAddr is where the load balancer listens unless told
+// otherwise, and where clients look for it when none is elected.
+const DefaultLoadBalancerAddr = "localhost:50319"
+
+var ErrNoLeader = errors.New("no load balancer leader elected")
+
+// LeaderFinder is implemented by registries that can tell which load
+// balancer instance is currently active.
+type LeaderFinder interface {
+	Leader(ctx context.Context) (string, error)
+}
+
+// LoadBalancerAddr returns the address of the elected load balancer, or
+// fallback if reg does not elect one or nobody is campaigning.
+func LoadBalancerAddr(ctx context.Context, reg Registry, fallback string) string {
+	finder, ok := reg.(LeaderFinder)
+	if !ok {
+		return fallback
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	addr, err := finder.Leader(ctx)
+	if err != nil {
+		return fallback
+	}
+	return addr
+}
+
+// FindLoadBalancer opens the registry described by c just long enough to
+// look up the elected load balancer, returning fallback if it cannot.
+func FindLoadBalancer(c Config, fallback string) string {
+	reg, err := Open(c)
+	if err != nil {
+		log.Printf("Could not open service registry, using %s: %v", fallback, err)
+		return fallback
+	}
+	defer reg.Close()
+	return LoadBalancerAddr(context.Background(), reg, fallback)
+}
+
+// Leader returns the value the current election leader campaigned with.
+func (r *EtcdRegistry) Leader(ctx context.Context) (string, error) {
+	// same query as concurrency.Election.Leader, which needs a session
+	resp, err := r.client.Get(ctx, LeaderElectionPrefix+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", ErrNoLeader
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Election is one campaign for load balancer leadership. Leadership is tied
+// to an etcd session; if the session's lease expires, Done is closed and a
+// new Election must be started to campaign again.
+type Election struct {
+	session  *concurrency.Session
+	election *concurrency.Election
+}
+
+// NewElection starts a session with the given TTL, which bounds how long a
+// crashed leader keeps its leadership.
+func (r *EtcdRegistry) NewElection(ttl time.Duration) (*Election, error) {
+	session, err := concurrency.NewSession(r.client, concurrency.WithTTL(int(leaseSeconds(ttl))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create election session: %w", err)
+	}
+	return &Election{
+		session:  session,
+		election: concurrency.NewElection(session, LeaderElectionPrefix),
+	}, nil
+}
+
+// Campaign blocks until this instance is elected with addr as its value.
+func (e *Election) Campaign(ctx context.Context, addr string) error {
+	return e.election.Campaign(ctx, addr)
+}
+
+// Done is closed when leadership can no longer be held.
+func (e *Election) Done() <-chan struct{} {
+	return e.session.Done()
+}
+
+// Close resigns leadership, if held, and ends the session.
+func (e *Election) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	e.election.Resign(ctx)
+	return e.session.Close()
+}
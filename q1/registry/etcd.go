@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/v3"
+)
+
+// EtcdRegistry stores each instance under KeyPrefix + address, attached to
+// the instance's lease.
+type EtcdRegistry struct {
+	client    *clientv3.Client
+	keyPrefix string
+}
+
+func NewEtcdRegistry(endpoints []string, keyPrefix string) (*EtcdRegistry, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to etcd: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := client.Status(ctx, endpoints[0])
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("etcd is not running or unreachable: %w", err)
+	}
+	log.Println("Etcd is running! Version:", resp.Version)
+
+	return &EtcdRegistry{client: client, keyPrefix: keyPrefix}, nil
+}
+
+// Client returns the underlying etcd client.
+func (r *EtcdRegistry) Client() *clientv3.Client {
+	return r.client
+}
+
+func (r *EtcdRegistry) Register(ctx context.Context, inst Instance, ttl time.Duration) (LeaseID, error) {
+	leaseResp, err := r.client.Grant(ctx, leaseSeconds(ttl))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create lease: %w", err)
+	}
+	_, err = r.client.Put(ctx, r.keyPrefix+inst.Addr, encodeInstance(inst), clientv3.WithLease(leaseResp.ID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to register %s: %w", inst.Addr, err)
+	}
+	return LeaseID(leaseResp.ID), nil
+}
+
+// leaseSeconds converts ttl to the whole seconds etcd leases take, rounding
+// up so a sub-second TTL does not become 0.
+func leaseSeconds(ttl time.Duration) int64 {
+	return max(int64((ttl+time.Second-1)/time.Second), 1)
+}
+
+func (r *EtcdRegistry) KeepAlive(ctx context.Context, lease LeaseID) error {
+	ch, err := r.client.KeepAlive(ctx, clientv3.LeaseID(lease))
+	if err != nil {
+		return err
+	}
+	for range ch {
+	} // to consumed keepalive responses
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ErrLeaseExpired
+}
+
+func (r *EtcdRegistry) Deregister(ctx context.Context, lease LeaseID) error {
+	_, err := r.client.Revoke(ctx, clientv3.LeaseID(lease))
+	return err
+}
+
+func (r *EtcdRegistry) List(ctx context.Context) ([]Instance, int64, error) {
+	resp, err := r.client.Get(ctx, r.keyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+	var instances []Instance
+	for _, kv := range resp.Kvs {
+		inst, err := decodeInstance(string(kv.Value))
+		if err != nil {
+			log.Printf("Ignoring invalid registration %s: %v", kv.Key, err)
+			continue
+		}
+		instances = append(instances, inst)
+	}
+	return instances, resp.Header.Revision, nil
+}
+
+func (r *EtcdRegistry) Watch(ctx context.Context, rev int64) <-chan WatchResponse {
+	out := make(chan WatchResponse)
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		watchChan := r.client.Watch(clientv3.WithRequireLeader(ctx), r.keyPrefix,
+			clientv3.WithPrefix(), clientv3.WithRev(rev))
+		for watchResp := range watchChan {
+			if err := watchResp.Err(); err != nil {
+				if watchResp.CompactRevision != 0 {
+					err = ErrCompacted
+				}
+				sendWatchResponse(ctx, out, WatchResponse{Err: err})
+				return
+			}
+			var events []Event
+			for _, ev := range watchResp.Events {
+				// keys are keyPrefix + server address
+				addr := strings.TrimPrefix(string(ev.Kv.Key), r.keyPrefix)
+				event := Event{Instance: Instance{Addr: addr}, Revision: ev.Kv.ModRevision}
+				if ev.Type == clientv3.EventTypePut {
+					inst, err := decodeInstance(string(ev.Kv.Value))
+					if err != nil {
+						log.Printf("Ignoring invalid registration %s: %v", ev.Kv.Key, err)
+						continue
+					}
+					event.Type, event.Instance = EventPut, inst
+				} else {
+					event.Type = EventDelete
+				}
+				events = append(events, event)
+			}
+			if !sendWatchResponse(ctx, out, WatchResponse{Events: events}) {
+				return
+			}
+		}
+		sendWatchResponse(ctx, out, WatchResponse{Err: fmt.Errorf("etcd watch channel closed")})
+	}()
+	return out
+}
+
+func (r *EtcdRegistry) Close() error {
+	return r.client.Close()
+}
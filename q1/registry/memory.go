@@ -0,0 +1,222 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryHistoryLimit bounds the events kept for Watch. Watching from an older
+// revision fails with ErrCompacted, as it would with etcd.
+const memoryHistoryLimit = 1024
+
+// MemoryRegistry is an in-process Registry with etcd-like leases and
+// revisions. It lets the load balancer and backends run in one process, e.g.
+// in tests.
+type MemoryRegistry struct {
+	mutexLock   sync.Mutex
+	revision    int64
+	instances   map[string]memoryEntry
+	leases      map[LeaseID]*memoryLease
+	nextLease   LeaseID
+	history     []Event
+	subscribers map[chan struct{}]struct{}
+}
+
+type memoryEntry struct {
+	inst  Instance
+	lease LeaseID
+}
+
+type memoryLease struct {
+	ttl   time.Duration
+	timer *time.Timer
+	addrs map[string]struct{}
+	done  chan struct{}
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		instances:   make(map[string]memoryEntry),
+		leases:      make(map[LeaseID]*memoryLease),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+func (r *MemoryRegistry) Register(ctx context.Context, inst Instance, ttl time.Duration) (LeaseID, error) {
+	r.mutexLock.Lock()
+	defer r.mutexLock.Unlock()
+
+	r.nextLease++
+	id := r.nextLease
+	lease := &memoryLease{ttl: ttl, addrs: map[string]struct{}{inst.Addr: {}}, done: make(chan struct{})}
+	lease.timer = time.AfterFunc(ttl, func() { r.Revoke(id) })
+	r.leases[id] = lease
+
+	if old, exists := r.instances[inst.Addr]; exists && old.lease != id {
+		if oldLease, ok := r.leases[old.lease]; ok {
+			delete(oldLease.addrs, inst.Addr)
+		}
+	}
+	r.instances[inst.Addr] = memoryEntry{inst: inst, lease: id}
+	r.appendEvent(Event{Type: EventPut, Instance: inst})
+	return id, nil
+}
+
+// Revoke drops the lease and every instance attached to it.
+func (r *MemoryRegistry) Revoke(id LeaseID) {
+	r.mutexLock.Lock()
+	defer r.mutexLock.Unlock()
+
+	lease, exists := r.leases[id]
+	if !exists {
+		return
+	}
+	lease.timer.Stop()
+	close(lease.done)
+	delete(r.leases, id)
+
+	addrs := make([]string, 0, len(lease.addrs))
+	for addr := range lease.addrs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		delete(r.instances, addr)
+		r.appendEvent(Event{Type: EventDelete, Instance: Instance{Addr: addr}})
+	}
+}
+
+func (r *MemoryRegistry) KeepAlive(ctx context.Context, id LeaseID) error {
+	r.mutexLock.Lock()
+	lease, exists := r.leases[id]
+	r.mutexLock.Unlock()
+	if !exists {
+		return ErrLeaseExpired
+	}
+
+	ticker := time.NewTicker(lease.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-lease.done:
+			return ErrLeaseExpired
+		case <-ticker.C:
+			r.mutexLock.Lock()
+			if _, exists := r.leases[id]; exists {
+				lease.timer.Reset(lease.ttl)
+			}
+			r.mutexLock.Unlock()
+		}
+	}
+}
+
+func (r *MemoryRegistry) Deregister(ctx context.Context, id LeaseID) error {
+	r.Revoke(id)
+	return nil
+}
+
+func (r *MemoryRegistry) List(ctx context.Context) ([]Instance, int64, error) {
+	r.mutexLock.Lock()
+	defer r.mutexLock.Unlock()
+
+	addrs := make([]string, 0, len(r.instances))
+	for addr := range r.instances {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs) // etcd returns keys in order as well
+	instances := make([]Instance, 0, len(addrs))
+	for _, addr := range addrs {
+		instances = append(instances, r.instances[addr].inst)
+	}
+	return instances, r.revision, nil
+}
+
+func (r *MemoryRegistry) Watch(ctx context.Context, rev int64) <-chan WatchResponse {
+	out := make(chan WatchResponse)
+	notify := make(chan struct{}, 1)
+
+	r.mutexLock.Lock()
+	r.subscribers[notify] = struct{}{}
+	r.mutexLock.Unlock()
+
+	go func() {
+		defer close(out)
+		defer func() {
+			r.mutexLock.Lock()
+			delete(r.subscribers, notify)
+			r.mutexLock.Unlock()
+		}()
+
+		for {
+			events, err := r.eventsSince(rev)
+			if err != nil {
+				sendWatchResponse(ctx, out, WatchResponse{Err: err})
+				return
+			}
+			if len(events) > 0 {
+				if !sendWatchResponse(ctx, out, WatchResponse{Events: events}) {
+					return
+				}
+				rev = events[len(events)-1].Revision + 1
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *MemoryRegistry) Close() error {
+	r.mutexLock.Lock()
+	ids := make([]LeaseID, 0, len(r.leases))
+	for id := range r.leases {
+		ids = append(ids, id)
+	}
+	r.mutexLock.Unlock()
+
+	for _, id := range ids {
+		r.Revoke(id)
+	}
+	return nil
+}
+
+// appendEvent records ev at the next revision and wakes the watchers. The
+// caller must hold mutexLock.
+func (r *MemoryRegistry) appendEvent(ev Event) {
+	r.revision++
+	ev.Revision = r.revision
+	r.history = append(r.history, ev)
+	if len(r.history) > memoryHistoryLimit {
+		r.history = r.history[len(r.history)-memoryHistoryLimit:]
+	}
+	for notify := range r.subscribers {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (r *MemoryRegistry) eventsSince(rev int64) ([]Event, error) {
+	r.mutexLock.Lock()
+	defer r.mutexLock.Unlock()
+
+	if len(r.history) == 0 || rev > r.revision {
+		return nil, nil
+	}
+	oldest := r.history[0].Revision
+	if rev < oldest {
+		return nil, fmt.Errorf("%w (oldest revision %d)", ErrCompacted, oldest)
+	}
+	events := make([]Event, len(r.history)-int(rev-oldest))
+	copy(events, r.history[rev-oldest:])
+	return events, nil
+}
@@ -0,0 +1,241 @@
+// Package registry is the service registry shared by the q1 load balancer
+// and backend servers. Backends register themselves under a lease and keep it
+// alive; the load balancer lists the registered backends and watches for
+// changes.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultEtcdEndpoints = "localhost:2379"
+	DefaultKeyPrefix     = "/services/backend/"
+
+	// ServerAddrHeader is the response header in which a backend names
+	// itself, so callers behind the proxy can tell which backend served them.
+	ServerAddrHeader = "x-server-addr"
+
+	// MaxWeight is the largest weight an instance is given. Larger weights
+	// are clamped to it: the CH policy puts 100 points on its ring per
+	// unit of weight, so one huge weight would take all the memory.
+	MaxWeight = 100
+)
+
+var (
+	ErrCompacted    = errors.New("registry: requested revision has been compacted")
+	ErrLeaseExpired = errors.New("registry: lease expired")
+)
+
+// Instance is a registered backend server.
+type Instance struct {
+	Addr   string           `json:"addr"`
+	Weight int32            `json:"weight,omitempty"` // relative capacity, 1 if unset, at most MaxWeight
+	Tasks  []TaskCapability `json:"tasks,omitempty"`  // task types served, all if empty
+	// Zone and Region say where the backend runs, such as its rack and
+	// data center, so the load balancer can keep traffic local. Empty if
+	// unknown.
+	Zone   string `json:"zone,omitempty"`
+	Region string `json:"region,omitempty"`
+}
+
+// TaskCapability is a task type a backend serves and how many tasks of that
+// type it takes at once, queued or running.
+type TaskCapability struct {
+	TaskType      int32 `json:"taskType"`
+	MaxConcurrent int32 `json:"maxConcurrent,omitempty"` // 0 for no limit
+}
+
+// EffectiveWeight returns the instance weight, treating unset or invalid
+// weights as 1 and clamping it to MaxWeight.
+func (inst Instance) EffectiveWeight() int32 {
+	return min(max(inst.Weight, 1), MaxWeight)
+}
+
+// Supports reports whether the instance serves tasks of taskType.
+func (inst Instance) Supports(taskType int32) bool {
+	if len(inst.Tasks) == 0 {
+		return true
+	}
+	for _, capability := range inst.Tasks {
+		if capability.TaskType == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal reports whether two registrations are the same.
+func (inst Instance) Equal(other Instance) bool {
+	return inst.Addr == other.Addr && inst.Weight == other.Weight && slices.Equal(inst.Tasks, other.Tasks) &&
+		inst.Zone == other.Zone && inst.Region == other.Region
+}
+
+// ParseTasks parses a task list such as "0,1:4,2:1": task types separated by
+// commas, each optionally followed by its concurrency limit. An empty list
+// means all task types without limits.
+func ParseTasks(spec string) ([]TaskCapability, error) {
+	var tasks []TaskCapability
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		taskType, limit, hasLimit := strings.Cut(field, ":")
+		var capability TaskCapability
+		n, err := strconv.ParseInt(taskType, 10, 32)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("registry: invalid task type %q", taskType)
+		}
+		capability.TaskType = int32(n)
+		if hasLimit {
+			n, err = strconv.ParseInt(limit, 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("registry: invalid concurrency limit %q for task type %s", limit, taskType)
+			}
+			capability.MaxConcurrent = int32(n)
+		}
+		for _, seen := range tasks {
+			if seen.TaskType == capability.TaskType {
+				return nil, fmt.Errorf("registry: task type %d listed twice", capability.TaskType)
+			}
+		}
+		tasks = append(tasks, capability)
+	}
+	return tasks, nil
+}
+
+// FormatTasks is the inverse of ParseTasks.
+func FormatTasks(tasks []TaskCapability) string {
+	fields := make([]string, 0, len(tasks))
+	for _, capability := range tasks {
+		field := fmt.Sprint(capability.TaskType)
+		if capability.MaxConcurrent > 0 {
+			field += fmt.Sprintf(":%d", capability.MaxConcurrent)
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, ",")
+}
+
+type LeaseID int64
+
+type EventType int
+
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+// Event is a single change to the set of registered instances. For
+// EventDelete only Instance.Addr is set.
+type Event struct {
+	Type     EventType
+	Instance Instance
+	Revision int64
+}
+
+// WatchResponse is a batch of events. A response with a non-nil Err is the
+// last one sent on the channel; the caller should List again and start a new
+// watch from the returned revision.
+type WatchResponse struct {
+	Events []Event
+	Err    error
+}
+
+type Registry interface {
+	// Register publishes inst under a new lease with the given TTL.
+	Register(ctx context.Context, inst Instance, ttl time.Duration) (LeaseID, error)
+	// KeepAlive refreshes the lease until ctx is cancelled or the lease is
+	// lost, in which case it returns ErrLeaseExpired or the underlying error.
+	KeepAlive(ctx context.Context, lease LeaseID) error
+	// Deregister revokes the lease, removing the instances registered under
+	// it without waiting for the TTL.
+	Deregister(ctx context.Context, lease LeaseID) error
+	// List returns the registered instances and the revision they were read
+	// at. Watching from revision+1 yields every later change.
+	List(ctx context.Context) ([]Instance, int64, error)
+	// Watch streams changes starting at rev until ctx is cancelled or the
+	// watch fails.
+	Watch(ctx context.Context, rev int64) <-chan WatchResponse
+	Close() error
+}
+
+// Config selects and configures a Registry implementation.
+type Config struct {
+	Kind          string // etcd, static or memory
+	EtcdEndpoints string // comma separated
+	File          string // instance list for the static registry
+	KeyPrefix     string
+}
+
+// AddFlags registers the registry command line flags on fs.
+func (c *Config) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Kind, "registry", "etcd", "service registry: etcd, static or memory")
+	fs.StringVar(&c.EtcdEndpoints, "etcd-endpoints", DefaultEtcdEndpoints, "comma separated etcd endpoints")
+	fs.StringVar(&c.File, "registry-file", "backends.txt", "backend list used by the static registry")
+	fs.StringVar(&c.KeyPrefix, "registry-prefix", DefaultKeyPrefix, "etcd key prefix for backend registrations")
+}
+
+func Open(c Config) (Registry, error) {
+	switch c.Kind {
+	case "etcd":
+		return NewEtcdRegistry(strings.Split(c.EtcdEndpoints, ","), c.KeyPrefix)
+	case "static":
+		return NewStaticRegistry(c.File), nil
+	case "memory":
+		return NewMemoryRegistry(), nil
+	}
+	return nil, fmt.Errorf("unknown registry %q, use 'etcd', 'static' or 'memory'", c.Kind)
+}
+
+// sendWatchResponse delivers resp unless ctx is done first.
+func sendWatchResponse(ctx context.Context, out chan<- WatchResponse, resp WatchResponse) bool {
+	select {
+	case out <- resp:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// encodeInstance and decodeInstance convert an Instance to and from the
+// value stored for it in etcd and in the static file. Values are JSON
+// objects; a bare address (the format used before weights were added) is
+// read as an instance with the default weight. Weights above MaxWeight are
+// clamped.
+func encodeInstance(inst Instance) string {
+	value, _ := json.Marshal(inst)
+	return string(value)
+}
+
+func decodeInstance(value string) (Instance, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Instance{}, errors.New("registry: empty instance value")
+	}
+	if !strings.HasPrefix(value, "{") {
+		return Instance{Addr: value}, nil
+	}
+	var inst Instance
+	if err := json.Unmarshal([]byte(value), &inst); err != nil {
+		return Instance{}, fmt.Errorf("registry: invalid instance value: %w", err)
+	}
+	if inst.Addr == "" {
+		return Instance{}, errors.New("registry: instance value has no address")
+	}
+	if inst.Weight > MaxWeight {
+		log.Printf("Clamping the weight %d of %s to %d", inst.Weight, inst.Addr, MaxWeight)
+		inst.Weight = MaxWeight
+	}
+	return inst, nil
+}
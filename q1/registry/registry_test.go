@@ -0,0 +1,276 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const watchTestTimeout = 5 * time.Second
+
+// nextEvents waits for the next watch response and returns its events.
+func nextEvents(t *testing.T, watch <-chan WatchResponse) []Event {
+	t.Helper()
+	select {
+	case resp, ok := <-watch:
+		if !ok {
+			t.Fatal("watch channel closed, want events")
+		}
+		if resp.Err != nil {
+			t.Fatalf("watch failed: %v", resp.Err)
+		}
+		return resp.Events
+	case <-time.After(watchTestTimeout):
+		t.Fatal("no watch response")
+	}
+	return nil
+}
+
+// checkEvents fails t unless got are events of want's types for want's
+// addresses, in order and at increasing revisions.
+func checkEvents(t *testing.T, got []Event, want ...Event) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].Instance.Addr != want[i].Instance.Addr {
+			t.Errorf("event %d = %v %s, want %v %s", i, got[i].Type, got[i].Instance.Addr, want[i].Type, want[i].Instance.Addr)
+		}
+		if i > 0 && got[i].Revision <= got[i-1].Revision {
+			t.Errorf("event %d at revision %d, after revision %d", i, got[i].Revision, got[i-1].Revision)
+		}
+	}
+}
+
+func putEvent(addr string) Event    { return Event{Type: EventPut, Instance: Instance{Addr: addr}} }
+func deleteEvent(addr string) Event { return Event{Type: EventDelete, Instance: Instance{Addr: addr}} }
+
+func TestMemoryRegistryListAndWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewMemoryRegistry()
+	defer r.Close()
+
+	leaseB, _ := r.Register(ctx, Instance{Addr: "b", Weight: 2}, time.Minute)
+	r.Register(ctx, Instance{Addr: "a"}, time.Minute)
+	instances, rev, err := r.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(instances) != 2 || instances[0].Addr != "a" || instances[1].Addr != "b" || instances[1].Weight != 2 {
+		t.Errorf("List = %v, want a and b in order", instances)
+	}
+	if rev != 2 {
+		t.Errorf("List revision = %d, want 2", rev)
+	}
+
+	// a watch from revision 1 replays both registrations
+	checkEvents(t, nextEvents(t, r.Watch(ctx, 1)), putEvent("b"), putEvent("a"))
+
+	watch := r.Watch(ctx, rev+1)
+	if err := r.Deregister(ctx, leaseB); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	checkEvents(t, nextEvents(t, watch), deleteEvent("b"))
+	r.Register(ctx, Instance{Addr: "c"}, time.Minute)
+	events := nextEvents(t, watch)
+	checkEvents(t, events, putEvent("c"))
+	if events[0].Revision != 4 {
+		t.Errorf("c registered at revision %d, want 4", events[0].Revision)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-watch:
+		if ok {
+			t.Error("watch sent a response after its context ended")
+		}
+	case <-time.After(watchTestTimeout):
+		t.Error("watch channel not closed after its context ended")
+	}
+}
+
+func TestMemoryRegistryLeaseExpires(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRegistry()
+	defer r.Close()
+
+	watch := r.Watch(ctx, 1)
+	lease, _ := r.Register(ctx, Instance{Addr: "kept"}, 100*time.Millisecond)
+	r.Register(ctx, Instance{Addr: "expired"}, 100*time.Millisecond)
+	keepAliveCtx, stopKeepAlive := context.WithCancel(ctx)
+	defer stopKeepAlive()
+	go r.KeepAlive(keepAliveCtx, lease)
+
+	var events []Event
+	for len(events) < 3 {
+		events = append(events, nextEvents(t, watch)...)
+	}
+	checkEvents(t, events, putEvent("kept"), putEvent("expired"), deleteEvent("expired"))
+	if instances, _, _ := r.List(ctx); len(instances) != 1 || instances[0].Addr != "kept" {
+		t.Errorf("List = %v, want only the backend keeping its lease alive", instances)
+	}
+}
+
+func TestMemoryRegistryClose(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRegistry()
+	leaseA, _ := r.Register(ctx, Instance{Addr: "a"}, time.Minute)
+	r.Register(ctx, Instance{Addr: "b"}, time.Minute)
+	watch := r.Watch(ctx, 3)
+	keepAlive := make(chan error, 1)
+	go func() { keepAlive <- r.KeepAlive(ctx, leaseA) }()
+
+	r.Close()
+	var events []Event
+	for len(events) < 2 {
+		events = append(events, nextEvents(t, watch)...)
+	}
+	// leases are revoked in no particular order
+	deleted := make(map[string]bool)
+	for _, ev := range events {
+		deleted[ev.Instance.Addr] = ev.Type == EventDelete
+	}
+	if len(events) != 2 || !deleted["a"] || !deleted["b"] {
+		t.Errorf("Close gave events %v, want a and b deleted", events)
+	}
+	select {
+	case err := <-keepAlive:
+		if !errors.Is(err, ErrLeaseExpired) {
+			t.Errorf("KeepAlive after Close returned %v, want ErrLeaseExpired", err)
+		}
+	case <-time.After(watchTestTimeout):
+		t.Error("KeepAlive still running after Close")
+	}
+	if instances, _, _ := r.List(ctx); len(instances) != 0 {
+		t.Errorf("List after Close = %v, want none", instances)
+	}
+}
+
+func TestMemoryRegistryWatchCompacted(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRegistry()
+	defer r.Close()
+	for i := 0; i <= memoryHistoryLimit; i++ {
+		r.Register(ctx, Instance{Addr: "a"}, time.Minute)
+	}
+	select {
+	case resp := <-r.Watch(ctx, 1):
+		if !errors.Is(resp.Err, ErrCompacted) {
+			t.Errorf("watch from a compacted revision gave %v, want ErrCompacted", resp.Err)
+		}
+	case <-time.After(watchTestTimeout):
+		t.Error("no watch response")
+	}
+}
+
+// writeRegistryFile replaces the file at path in one step, so a watch never
+// reads it half written.
+func writeRegistryFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path+".tmp", []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStaticRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "backends.txt")
+	writeRegistryFile(t, path, "# backends\nlocalhost:6001\n"+`{"addr":"localhost:6002","weight":2,"zone":"rack1"}`+"\n")
+	r := NewStaticRegistry(path)
+	defer r.Close()
+
+	instances, _, err := r.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(instances) != 2 || instances[0].Addr != "localhost:6001" || instances[1].Weight != 2 || instances[1].Zone != "rack1" {
+		t.Errorf("List = %v, want the two listed backends", instances)
+	}
+	// registration and deregistration leave the file alone
+	lease, err := r.Register(ctx, Instance{Addr: "localhost:6009"}, time.Minute)
+	if err != nil {
+		t.Errorf("Register of an unlisted backend failed: %v", err)
+	}
+	r.Deregister(ctx, lease)
+	if instances, _, _ := r.List(ctx); len(instances) != 2 {
+		t.Errorf("List after Register and Deregister = %v, want the file's backends", instances)
+	}
+
+	// editing the file reports the changes: puts in file order, then the
+	// backends that are gone
+	watch := r.Watch(ctx, 0)
+	writeRegistryFile(t, path, `{"addr":"localhost:6002","weight":3}`+"\nlocalhost:6003\n")
+	events := nextEvents(t, watch)
+	checkEvents(t, events, putEvent("localhost:6002"), putEvent("localhost:6003"), deleteEvent("localhost:6001"))
+	if events[0].Instance.Weight != 3 {
+		t.Errorf("changed backend put with weight %d, want 3", events[0].Instance.Weight)
+	}
+
+	// a file that can no longer be read ends the watch with an error
+	os.Remove(path)
+	select {
+	case resp := <-watch:
+		if resp.Err == nil {
+			t.Errorf("watch of a removed file gave %v, want an error", resp)
+		}
+	case <-time.After(watchTestTimeout):
+		t.Fatal("no watch response")
+	}
+	if _, ok := <-watch; ok {
+		t.Error("watch channel still open after its error")
+	}
+}
+
+func TestDecodeInstanceWeight(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int32 // EffectiveWeight
+	}{
+		{"localhost:6001", 1},
+		{`{"addr":"localhost:6001","weight":0}`, 1},
+		{`{"addr":"localhost:6001","weight":-3}`, 1},
+		{`{"addr":"localhost:6001","weight":7}`, 7},
+		{`{"addr":"localhost:6001","weight":100}`, MaxWeight},
+		{`{"addr":"localhost:6001","weight":2000000000}`, MaxWeight},
+	}
+	for _, tt := range tests {
+		inst, err := decodeInstance(tt.value)
+		if err != nil {
+			t.Fatalf("decodeInstance(%s) failed: %v", tt.value, err)
+		}
+		if inst.Weight > MaxWeight || inst.EffectiveWeight() != tt.want {
+			t.Errorf("decodeInstance(%s) weight %d, effective %d, want %d", tt.value, inst.Weight, inst.EffectiveWeight(), tt.want)
+		}
+	}
+	if got := (Instance{Weight: MaxWeight + 1}).EffectiveWeight(); got != MaxWeight {
+		t.Errorf("EffectiveWeight of a registered weight above the maximum = %d, want %d", got, MaxWeight)
+	}
+}
+
+func TestLeaseSeconds(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{0, 1},
+		{time.Millisecond, 1},
+		{500 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{10 * time.Second, 10},
+	}
+	for _, tt := range tests {
+		if got := leaseSeconds(tt.ttl); got != tt.want {
+			t.Errorf("leaseSeconds(%v) = %d, want %d", tt.ttl, got, tt.want)
+		}
+	}
+}
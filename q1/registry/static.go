@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const staticPollInterval = time.Second
+
+// StaticRegistry serves a fixed list of backends from a file, one instance per
+// line as a bare address or a JSON object such as
+//
+//	{"addr":"localhost:50401","weight":2,"zone":"rack1","region":"dc1"}
+//
+// ('#' starts a comment). The file is re-read while watching, so editing it
+// adds or removes backends. Registration is a no-op: backends using it must
+// listen on an address listed in the file.
+type StaticRegistry struct {
+	path string
+
+	mutexLock sync.Mutex
+	revision  int64
+	current   map[string]Instance
+}
+
+func NewStaticRegistry(path string) *StaticRegistry {
+	return &StaticRegistry{path: path}
+}
+
+func (r *StaticRegistry) Register(ctx context.Context, inst Instance, ttl time.Duration) (LeaseID, error) {
+	instances, _, err := r.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, listed := range instances {
+		if listed.Addr == inst.Addr {
+			return 0, nil
+		}
+	}
+	log.Printf("Warning: %s is not listed in %s, the load balancer will not use it", inst.Addr, r.path)
+	return 0, nil
+}
+
+func (r *StaticRegistry) KeepAlive(ctx context.Context, lease LeaseID) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Deregister does nothing: the file lists the backend until someone edits
+// it. The load balancer stops routing to a backend that reports draining.
+func (r *StaticRegistry) Deregister(ctx context.Context, lease LeaseID) error {
+	return nil
+}
+
+func (r *StaticRegistry) List(ctx context.Context) ([]Instance, int64, error) {
+	instances, err := r.readFile()
+	if err != nil {
+		return nil, 0, err
+	}
+	r.mutexLock.Lock()
+	defer r.mutexLock.Unlock()
+	r.update(instances)
+	return instances, r.revision, nil
+}
+
+func (r *StaticRegistry) Watch(ctx context.Context, rev int64) <-chan WatchResponse {
+	out := make(chan WatchResponse)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(staticPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			instances, err := r.readFile()
+			if err != nil {
+				sendWatchResponse(ctx, out, WatchResponse{Err: err})
+				return
+			}
+			r.mutexLock.Lock()
+			events := r.update(instances)
+			r.mutexLock.Unlock()
+			if len(events) > 0 && !sendWatchResponse(ctx, out, WatchResponse{Events: events}) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *StaticRegistry) Close() error {
+	return nil
+}
+
+// update makes instances the current set and returns the events between the
+// previous set and it. The caller must hold mutexLock.
+func (r *StaticRegistry) update(instances []Instance) []Event {
+	next := make(map[string]Instance, len(instances))
+	var events []Event
+	for _, inst := range instances {
+		next[inst.Addr] = inst
+		if old, exists := r.current[inst.Addr]; !exists || !old.Equal(inst) {
+			events = append(events, Event{Type: EventPut, Instance: inst})
+		}
+	}
+	for addr := range r.current {
+		if _, exists := next[addr]; !exists {
+			events = append(events, Event{Type: EventDelete, Instance: Instance{Addr: addr}})
+		}
+	}
+	if r.current == nil {
+		events = nil // first read, nothing to report
+	}
+	for i := range events {
+		r.revision++
+		events[i].Revision = r.revision
+	}
+	r.current = next
+	return events
+}
+
+func (r *StaticRegistry) readFile() ([]Instance, error) {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry file: %w", err)
+	}
+	defer file.Close()
+
+	var instances []Instance
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		inst, err := decodeInstance(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.path, err)
+		}
+		instances = append(instances, inst)
+	}
+	return instances, scanner.Err()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc/status"
+)
+
+// ResultCache remembers the results of recent tasks. Every task is a pure
+// function of its type and argument, so a result never goes stale; the
+// least recently used result is evicted once capacity is reached.
+// Concurrent requests for a result that is being computed wait for that
+// computation instead of starting their own.
+type ResultCache struct {
+	mutexLock sync.Mutex
+	capacity  int
+	disabled  map[lbproto.TaskType]bool
+	entries   map[cacheKey]*list.Element // values are *cacheEntry
+	order     *list.List                 // most recently used first
+	calls     map[cacheKey]*cacheCall
+
+	hits, misses, shared, evictions uint64
+}
+
+type cacheKey struct {
+	taskType lbproto.TaskType
+	num      int64
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	output int64
+}
+
+// cacheCall is a computation shared by the requests waiting for it. It is
+// cancelled when the last of them gives up.
+type cacheCall struct {
+	done    chan struct{}
+	output  int64
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// NewResultCache returns a cache of capacity results. A capacity of 0
+// disables caching, as does listing a task type in disabled.
+func NewResultCache(capacity int, disabled []lbproto.TaskType) *ResultCache {
+	c := &ResultCache{
+		capacity: capacity,
+		disabled: make(map[lbproto.TaskType]bool),
+		entries:  make(map[cacheKey]*list.Element),
+		order:    list.New(),
+		calls:    make(map[cacheKey]*cacheCall),
+	}
+	for _, taskType := range disabled {
+		c.disabled[taskType] = true
+	}
+	return c
+}
+
+// ParseTaskTypes parses a comma separated list of task type names or
+// numbers, such as "sort,3".
+func ParseTaskTypes(list string) ([]lbproto.TaskType, error) {
+	var types []lbproto.TaskType
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if value, exists := lbproto.TaskType_value[strings.ToUpper(field)]; exists {
+			types = append(types, lbproto.TaskType(value))
+			continue
+		}
+		value, err := strconv.ParseInt(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unknown task type %q", field)
+		}
+		types = append(types, lbproto.TaskType(value))
+	}
+	return types, nil
+}
+
+func (c *ResultCache) enabled(taskType lbproto.TaskType) bool {
+	return c.capacity > 0 && !c.disabled[taskType]
+}
+
+// Do returns the cached result of a task or computes it with compute,
+// sharing the computation with concurrent callers asking for the same
+// result. cached reports whether the result came from the cache. Failed
+// computations are not cached.
+func (c *ResultCache) Do(ctx context.Context, taskType lbproto.TaskType, num int64, compute func(ctx context.Context) (int64, error)) (output int64, cached bool, err error) {
+	if !c.enabled(taskType) {
+		output, err = compute(ctx)
+		return output, false, err
+	}
+	key := cacheKey{taskType, num}
+
+	c.mutexLock.Lock()
+	if output, ok := c.lookup(key); ok {
+		c.mutexLock.Unlock()
+		return output, true, nil
+	}
+	call, exists := c.calls[key]
+	if exists {
+		c.shared++
+		call.waiters++
+	} else {
+		c.misses++
+		callCtx, cancel := context.WithCancel(context.Background())
+		call = &cacheCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.calls[key] = call
+		go c.run(callCtx, key, call, compute)
+	}
+	c.mutexLock.Unlock()
+
+	select {
+	case <-call.done:
+		return call.output, false, call.err
+	case <-ctx.Done():
+		c.mutexLock.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// nobody wants the result any more; later requests start afresh
+			call.cancel()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		}
+		c.mutexLock.Unlock()
+		return 0, false, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (c *ResultCache) run(ctx context.Context, key cacheKey, call *cacheCall, compute func(ctx context.Context) (int64, error)) {
+	output, err := compute(ctx)
+
+	c.mutexLock.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	if err == nil {
+		c.add(key, output)
+	}
+	c.mutexLock.Unlock()
+
+	call.output, call.err = output, err
+	call.cancel()
+	close(call.done)
+}
+
+// Get returns a cached result without computing it.
+func (c *ResultCache) Get(taskType lbproto.TaskType, num int64) (int64, bool) {
+	if !c.enabled(taskType) {
+		return 0, false
+	}
+	c.mutexLock.Lock()
+	defer c.mutexLock.Unlock()
+
+	output, ok := c.lookup(cacheKey{taskType, num})
+	if !ok {
+		c.misses++
+	}
+	return output, ok
+}
+
+// Add caches a result computed outside Do.
+func (c *ResultCache) Add(taskType lbproto.TaskType, num int64, output int64) {
+	if !c.enabled(taskType) {
+		return
+	}
+	c.mutexLock.Lock()
+	defer c.mutexLock.Unlock()
+	c.add(cacheKey{taskType, num}, output)
+}
+
+// lookup returns a cached result and marks it used. The caller must hold
+// mutexLock.
+func (c *ResultCache) lookup(key cacheKey) (int64, bool) {
+	elem, exists := c.entries[key]
+	if !exists {
+		return 0, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).output, true
+}
+
+// add caches a result, evicting the least recently used one if the cache is
+// full. The caller must hold mutexLock.
+func (c *ResultCache) add(key cacheKey, output int64) {
+	if elem, exists := c.entries[key]; exists {
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, output: output})
+}
+
+// Stats returns the cache counters.
+func (c *ResultCache) Stats() *lbproto.CacheStats {
+	c.mutexLock.Lock()
+	defer c.mutexLock.Unlock()
+
+	return &lbproto.CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Shared:    c.shared,
+		Evictions: c.evictions,
+		Entries:   int32(c.order.Len()),
+		Capacity:  int32(c.capacity),
+	}
+}
+
+func (s *BackendServer) GetCacheStats(ctx context.Context, req *lbproto.Empty) (*lbproto.CacheStats, error) {
+	return resultCache.Stats(), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func constant(output int64, calls *atomic.Int32) func(context.Context) (int64, error) {
+	return func(context.Context) (int64, error) {
+		calls.Add(1)
+		return output, nil
+	}
+}
+
+func TestResultCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewResultCache(2, nil)
+	ctx := context.Background()
+	var calls atomic.Int32
+	fib := lbproto.TaskType_FIBONACCI
+
+	cache.Do(ctx, fib, 1, constant(1, &calls))
+	cache.Do(ctx, fib, 2, constant(1, &calls))
+	cache.Do(ctx, fib, 1, constant(1, &calls)) // 2 is now least recently used
+	cache.Do(ctx, fib, 3, constant(2, &calls))
+
+	if _, cached := cache.Get(fib, 1); !cached {
+		t.Errorf("recently used result evicted")
+	}
+	if _, cached := cache.Get(fib, 2); cached {
+		t.Errorf("least recently used result kept")
+	}
+	stats := cache.Stats()
+	if calls.Load() != 3 || stats.GetHits() != 2 || stats.GetEvictions() != 1 || stats.GetEntries() != 2 {
+		t.Errorf("computed %d times, stats %v; want 3 computations, 2 hits, 1 eviction, 2 entries", calls.Load(), stats)
+	}
+}
+
+func TestResultCacheSharesConcurrentComputations(t *testing.T) {
+	cache := NewResultCache(8, nil)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	compute := func(context.Context) (int64, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			output, _, err := cache.Do(context.Background(), lbproto.TaskType_SORT, 10, compute)
+			if output != 42 || err != nil {
+				t.Errorf("Do = %d, %v, want 42", output, err)
+			}
+		}()
+	}
+	// let every caller join before the computation finishes
+	for cache.Stats().GetShared() < callers-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("computed %d times for %d concurrent callers, want 1", calls.Load(), callers)
+	}
+}
+
+func TestResultCacheCancelsWhenEveryCallerLeaves(t *testing.T) {
+	cache := NewResultCache(8, nil)
+	cancelled := make(chan struct{})
+	compute := func(ctx context.Context) (int64, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return 0, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err := cache.Do(ctx, lbproto.TaskType_FIBONACCI, 50, compute)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("Do error = %v, want code %v", err, codes.DeadlineExceeded)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("computation kept running after its only caller left")
+	}
+	if _, cached := cache.Get(lbproto.TaskType_FIBONACCI, 50); cached {
+		t.Error("failed computation was cached")
+	}
+}
+
+func TestResultCacheDisabledTaskType(t *testing.T) {
+	cache := NewResultCache(8, []lbproto.TaskType{lbproto.TaskType_SORT})
+	var calls atomic.Int32
+	for i := 0; i < 2; i++ {
+		cache.Do(context.Background(), lbproto.TaskType_SORT, 10, constant(1, &calls))
+		cache.Do(context.Background(), lbproto.TaskType_SUM, 10, constant(55, &calls))
+	}
+	if calls.Load() != 3 {
+		t.Errorf("computed %d times, want 3: sort twice, sum once", calls.Load())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+
+	"q1/registry"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TaskLimiter enforces the task types and per-type concurrency limits this
+// server registered with. The load balancer only routes supported task types
+// here, but clients with a stale view or talking to the server directly may
+// not. A limit covers the tasks of its type waiting in the worker pool's
+// queue as well as the running ones, so one task type cannot fill the queue
+// either.
+type TaskLimiter struct {
+	mutexLock sync.Mutex
+	tasks     []registry.TaskCapability // empty means every type, no limits
+	admitted  map[int32]int32           // tasks queued or running, by type
+}
+
+func NewTaskLimiter(tasks []registry.TaskCapability) *TaskLimiter {
+	return &TaskLimiter{tasks: tasks, admitted: make(map[int32]int32)}
+}
+
+// acquire admits a task of taskType, or returns Unimplemented if the type
+// is not served and ResourceExhausted if its limit is reached. An admitted
+// task must be released.
+func (l *TaskLimiter) acquire(taskType int32) error {
+	if len(l.tasks) == 0 {
+		return nil
+	}
+	l.mutexLock.Lock()
+	defer l.mutexLock.Unlock()
+
+	for _, capability := range l.tasks {
+		if capability.TaskType != taskType {
+			continue
+		}
+		if capability.MaxConcurrent > 0 && l.admitted[taskType] >= capability.MaxConcurrent {
+			return status.Errorf(codes.ResourceExhausted, "server %s already has %d tasks of type %d queued or running", serverAddr, capability.MaxConcurrent, taskType)
+		}
+		l.admitted[taskType]++
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "server %s does not serve task type %d", serverAddr, taskType)
+}
+
+func (l *TaskLimiter) release(taskType int32) {
+	if len(l.tasks) == 0 {
+		return
+	}
+	l.mutexLock.Lock()
+	l.admitted[taskType]--
+	l.mutexLock.Unlock()
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchInterval is how often WatchTask reports the progress of a running job.
+const watchInterval = time.Second
+
+// Job is a task submitted with SubmitTask. It runs on the worker pool like
+// any other request, but under its own context rather than the RPC's, so it
+// outlives the call that submitted it.
+type Job struct {
+	id       string
+	taskType lbproto.TaskType
+	num      int64
+	spec     *TaskSpec
+	cancel   context.CancelFunc
+
+	// guarded by JobManager.mutexLock
+	state     lbproto.TaskState
+	started   time.Time
+	output    int64
+	err       error
+	expiresAt time.Time
+	changed   chan struct{} // closed and replaced on every state change
+}
+
+func (job *Job) finished() bool {
+	return job.state != lbproto.TaskState_QUEUED && job.state != lbproto.TaskState_RUNNING
+}
+
+// JobManager keeps the jobs of this backend. Finished jobs are kept for
+// resultTTL so clients can come back for the result.
+type JobManager struct {
+	mutexLock    sync.Mutex
+	jobs         map[string]*Job
+	resultTTL    time.Duration
+	running      sync.WaitGroup // jobs queued or running
+	shuttingDown bool           // no more jobs are taken, guarded by mutexLock
+}
+
+func NewJobManager(resultTTL time.Duration) *JobManager {
+	return &JobManager{jobs: make(map[string]*Job), resultTTL: resultTTL}
+}
+
+// newJobID returns a job ID naming this server, so the load balancer can
+// route calls about the job here.
+func newJobID() string {
+	var id [8]byte
+	crand.Read(id[:])
+	return serverAddr + "/" + hex.EncodeToString(id[:])
+}
+
+// Submit validates and queues a task. It fails like BackendRPC does if the
+// task is invalid or the server cannot take it.
+func (m *JobManager) Submit(req *lbproto.BackendRequest) (*lbproto.TaskStatus, error) {
+	spec, num, err := prepareTask(req.GetTaskType(), req.GetNum(), req.GetUseDefault())
+	if err != nil {
+		return nil, err
+	}
+	if output, cached := resultCache.Get(req.GetTaskType(), num); cached {
+		job := m.newJob(req.GetTaskType(), num, spec, func() {})
+		m.finish(job, output, nil)
+		log.Printf("Job %s submitted: %v, N : %d, served from cache", job.id, job.taskType, num)
+		return m.Status(job.id)
+	}
+	// taken under the lock so that no job is added once Shutdown waits
+	m.mutexLock.Lock()
+	if m.shuttingDown {
+		m.mutexLock.Unlock()
+		return nil, status.Errorf(codes.Unavailable, "server %s is shutting down", serverAddr)
+	}
+	m.running.Add(1)
+	m.mutexLock.Unlock()
+	if err := taskLimiter.acquire(int32(req.GetTaskType())); err != nil {
+		m.running.Done()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := m.newJob(req.GetTaskType(), num, spec, cancel)
+
+	requestStats.begin()
+	start := time.Now()
+	done, err := workerPool.Submit(ctx, func() {
+		m.setRunning(job)
+		output, err := spec.Run(ctx, num)
+		if err == nil {
+			resultCache.Add(job.taskType, num, output)
+		}
+		m.finish(job, output, err)
+	})
+	if err != nil {
+		m.mutexLock.Lock()
+		delete(m.jobs, job.id)
+		m.mutexLock.Unlock()
+		cancel()
+		taskLimiter.release(int32(job.taskType))
+		requestStats.end(0, err)
+		m.running.Done()
+		return nil, err
+	}
+
+	go func() {
+		defer m.running.Done()
+		<-done
+		// the worker skips a job cancelled while queued
+		m.finish(job, 0, ctx.Err())
+		taskLimiter.release(int32(job.taskType))
+		m.mutexLock.Lock()
+		err := job.err
+		m.mutexLock.Unlock()
+		requestStats.end(time.Since(start), err)
+	}()
+
+	log.Printf("Job %s submitted: %v, N : %d, estimated cost %.0fms", job.id, job.taskType, num, spec.Cost(num))
+	return m.Status(job.id)
+}
+
+// newJob records a queued job.
+func (m *JobManager) newJob(taskType lbproto.TaskType, num int64, spec *TaskSpec, cancel context.CancelFunc) *Job {
+	job := &Job{
+		id:       newJobID(),
+		taskType: taskType,
+		num:      num,
+		spec:     spec,
+		cancel:   cancel,
+		state:    lbproto.TaskState_QUEUED,
+		changed:  make(chan struct{}),
+	}
+	m.mutexLock.Lock()
+	m.jobs[job.id] = job
+	m.mutexLock.Unlock()
+	return job
+}
+
+func (m *JobManager) setRunning(job *Job) {
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+	if job.state == lbproto.TaskState_QUEUED {
+		job.state, job.started = lbproto.TaskState_RUNNING, time.Now()
+		m.notify(job)
+	}
+}
+
+// finish records the outcome of a job, unless it already has one, and
+// schedules it to be forgotten after resultTTL.
+func (m *JobManager) finish(job *Job, output int64, err error) {
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+	if job.finished() {
+		return
+	}
+	switch {
+	case err == nil:
+		job.state, job.output = lbproto.TaskState_SUCCEEDED, output
+	case errors.Is(err, context.Canceled):
+		job.state, job.err = lbproto.TaskState_CANCELLED, err
+	default:
+		job.state, job.err = lbproto.TaskState_FAILED, err
+	}
+	job.cancel()
+	job.expiresAt = time.Now().Add(m.resultTTL)
+	m.notify(job)
+	log.Printf("Job %s %v", job.id, job.state)
+
+	time.AfterFunc(m.resultTTL, func() {
+		m.mutexLock.Lock()
+		delete(m.jobs, job.id)
+		m.mutexLock.Unlock()
+	})
+}
+
+// notify wakes the watchers of job. The caller must hold mutexLock.
+func (m *JobManager) notify(job *Job) {
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+func (m *JobManager) lookup(jobID string) (*Job, error) {
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "no job %s, it may have expired", jobID)
+	}
+	return job, nil
+}
+
+func (m *JobManager) Status(jobID string) (*lbproto.TaskStatus, error) {
+	job, err := m.lookup(jobID)
+	if err != nil {
+		return nil, err
+	}
+	taskStatus, _ := m.status(job)
+	return taskStatus, nil
+}
+
+// status returns the status of job and a channel closed when it changes.
+func (m *JobManager) status(job *Job) (*lbproto.TaskStatus, <-chan struct{}) {
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+
+	taskStatus := &lbproto.TaskStatus{
+		JobId:    job.id,
+		TaskType: job.taskType,
+		Num:      job.num,
+		State:    job.state,
+		Output:   job.output,
+	}
+	switch job.state {
+	case lbproto.TaskState_RUNNING:
+		// the cost hint is only an estimate, so never claim to be done
+		elapsed := float64(time.Since(job.started).Milliseconds())
+		taskStatus.Progress = float32(min(0.99, elapsed/max(1, job.spec.Cost(job.num))))
+	case lbproto.TaskState_SUCCEEDED:
+		taskStatus.Progress = 1
+	}
+	if job.err != nil {
+		taskStatus.Error = job.err.Error()
+	}
+	if !job.expiresAt.IsZero() {
+		taskStatus.ExpiresAt = job.expiresAt.UnixNano()
+	}
+	return taskStatus, job.changed
+}
+
+// Cancel stops a queued or running job. A running task stops at its next
+// context check, so the returned status may still say RUNNING.
+func (m *JobManager) Cancel(jobID string) (*lbproto.TaskStatus, error) {
+	job, err := m.lookup(jobID)
+	if err != nil {
+		return nil, err
+	}
+	job.cancel()
+	m.mutexLock.Lock()
+	queued := job.state == lbproto.TaskState_QUEUED
+	m.mutexLock.Unlock()
+	if queued {
+		m.finish(job, 0, context.Canceled)
+	}
+	return m.Status(jobID)
+}
+
+// Watch sends the status of a job until it has finished or ctx is done.
+func (m *JobManager) Watch(ctx context.Context, jobID string, send func(*lbproto.TaskStatus) error) error {
+	job, err := m.lookup(jobID)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		taskStatus, changed := m.status(job)
+		if err := send(taskStatus); err != nil {
+			return err
+		}
+		if taskStatus.GetExpiresAt() != 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops taking jobs and waits for the queued and running ones to
+// finish. When ctx is done first it cancels the jobs left.
+func (m *JobManager) Shutdown(ctx context.Context) {
+	m.mutexLock.Lock()
+	m.shuttingDown = true
+	m.mutexLock.Unlock()
+	finished := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return
+	case <-ctx.Done():
+	}
+
+	var pending []*Job
+	m.mutexLock.Lock()
+	for _, job := range m.jobs {
+		if !job.finished() {
+			pending = append(pending, job)
+		}
+	}
+	m.mutexLock.Unlock()
+	log.Printf("Cancelling %d unfinished jobs", len(pending))
+	for _, job := range pending {
+		m.Cancel(job.id)
+	}
+}
+
+func (s *BackendServer) SubmitTask(ctx context.Context, req *lbproto.BackendRequest) (*lbproto.TaskStatus, error) {
+	taskStatus, err := jobManager.Submit(req)
+	if err != nil {
+		log.Printf("Rejected job %v: %v", req.GetTaskType(), err)
+	}
+	return taskStatus, err
+}
+
+func (s *BackendServer) GetTaskStatus(ctx context.Context, req *lbproto.TaskID) (*lbproto.TaskStatus, error) {
+	return jobManager.Status(req.GetJobId())
+}
+
+func (s *BackendServer) WatchTask(req *lbproto.TaskID, stream grpc.ServerStreamingServer[lbproto.TaskStatus]) error {
+	return jobManager.Watch(stream.Context(), req.GetJobId(), stream.Send)
+}
+
+func (s *BackendServer) CancelTask(ctx context.Context, req *lbproto.TaskID) (*lbproto.TaskStatus, error) {
+	return jobManager.Cancel(req.GetJobId())
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingTaskType is a task type registered only in tests, whose task runs
+// until unblocked.
+const blockingTaskType = lbproto.TaskType(1000)
+
+// newTestJobManager returns a JobManager over a pool of workers, with the
+// blocking task registered. Closing the returned channel lets the blocking
+// tasks finish.
+func newTestJobManager(t *testing.T, resultTTL time.Duration, workers int) (*JobManager, chan struct{}) {
+	t.Helper()
+	pool, cache, limiter := workerPool, resultCache, taskLimiter
+	workerPool, resultCache, taskLimiter = NewWorkerPool(workers, 4), NewResultCache(0, nil), NewTaskLimiter(nil)
+	unblock := make(chan struct{})
+	taskSpecs[blockingTaskType] = &TaskSpec{
+		Cost: func(n int64) float64 { return 1000 },
+		Run: func(ctx context.Context, n int64) (int64, error) {
+			select {
+			case <-unblock:
+				return n, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		},
+	}
+	m := NewJobManager(resultTTL)
+	t.Cleanup(func() {
+		// the jobs use the globals until they are done
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		m.Shutdown(ctx)
+		m.running.Wait()
+		delete(taskSpecs, blockingTaskType)
+		workerPool, resultCache, taskLimiter = pool, cache, limiter
+	})
+	return m, unblock
+}
+
+func submitJob(t *testing.T, m *JobManager, taskType lbproto.TaskType, num int64) string {
+	t.Helper()
+	taskStatus, err := m.Submit(&lbproto.BackendRequest{TaskType: taskType, Num: num})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	return taskStatus.GetJobId()
+}
+
+// waitForState waits until the job is in state.
+func waitForState(t *testing.T, m *JobManager, jobID string, state lbproto.TaskState) *lbproto.TaskStatus {
+	t.Helper()
+	deadline := time.Now().Add(poolTestTimeout)
+	for {
+		taskStatus, err := m.Status(jobID)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if taskStatus.GetState() == state {
+			return taskStatus
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %v, want %v", jobID, taskStatus.GetState(), state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobResultExpires(t *testing.T) {
+	const resultTTL = 50 * time.Millisecond
+	m, _ := newTestJobManager(t, resultTTL, 1)
+	jobID := submitJob(t, m, lbproto.TaskType_SUM, 10)
+	taskStatus := waitForState(t, m, jobID, lbproto.TaskState_SUCCEEDED)
+	if taskStatus.GetOutput() != 55 || taskStatus.GetExpiresAt() == 0 {
+		t.Errorf("finished job output %d, expires at %d; want 55 and an expiry", taskStatus.GetOutput(), taskStatus.GetExpiresAt())
+	}
+
+	deadline := time.Now().Add(poolTestTimeout)
+	for {
+		_, err := m.Status(jobID)
+		if status.Code(err) == codes.NotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Status %v after the result TTL, want NotFound", err)
+		}
+		time.Sleep(resultTTL / 5)
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	m, unblock := newTestJobManager(t, time.Minute, 1)
+	running := submitJob(t, m, blockingTaskType, 1)
+	waitForState(t, m, running, lbproto.TaskState_RUNNING)
+	queued := submitJob(t, m, blockingTaskType, 2)
+
+	taskStatus, err := m.Cancel(queued)
+	if err != nil || taskStatus.GetState() != lbproto.TaskState_CANCELLED {
+		t.Fatalf("Cancel of a queued job = %v, %v, want CANCELLED", taskStatus, err)
+	}
+	close(unblock)
+	waitForState(t, m, running, lbproto.TaskState_SUCCEEDED)
+	// the worker skips the cancelled job once it reaches it
+	m.Shutdown(context.Background())
+	if taskStatus, _ := m.Status(queued); taskStatus.GetState() != lbproto.TaskState_CANCELLED {
+		t.Errorf("cancelled job is %v after the worker was free, want CANCELLED", taskStatus.GetState())
+	}
+}
+
+func TestWatchJob(t *testing.T) {
+	m, unblock := newTestJobManager(t, time.Minute, 1)
+	first := submitJob(t, m, blockingTaskType, 1)
+	jobID := submitJob(t, m, blockingTaskType, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), poolTestTimeout)
+	defer cancel()
+	states := make(chan lbproto.TaskState, 100)
+	watched := make(chan error, 1)
+	go func() {
+		watched <- m.Watch(ctx, jobID, func(taskStatus *lbproto.TaskStatus) error {
+			states <- taskStatus.GetState()
+			return nil
+		})
+	}()
+	// each step waits to be seen, so the watch cannot miss one
+	var got []lbproto.TaskState
+	next := func(want lbproto.TaskState) {
+		t.Helper()
+		for {
+			select {
+			case state := <-states:
+				if len(got) == 0 || got[len(got)-1] != state {
+					got = append(got, state)
+				}
+				if state == want {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatalf("watch sent %v, want %v next", got, want)
+			}
+		}
+	}
+	next(lbproto.TaskState_QUEUED)
+	unblock <- struct{}{} // finishes the first job
+	waitForState(t, m, first, lbproto.TaskState_SUCCEEDED)
+	next(lbproto.TaskState_RUNNING)
+	unblock <- struct{}{}
+	next(lbproto.TaskState_SUCCEEDED)
+	if err := <-watched; err != nil {
+		t.Errorf("Watch failed: %v", err)
+	}
+
+	want := []lbproto.TaskState{lbproto.TaskState_QUEUED, lbproto.TaskState_RUNNING, lbproto.TaskState_SUCCEEDED}
+	if len(got) != len(want) {
+		t.Fatalf("watch sent %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("watch sent %v, want %v", got, want)
+		}
+	}
+}
+
+func TestJobManagerShutdown(t *testing.T) {
+	m, unblock := newTestJobManager(t, time.Minute, 2)
+	jobs := []string{submitJob(t, m, blockingTaskType, 1), submitJob(t, m, blockingTaskType, 2), submitJob(t, m, blockingTaskType, 3)}
+
+	// Shutdown waits for every job, not only one of them
+	shutdown := make(chan struct{})
+	go func() {
+		m.Shutdown(context.Background())
+		close(shutdown)
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned with jobs running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := m.Submit(&lbproto.BackendRequest{TaskType: blockingTaskType, Num: 4}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Submit during shutdown returned %v, want Unavailable", err)
+	}
+	close(unblock)
+	select {
+	case <-shutdown:
+	case <-time.After(poolTestTimeout):
+		t.Fatal("Shutdown still waiting after every job finished")
+	}
+	for _, jobID := range jobs {
+		if taskStatus, _ := m.Status(jobID); taskStatus.GetState() != lbproto.TaskState_SUCCEEDED {
+			t.Errorf("job %s is %v after Shutdown, want SUCCEEDED", jobID, taskStatus.GetState())
+		}
+	}
+}
+
+func TestJobManagerShutdownTimeout(t *testing.T) {
+	m, _ := newTestJobManager(t, time.Minute, 1)
+	running := submitJob(t, m, blockingTaskType, 1)
+	queued := submitJob(t, m, blockingTaskType, 2)
+	waitForState(t, m, running, lbproto.TaskState_RUNNING)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	m.Shutdown(ctx)
+	waitForState(t, m, queued, lbproto.TaskState_CANCELLED)
+	waitForState(t, m, running, lbproto.TaskState_CANCELLED)
+}
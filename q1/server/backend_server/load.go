@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lbproto "q1/protofiles"
+)
+
+// LoadReporter measures the load a backend reports to the load balancer.
+type LoadReporter interface {
+	Load() (float64, error)
+	Metric() lbproto.LoadMetric
+}
+
+var loadMetricNames = []string{"cpu", "inflight", "queue", "composite"}
+
+func newLoadReporter(name string, stats *RequestStats, pool *WorkerPool) (LoadReporter, error) {
+	switch name {
+	case "cpu":
+		return &procStatCPU{}, nil
+	case "inflight":
+		return inFlightLoad{stats}, nil
+	case "queue":
+		return queueDepthLoad{pool}, nil
+	case "composite":
+		return &compositeLoad{cpu: &procStatCPU{}, stats: stats, pool: pool}, nil
+	}
+	return nil, fmt.Errorf("invalid load metric %q, use one of %v", name, loadMetricNames)
+}
+
+// clockTicksPerSecond is USER_HZ, the unit of the CPU times in /proc. It is
+// 100 on every architecture Linux supports.
+const clockTicksPerSecond = 100
+
+// procStatCPU reports the CPU usage of this process since the previous call,
+// in percent of one core (like top), from /proc/self/stat.
+type procStatCPU struct {
+	mutexLock sync.Mutex
+	readTicks func() (uint64, error) // readProcessCPUTicks if nil
+	lastTicks uint64
+	lastTime  time.Time
+}
+
+func (p *procStatCPU) Metric() lbproto.LoadMetric {
+	return lbproto.LoadMetric_CPU
+}
+
+func (p *procStatCPU) Load() (float64, error) {
+	readTicks := p.readTicks
+	if readTicks == nil {
+		readTicks = readProcessCPUTicks
+	}
+	ticks, err := readTicks()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	p.mutexLock.Lock()
+	defer p.mutexLock.Unlock()
+
+	usage := 0.0
+	if !p.lastTime.IsZero() && now.After(p.lastTime) {
+		cpuSeconds := float64(ticks-p.lastTicks) / clockTicksPerSecond
+		usage = 100 * cpuSeconds / now.Sub(p.lastTime).Seconds()
+	}
+	p.lastTicks, p.lastTime = ticks, now
+	return usage, nil
+}
+
+// readProcessCPUTicks returns utime + stime of this process.
+func readProcessCPUTicks() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, err
+	}
+	return parseStatCPUTicks(string(data))
+}
+
+// parseStatCPUTicks returns utime + stime from a /proc/<pid>/stat line.
+func parseStatCPUTicks(stat string) (uint64, error) {
+	// The command name (field 2) is in parentheses and may contain spaces,
+	// so count fields from the closing parenthesis; utime and stime are
+	// fields 14 and 15.
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("unexpected /proc/self/stat format")
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("unexpected /proc/self/stat format: %d fields", len(fields)+2)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
+
+// inFlightLoad reports the number of requests being served.
+type inFlightLoad struct {
+	stats *RequestStats
+}
+
+func (l inFlightLoad) Metric() lbproto.LoadMetric {
+	return lbproto.LoadMetric_IN_FLIGHT
+}
+
+func (l inFlightLoad) Load() (float64, error) {
+	return float64(l.stats.InFlight()), nil
+}
+
+// queueDepthLoad reports the requests waiting for a worker.
+type queueDepthLoad struct {
+	pool *WorkerPool
+}
+
+func (l queueDepthLoad) Metric() lbproto.LoadMetric {
+	return lbproto.LoadMetric_QUEUE_DEPTH
+}
+
+func (l queueDepthLoad) Load() (float64, error) {
+	return float64(l.pool.QueueDepth()), nil
+}
+
+// compositeLoad combines the other metrics, each scaled to "fraction of the
+// machine", into one score: CPU usage + requests in flight + twice the queue
+// depth, with waiting requests counted double because they add latency
+// directly. An idle backend scores 0 and a fully busy one about 2.
+type compositeLoad struct {
+	cpu   *procStatCPU
+	stats *RequestStats
+	pool  *WorkerPool
+}
+
+func (l *compositeLoad) Metric() lbproto.LoadMetric {
+	return lbproto.LoadMetric_COMPOSITE
+}
+
+func (l *compositeLoad) Load() (float64, error) {
+	cpuUsage, err := l.cpu.Load()
+	if err != nil {
+		return 0, err
+	}
+	cores := float64(runtime.GOMAXPROCS(0))
+	score := cpuUsage/100/cores +
+		float64(l.stats.InFlight())/cores +
+		2*float64(l.pool.QueueDepth())/cores
+	return score, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseStatCPUTicks(t *testing.T) {
+	// fields after the command name: state ppid pgrp session tty_nr tpgid
+	// flags minflt cminflt majflt cmajflt utime stime cutime cstime ...
+	const rest = " S 1 42 42 0 -1 4194560 1200 0 3 0 250 70 9 8 20 0 12 0 500 1000000 300"
+	tests := []struct {
+		name    string
+		stat    string
+		want    uint64
+		wantErr bool
+	}{
+		{"plain name", "42 (backend)" + rest, 320, false},
+		{"name with spaces", "42 (backend server 1)" + rest, 320, false},
+		{"name with parentheses", "42 (a) b (c) 7 8)" + rest, 320, false},
+		{"name with a closing parenthesis and spaces", "42 ( ) S 1 2 )" + rest, 320, false},
+		{"no parentheses", "42 backend" + rest, 0, true},
+		{"too few fields", "42 (backend) S 1 42", 0, true},
+		{"non numeric utime", "42 (backend) S 1 42 42 0 -1 4194560 1200 0 3 0 x 70", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatCPUTicks(tt.stat)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseStatCPUTicks(%q) = %d, %v; want %d, error %v", tt.stat, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadProcessCPUTicks(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs /proc")
+	}
+	if _, err := readProcessCPUTicks(); err != nil {
+		t.Errorf("readProcessCPUTicks failed: %v", err)
+	}
+}
+
+func TestCompositeLoad(t *testing.T) {
+	cores := float64(runtime.GOMAXPROCS(0))
+	pool := NewWorkerPool(1, 3)
+	release := fillPool(t, pool, 1, 3)
+	defer release()
+	stats := &RequestStats{}
+	stats.begin()
+	stats.begin()
+
+	// half of every core busy over the last 10s
+	const window = 10 * time.Second
+	ticks := uint64(window.Seconds() * clockTicksPerSecond * cores / 2)
+	cpu := &procStatCPU{readTicks: func() (uint64, error) { return ticks, nil }}
+	cpu.lastTime = time.Now().Add(-window)
+	l := &compositeLoad{cpu: cpu, stats: stats, pool: pool}
+
+	got, err := l.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// CPU + in flight + twice the queue depth, per core
+	want := 0.5 + 2/cores + 2*3/cores
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+}
@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net"
-	"os"
-	"time"
-	"bytes"
-	"os/exec"
-	"strconv"
+	"os/signal"
+	"runtime"
 	"strings"
+	"syscall"
+	"time"
+	"sync"
 	
+	"q1/metrics"
 	lbproto "q1/protofiles"
-	"go.etcd.io/etcd/client/v3"
+	"q1/registry"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 	
 func getAvaliablePort() (int, error) {
@@ -26,40 +32,6 @@ func getAvaliablePort() (int, error) {
 	return listener.Addr().(*net.TCPAddr).Port, nil
 }
 
-func parseCmdOutput(output string) (float64, error){
-	lines := strings.Split(output, "\n")
-	if len(lines) < 7 {
-		log.Fatalf("Unexpected output from top command")
-	}
-	processInfo := strings.Fields(lines[len(lines) - 2])
-	if len(processInfo) < 9 {
-		log.Fatalf("Failed to parse CPU usage")
-	} 
-	cpuUsage, err := strconv.ParseFloat(processInfo[8], 64)
-	if err != nil {
-		return 0.0, err
-	}
-	return cpuUsage, nil
-}
-
-func getCpuUsage()(float64, error){
-	pid := os.Getpid()
-	cmd := exec.Command("top", "-b", "-n", "1", "-p", strconv.Itoa(pid))
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	
-	err := cmd.Run()
-	if err != nil {
-		return 0.0, err
-	} 
-	cpuUsage, err := parseCmdOutput(out.String())
-	if err != nil{
-		return 0.0, err
-	}
-	// fmt.Println("Pid:",pid , "CPU Usage:", cpuUsage, "%")
-	return cpuUsage, nil
-}
-
 func getAvaliableAddress() (string, error) {
 	port, err := getAvaliablePort()
 	if err != nil {
@@ -70,100 +42,367 @@ func getAvaliableAddress() (string, error) {
 }
 
 const (
-	etcdServerAddr = "localhost:2379"
-	etcdKeyPrefix  = "/services/backend/"
-	lbServerAddr   = "localhost:50319"
-	ttl            = 2                   // TTL in seconds for etcd lease
+	ttl            = 2 * time.Second     // TTL for the registry lease
+	deregisterTimeout  = 2 * time.Second
+	loadReportInterval = time.Second
+	reportMinBackoff   = 100 * time.Millisecond
+	reportMaxBackoff   = 5 * time.Second
+	healthCheckInterval = time.Second
 )
 
 var (
 	serverAddr = ""
+	registryConfig registry.Config
+	requestStats = &RequestStats{}
+	taskLimiter  = NewTaskLimiter(nil)
+	workerPool   *WorkerPool
+	jobManager   *JobManager
+	resultCache  *ResultCache
 )
 
 type BackendServer struct {
 	lbproto.UnimplementedBackendServiceServer
 }
 
+// RequestStats tracks the requests in flight and the latency of completed
+// requests between two load reports.
+type RequestStats struct {
+	mutexLock  sync.Mutex
+	inFlight   int32
+	completed  int
+	latencySum time.Duration
+}
+
+func (rs *RequestStats) InFlight() int32 {
+	rs.mutexLock.Lock()
+	defer rs.mutexLock.Unlock()
+	return rs.inFlight
+}
+
+func (rs *RequestStats) begin() {
+	rs.mutexLock.Lock()
+	rs.inFlight++
+	rs.mutexLock.Unlock()
+}
+
+// end records a finished request. Only requests that completed count
+// towards the mean latency, since rejections return immediately.
+func (rs *RequestStats) end(latency time.Duration, err error) {
+	rs.mutexLock.Lock()
+	rs.inFlight--
+	if err == nil {
+		rs.completed++
+		rs.latencySum += latency
+	}
+	rs.mutexLock.Unlock()
+}
+
+// collect returns the requests in flight and the mean latency in milliseconds
+// of the requests completed since the previous call (0 if none).
+func (rs *RequestStats) collect() (int32, float32) {
+	rs.mutexLock.Lock()
+	defer rs.mutexLock.Unlock()
+
+	meanLatency := float32(0)
+	if rs.completed > 0 {
+		meanLatency = float32(rs.latencySum.Seconds() * 1000 / float64(rs.completed))
+	}
+	rs.completed, rs.latencySum = 0, 0
+	return rs.inFlight, meanLatency
+}
+
 func (s *BackendServer) BackendRPC(ctx context.Context, req *lbproto.BackendRequest) (*lbproto.BackendResponse, error) {
-	tasktype, num := req.GetTaskType(), req.GetNum()
-	log.Printf("Task Received : %d, N : %d\n", tasktype, num)
-	result := executeTask(tasktype, num)
-	log.Printf("Server:%s, Task:%d Completed!, Sending Response...\n", serverAddr, tasktype)
+	tasktype := req.GetTaskType()
+	spec, num, err := prepareTask(tasktype, req.GetNum(), req.GetUseDefault())
+	if err != nil {
+		log.Printf("Rejected task %v: %v", tasktype, err)
+		return nil, err
+	}
+
+	log.Printf("Task Received : %v, N : %d, estimated cost %.0fms\n", tasktype, num, spec.Cost(num))
+	grpc.SetHeader(ctx, metadata.Pairs(registry.ServerAddrHeader, serverAddr))
+	requestStats.begin()
+	start := time.Now()
+	result, cached, err := resultCache.Do(ctx, tasktype, num, func(ctx context.Context) (int64, error) {
+		return runTask(ctx, tasktype, spec, num)
+	})
+	elapsed := time.Since(start)
+	requestStats.end(elapsed, err)
+	observeTask(tasktype, elapsed.Seconds(), cached, err)
+	if err != nil {
+		log.Printf("Task %v not completed: %v", tasktype, err)
+		return nil, err
+	}
+	if cached {
+		log.Printf("Server:%s, Task:%v served from cache, Sending Response...\n", serverAddr, tasktype)
+	} else {
+		log.Printf("Server:%s, Task:%v Completed in %v!, Sending Response...\n", serverAddr, tasktype, time.Since(start).Round(time.Millisecond))
+	}
 	return &lbproto.BackendResponse{Output: result}, nil
 }
 
-func ReportLoadStatus(client lbproto.ReportLoadServiceClient, serverAddr string){
-	for{
-		load, err := getCpuUsage()
-		if err != nil {
-			log.Fatalf("Error while getting CPU load: %v", err)
+// runTask runs a task on the worker pool within its type's concurrency limit.
+// The task takes its slot in the limit before it is queued and holds it until
+// it stops, even when the caller gives up on it first.
+func runTask(ctx context.Context, tasktype lbproto.TaskType, spec *TaskSpec, num int64) (int64, error) {
+	if err := taskLimiter.acquire(int32(tasktype)); err != nil {
+		return 0, err
+	}
+
+	// the task may still be running when the caller gives up, so it hands
+	// its result over rather than write to variables read here
+	results := make(chan taskResult, 1)
+	done, err := workerPool.Submit(ctx, func() {
+		output, err := spec.Run(ctx, num)
+		results <- taskResult{output: output, err: err}
+	})
+	if err != nil {
+		taskLimiter.release(int32(tasktype))
+		return 0, err
+	}
+	select {
+	case <-done:
+		taskLimiter.release(int32(tasktype))
+	case <-ctx.Done():
+		// a started task sees ctx is done and stops soon after
+		go func() {
+			<-done
+			taskLimiter.release(int32(tasktype))
+		}()
+		return 0, status.FromContextError(ctx.Err()).Err()
+	}
+	select {
+	case result := <-results:
+		if result.err != nil {
+			return 0, status.FromContextError(result.err).Err()
+		}
+		return result.output, nil
+	default:
+		// the worker skipped the task because ctx ended while it was queued
+		return 0, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+type taskResult struct {
+	output int64
+	err    error
+}
+
+// LoadBalancerConn connects to the load balancer that is currently elected
+// in the registry, or to a fixed address if none is.
+type LoadBalancerConn struct {
+	reg      registry.Registry
+	fallback string
+	addr     string
+	conn     *grpc.ClientConn
+}
+
+// client looks up the leader again and redials if it has changed.
+func (c *LoadBalancerConn) client() lbproto.ReportLoadServiceClient {
+	addr := registry.LoadBalancerAddr(context.Background(), c.reg, c.fallback)
+	if c.conn == nil || addr != c.addr {
+		if c.conn != nil {
+			c.conn.Close()
 		}
-		loadStatus := &lbproto.LoadStatus{ServerAddr:serverAddr, Load: float32(load)}
-		_, err = client.ReportLoadRPC(context.Background(), loadStatus)
-		if err != nil{
-			log.Fatalf("Error while send Load Status: %v", err)
+		conn, err := grpc.Dial(addr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("Failed to create load balancer client: %v", err)
 		}
-		time.Sleep(1 * time.Second)
+		log.Println("Reporting load to load balancer", addr)
+		c.addr, c.conn = addr, conn
 	}
+	return lbproto.NewReportLoadServiceClient(c.conn)
 }
 
-func registerWithEtcd(client *clientv3.Client, leaseID clientv3.LeaseID, serverAddr string) {
-	etcdKey := fmt.Sprintf("%s%s", etcdKeyPrefix, serverAddr)
-	_, err := client.Put(context.Background(), etcdKey, serverAddr, clientv3.WithLease(leaseID))
-	if err != nil {
-		log.Fatalf("Failed to register backend: %v", err)
+// ReportLoadStatus streams a load report to the load balancer every
+// loadReportInterval, reconnecting with exponential backoff whenever the
+// stream breaks (e.g. while the load balancer restarts or fails over).
+func ReportLoadStatus(lbConn *LoadBalancerConn, serverAddr string, reporter LoadReporter){
+	seq := uint64(0)
+	backoff := reportMinBackoff
+	for {
+		err := streamLoadReports(lbConn.client(), serverAddr, reporter, &seq, func() { backoff = reportMinBackoff })
+		log.Printf("Load report stream failed, reconnecting in %v: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, reportMaxBackoff)
 	}
-	log.Printf("Registered %s with etcd", serverAddr)
 }
 
-func keepAlive(client *clientv3.Client, leaseID clientv3.LeaseID) {
-	ch, err := client.KeepAlive(context.Background(), leaseID)
+// streamLoadReports sends reports on one ReportLoadStream until it fails.
+// seq numbers the reports across streams; sent is called after each report
+// that was sent.
+func streamLoadReports(client lbproto.ReportLoadServiceClient, serverAddr string, reporter LoadReporter, seq *uint64, sent func()) error {
+	stream, err := client.ReportLoadStream(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to keep alive: %v", err)
+		return err
+	}
+	wake := drainStarted // report the start of a drain without waiting
+	for {
+		load, err := reporter.Load()
+		if err != nil {
+			log.Printf("Error while getting load: %v", err)
+		} else {
+			reportedLoad.WithLabelValues(strings.ToLower(reporter.Metric().String())).Set(load)
+			*seq++
+			inFlight, latency := requestStats.collect()
+			loadStatus := &lbproto.LoadStatus{
+				ServerAddr: serverAddr,
+				Load:       float32(load),
+				InFlight:   inFlight,
+				Latency:    latency,
+				Metric:     reporter.Metric(),
+				QueueDepth: int32(workerPool.QueueDepth()),
+				Seq:        *seq,
+				Timestamp:  time.Now().UnixNano(),
+				Draining:   draining.Load(),
+			}
+			if err := stream.Send(loadStatus); err != nil {
+				// Send only reports io.EOF, the status comes from CloseAndRecv
+				if _, err = stream.CloseAndRecv(); err == nil {
+					err = fmt.Errorf("load balancer closed the stream")
+				}
+				return err
+			}
+			sent()
+		}
+		select {
+		case <-time.After(loadReportInterval):
+		case <-wake:
+			wake = nil
+		}
 	}
-	for range ch {}  // to consumed keepalive responses
 }
 
-func main() {
-	etcdClient, err := clientv3.New(clientv3.Config{
-		Endpoints:   []string{etcdServerAddr},
-		DialTimeout: 2 * time.Second,
-	})
-	if err != nil {
-		log.Fatalf("Failed to connect to etcd: %v", err)
+// keepRegistered keeps the server's lease alive, registering again under a
+// new lease whenever the old one is lost. Once ctx is done it revokes the
+// lease, so the server leaves the registry without waiting for the TTL.
+func keepRegistered(ctx context.Context, reg registry.Registry, leaseID registry.LeaseID, instance registry.Instance) {
+	for registered := true; ; {
+		if registered {
+			err := reg.KeepAlive(ctx, leaseID)
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Registry lease lost: %v", err)
+		}
+		var err error
+		leaseID, err = reg.Register(ctx, instance, ttl)
+		registered = err == nil
+		if registered {
+			log.Printf("Registered %s with the service registry again", instance.Addr)
+			continue
+		}
+		log.Printf("Failed to register backend: %v", err)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
 	}
-	defer etcdClient.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	revokeCtx, cancel := context.WithTimeout(context.Background(), deregisterTimeout)
 	defer cancel()
+	if err := reg.Deregister(revokeCtx, leaseID); err != nil {
+		log.Printf("Failed to deregister backend, it stays registered until its lease expires: %v", err)
+		return
+	}
+	log.Println("Backend server deregistered from the service registry")
+}
 
-	resp, err := etcdClient.Status(ctx, etcdServerAddr)
-	if err != nil {
-		log.Fatalf("Etcd is not running or unreachable: %v", err)
+// watchHealth sets the health status from the worker pool's progress until
+// the drain starts: NOT_SERVING while the pool is stalled, SERVING otherwise.
+func watchHealth(healthServer *health.Server, pool *WorkerPool, window time.Duration) {
+	serving := true
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if stalled := pool.Stalled(window, now); stalled == serving {
+				serving = !stalled
+				status := healthpb.HealthCheckResponse_SERVING
+				if stalled {
+					status = healthpb.HealthCheckResponse_NOT_SERVING
+					log.Printf("No task finished in %v with the queue full, reporting NOT_SERVING", window)
+				} else {
+					log.Println("Tasks are finishing again, reporting SERVING")
+				}
+				healthServer.SetServingStatus("", status)
+			}
+		case <-drainStarted:
+			return
+		}
 	}
+}
+
+func main() {
+	registryConfig.AddFlags(flag.CommandLine)
+	flag.StringVar(&serverAddr, "addr", "", "address to listen on (default: a free port on localhost)")
+	weight := flag.Int("weight", 1, "relative capacity of this server, used by the weighted policies")
+	zone := flag.String("zone", "", "zone this server runs in, such as its rack; the load balancer prefers servers in the caller's zone")
+	region := flag.String("region", "", "region this server runs in, such as its data center")
+	workers := flag.Int("workers", runtime.GOMAXPROCS(0), "number of tasks run at once")
+	queueSize := flag.Int("queue-size", 16, "number of tasks that may wait for a worker before new ones are rejected")
+	resultTTL := flag.Duration("result-ttl", 10*time.Minute, "how long the result of an asynchronous job is kept")
+	cacheSize := flag.Int("cache-size", 1024, "number of task results to cache, 0 to disable the cache")
+	cacheDisable := flag.String("cache-disable", "", "task types not to cache, e.g. sort,matrix_multiply")
+	tasks := flag.String("tasks", "", "task types served, with optional limits on how many of each may be queued or running, e.g. 2 or 0,1:4 (default: all)")
+	lbAddr := flag.String("lb-addr", registry.DefaultLoadBalancerAddr, "load balancer address, used when none is elected in the registry")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "how long in-flight requests and jobs may run after SIGINT or SIGTERM before they are cancelled")
+	loadMetric := flag.String("load-metric", "cpu", "load to report: cpu, inflight, queue or composite")
+	stallWindow := flag.Duration("stall-window", 10*time.Second, "report NOT_SERVING to health checks while the queue is full and no task has finished for this long")
+	metricsAddr := flag.String("metrics-addr", "localhost:0", "address to serve Prometheus metrics on at /metrics (default: a free port on localhost), empty to disable")
+	flag.Parse()
 
-	log.Println("Etcd is running! Version:", resp.Version)
-	// Create a lease
-	leaseResp, err := etcdClient.Grant(context.Background(), ttl)
+	if *workers < 1 || *queueSize < 0 {
+		log.Fatalf("--workers must be at least 1 and --queue-size at least 0")
+	}
+	workerPool = NewWorkerPool(*workers, *queueSize)
+	jobManager = NewJobManager(*resultTTL)
+	uncached, err := ParseTaskTypes(*cacheDisable)
+	if err != nil || *cacheSize < 0 {
+		log.Fatalf("Invalid cache configuration: --cache-size=%d --cache-disable=%q: %v", *cacheSize, *cacheDisable, err)
+	}
+	resultCache = NewResultCache(*cacheSize, uncached)
+	loadReporter, err := newLoadReporter(*loadMetric, requestStats, workerPool)
 	if err != nil {
-		log.Fatalf("Failed to create lease: %v", err)
+		log.Fatalf("%v", err)
 	}
-	leaseID := leaseResp.ID
-	
-	serverAddr, err = getAvaliableAddress()
+	capabilities, err := registry.ParseTasks(*tasks)
 	if err != nil {
-		log.Fatalf("Failed to get avaliable address: %v", err)
+		log.Fatalf("Invalid --tasks: %v", err)
+	}
+	taskLimiter = NewTaskLimiter(capabilities)
+
+	reg, err := registry.Open(registryConfig)
+	if err != nil {
+		log.Fatalf("Failed to open service registry: %v", err)
+	}
+	defer reg.Close()
+
+	if serverAddr == "" {
+		serverAddr, err = getAvaliableAddress()
+		if err != nil {
+			log.Fatalf("Failed to get avaliable address: %v", err)
+		}
 	}
 
 	// Register and keep alive
-	registerWithEtcd(etcdClient, leaseID, serverAddr)
-	log.Println("Backend server registered with etcd")
+	instance := registry.Instance{Addr: serverAddr, Weight: int32(*weight), Tasks: capabilities, Zone: *zone, Region: *region}
+	leaseID, err := reg.Register(context.Background(), instance, ttl)
+	if err != nil {
+		log.Fatalf("Failed to register backend: %v", err)
+	}
+	log.Println("Backend server registered with the service registry")
 
-	go keepAlive(etcdClient, leaseID)
+	registryCtx, stopRegistry := context.WithCancel(context.Background())
+	deregistered := make(chan struct{})
+	go func() {
+		keepRegistered(registryCtx, reg, leaseID, instance)
+		close(deregistered)
+	}()
 
-	conn, err := grpc.Dial(lbServerAddr, grpc.WithInsecure())
-	reportLoadClient := lbproto.NewReportLoadServiceClient(conn)
-	go ReportLoadStatus(reportLoadClient, serverAddr)
+	go ReportLoadStatus(&LoadBalancerConn{reg: reg, fallback: *lbAddr}, serverAddr, loadReporter)
 	
 	listener, err := net.Listen("tcp", serverAddr)
 	if err != nil {
@@ -171,17 +410,51 @@ func main() {
 	}
 	defer listener.Close()
 
-	backendServer := grpc.NewServer()
+	if *metricsAddr != "" {
+		if addr, err := metrics.Serve(*metricsAddr, metricsRegistry); err != nil {
+			log.Printf("Not serving metrics: %v", err)
+		} else {
+			log.Printf("Serving metrics on http://%s/metrics", addr)
+		}
+	}
+
+	backendServer := grpc.NewServer(grpc.StatsHandler(metrics.NewConnCounter(activeConns)))
 	lbproto.RegisterBackendServiceServer(backendServer, &BackendServer{})
 
+	// Health checked by the load balancer and client-side balancers
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(backendServer, healthServer)
+	go watchHealth(healthServer, workerPool, *stallWindow)
+
+	// Drain on SIGINT/SIGTERM; a second signal stops the server at once
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		served <- backendServer.Serve(listener)
+	}()
+
 	log.Println("Backend gRPC server is running on", serverAddr)
-	if err := backendServer.Serve(listener); err != nil {
+	select {
+	case err := <-served:
 		log.Fatalf("Failed to serve: %v", err)
+	case <-ctx.Done():
 	}
+	stop()
+	log.Printf("Backend server draining, in-flight requests have %v to finish", *shutdownTimeout)
+	shutdown(backendServer, healthServer, func() {
+		stopRegistry()
+		<-deregistered
+	}, *shutdownTimeout)
+	log.Println("Backend server stopped")
 }
 
 /*
 
+Either run the load balancer with --embedded-etcd (make server LB_FLAGS=--embedded-etcd)
+or start etcd with Docker:
+
 docker pull gcr.io/etcd-development/etcd:v3.5.9
 
 docker run -d -p 2379:2379 --name etcd-instance gcr.io/etcd-development/etcd:v3.5.9 \
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"q1/metrics"
+	lbproto "q1/protofiles"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/status"
+)
+
+// Prometheus metrics, served on --metrics-addr
+var (
+	metricsRegistry = metrics.NewRegistry()
+	metricsFactory  = promauto.With(metricsRegistry)
+
+	taskDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "q1_backend_task_duration_seconds",
+		Help: "Latency of completed BackendRPC calls, by task type and whether the result came from the cache.",
+		// 0.5ms to about 16s
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+	}, []string{"task_type", "cached"})
+	taskCount = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "q1_backend_tasks_total",
+		Help: "BackendRPC calls, by task type and gRPC status code.",
+	}, []string{"task_type", "code"})
+
+	reportedLoad = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "q1_backend_load",
+		Help: "Load last measured for the load balancer, in the unit of the load metric.",
+	}, []string{"metric"})
+	_ = metricsFactory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "q1_backend_queue_depth",
+		Help: "Tasks waiting for a worker.",
+	}, func() float64 { return float64(workerPool.QueueDepth()) })
+	_ = metricsFactory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "q1_backend_in_flight",
+		Help: "BackendRPC calls in progress.",
+	}, func() float64 { return float64(requestStats.InFlight()) })
+	_ = metricsFactory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "q1_backend_cache_hits_total",
+		Help: "Tasks answered from the result cache, or by waiting for the same task in progress.",
+	}, func() float64 {
+		stats := resultCache.Stats()
+		return float64(stats.GetHits() + stats.GetShared())
+	})
+	_ = metricsFactory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "q1_backend_cache_misses_total",
+		Help: "Tasks the result cache could not answer.",
+	}, func() float64 { return float64(resultCache.Stats().GetMisses()) })
+
+	activeConns = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Name: "q1_backend_active_connections",
+		Help: "Open client connections to the backend's gRPC server.",
+	})
+)
+
+// observeTask records a finished BackendRPC call. Only completed tasks count
+// towards the latency, since rejections return immediately.
+func observeTask(taskType lbproto.TaskType, seconds float64, cached bool, err error) {
+	label := strings.ToLower(taskType.String())
+	taskCount.WithLabelValues(label, status.Code(err).String()).Inc()
+	if err == nil {
+		taskDuration.WithLabelValues(label, strconv.FormatBool(cached)).Observe(seconds)
+	}
+}
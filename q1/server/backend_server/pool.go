@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WorkerPool runs tasks on a fixed number of workers. Tasks that find every
+// worker busy wait in a bounded queue; when the queue is full new tasks are
+// rejected, so a burst is pushed back to the load balancer instead of
+// oversubscribing the CPU.
+type WorkerPool struct {
+	queue   chan *poolJob
+	workers int32
+	busy    atomic.Int32
+	// lastFinished is when a worker last finished a task, in Unix nanoseconds
+	lastFinished atomic.Int64
+}
+
+type poolJob struct {
+	ctx  context.Context
+	run  func()
+	done chan struct{} // closed once run returns, or when the job is skipped
+}
+
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	pool := &WorkerPool{queue: make(chan *poolJob, queueSize), workers: int32(workers)}
+	pool.lastFinished.Store(time.Now().UnixNano())
+	for i := 0; i < workers; i++ {
+		go pool.worker()
+	}
+	return pool
+}
+
+func (pool *WorkerPool) worker() {
+	for job := range pool.queue {
+		// the caller gave up while the job was queued
+		if job.ctx.Err() == nil {
+			pool.busy.Add(1)
+			job.run()
+			pool.busy.Add(-1)
+			pool.lastFinished.Store(time.Now().UnixNano())
+		}
+		close(job.done)
+	}
+}
+
+// Submit queues run without waiting for it. The returned channel is closed
+// once run has returned, or without calling run if ctx ended while it was
+// queued. It returns ResourceExhausted without queueing if the queue is
+// full.
+func (pool *WorkerPool) Submit(ctx context.Context, run func()) (<-chan struct{}, error) {
+	job := &poolJob{ctx: ctx, run: run, done: make(chan struct{})}
+	select {
+	case pool.queue <- job:
+		return job.done, nil
+	default:
+		return nil, status.Errorf(codes.ResourceExhausted, "server %s is overloaded, %d tasks queued", serverAddr, cap(pool.queue))
+	}
+}
+
+// QueueDepth returns the number of tasks waiting for a worker.
+func (pool *WorkerPool) QueueDepth() int {
+	return len(pool.queue)
+}
+
+// Stalled reports whether every worker is busy, the queue is full and no
+// task has finished within window before now, so new tasks are being
+// rejected and nothing suggests that will change soon.
+func (pool *WorkerPool) Stalled(window time.Duration, now time.Time) bool {
+	if pool.busy.Load() < pool.workers || len(pool.queue) < cap(pool.queue) {
+		return false
+	}
+	return now.Sub(time.Unix(0, pool.lastFinished.Load())) >= window
+}
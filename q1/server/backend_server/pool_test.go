@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const poolTestTimeout = 5 * time.Second
+
+// fillPool occupies every worker of pool and then its queue with tasks that
+// block until the returned function is called.
+func fillPool(t *testing.T, pool *WorkerPool, workers, queueSize int) (release func()) {
+	t.Helper()
+	block := make(chan struct{})
+	started := make(chan struct{})
+	// one at a time, so the queue never holds more than one of them
+	for i := 0; i < workers; i++ {
+		if _, err := pool.Submit(context.Background(), func() { started <- struct{}{}; <-block }); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		select {
+		case <-started:
+		case <-time.After(poolTestTimeout):
+			t.Fatal("workers did not start")
+		}
+	}
+	for i := 0; i < queueSize; i++ {
+		if _, err := pool.Submit(context.Background(), func() { <-block }); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { close(block) }) }
+}
+
+func TestWorkerPoolStalled(t *testing.T) {
+	const window = time.Minute
+	pool := NewWorkerPool(2, 1)
+	now := time.Now()
+	if pool.Stalled(window, now.Add(2*window)) {
+		t.Error("idle pool is stalled")
+	}
+
+	release := fillPool(t, pool, 2, 1)
+	defer release()
+	if pool.Stalled(window, now) {
+		t.Error("full pool is stalled before the window has passed")
+	}
+	if !pool.Stalled(window, now.Add(2*window)) {
+		t.Error("full pool with no task finished in the window is not stalled")
+	}
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	pool := NewWorkerPool(1, 2)
+	release := fillPool(t, pool, 1, 2)
+	defer release()
+	if depth := pool.QueueDepth(); depth != 2 {
+		t.Errorf("QueueDepth = %d, want 2", depth)
+	}
+	ran := false
+	if _, err := pool.Submit(context.Background(), func() { ran = true }); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Submit to a full queue returned %v, want ResourceExhausted", err)
+	}
+	release()
+	if ran {
+		t.Error("rejected task ran")
+	}
+}
+
+// captureLoadStream is a load balancer whose load report stream takes one
+// report and then fails.
+type captureLoadStream struct {
+	lbproto.ReportLoadServiceClient
+	grpc.ClientStream
+	sent chan *lbproto.LoadStatus
+}
+
+func (s *captureLoadStream) ReportLoadStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[lbproto.LoadStatus, lbproto.Empty], error) {
+	return s, nil
+}
+
+func (s *captureLoadStream) Send(loadStatus *lbproto.LoadStatus) error {
+	s.sent <- loadStatus
+	return io.EOF
+}
+
+func (s *captureLoadStream) CloseAndRecv() (*lbproto.Empty, error) {
+	return nil, errors.New("stream closed")
+}
+
+func TestLoadStatusQueueDepth(t *testing.T) {
+	defer func(pool *WorkerPool) { workerPool = pool }(workerPool)
+	workerPool = NewWorkerPool(1, 3)
+	release := fillPool(t, workerPool, 1, 3)
+	defer release()
+
+	reporter := queueDepthLoad{workerPool}
+	stream := &captureLoadStream{sent: make(chan *lbproto.LoadStatus, 1)}
+	var seq uint64
+	if err := streamLoadReports(stream, "localhost:6401", reporter, &seq, func() {}); err == nil {
+		t.Fatal("streamLoadReports returned no error for a failed stream")
+	}
+	loadStatus := <-stream.sent
+	if loadStatus.GetQueueDepth() != 3 || loadStatus.GetLoad() != 3 || loadStatus.GetMetric() != lbproto.LoadMetric_QUEUE_DEPTH {
+		t.Errorf("reported queue depth %d, load %v %v; want 3 queued", loadStatus.GetQueueDepth(), loadStatus.GetLoad(), loadStatus.GetMetric())
+	}
+}
+
+// On shutdown the server cancels the requests still running, so tasks still
+// queued end without running once a worker reaches them.
+func TestWorkerPoolShutdownWithTasksQueued(t *testing.T) {
+	pool := NewWorkerPool(1, 2)
+	release := fillPool(t, pool, 1, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 2)
+	var queued []<-chan struct{}
+	for i := 0; i < 2; i++ {
+		done, err := pool.Submit(ctx, func() { ran <- struct{}{} })
+		if err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		queued = append(queued, done)
+	}
+
+	cancel()
+	release()
+	for _, done := range queued {
+		select {
+		case <-done:
+		case <-time.After(poolTestTimeout):
+			t.Fatal("queued task not done after shutdown")
+		}
+	}
+	if len(ran) != 0 {
+		t.Errorf("%d queued tasks ran after their requests were cancelled", len(ran))
+	}
+	if depth := pool.QueueDepth(); depth != 0 {
+		t.Errorf("QueueDepth after shutdown = %d, want 0", depth)
+	}
+}
+
+func TestTaskLimitCoversQueuedTasks(t *testing.T) {
+	defer func(pool *WorkerPool, limiter *TaskLimiter) { workerPool, taskLimiter = pool, limiter }(workerPool, taskLimiter)
+	workerPool = NewWorkerPool(1, 4)
+	release := fillPool(t, workerPool, 1, 0)
+	defer release()
+	const taskType = lbproto.TaskType_SUM
+	taskLimiter = NewTaskLimiter([]registry.TaskCapability{{TaskType: int32(taskType), MaxConcurrent: 1}})
+	spec := &TaskSpec{Run: func(ctx context.Context, n int64) (int64, error) { return n, nil }}
+
+	// the first task waits in the queue behind the busy worker
+	first := make(chan error, 1)
+	go func() {
+		_, err := runTask(context.Background(), taskType, spec, 1)
+		first <- err
+	}()
+	deadline := time.Now().Add(poolTestTimeout)
+	for workerPool.QueueDepth() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("task not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := runTask(context.Background(), taskType, spec, 2); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second task with one queued returned %v, want ResourceExhausted", err)
+	}
+
+	release()
+	if err := <-first; err != nil {
+		t.Errorf("queued task failed: %v", err)
+	}
+	if _, err := runTask(context.Background(), taskType, spec, 3); err != nil {
+		t.Errorf("task after the queued one finished failed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// drainDelay is how long a draining server keeps accepting requests, so the
+// ones the load balancer routed here before it heard of the drain still get
+// through.
+const drainDelay = time.Second
+
+var (
+	// draining is set once shutdown starts and sent with every load report
+	draining atomic.Bool
+	// drainStarted is closed when shutdown starts, to report it at once
+	drainStarted = make(chan struct{})
+)
+
+// shutdown drains the server. It stops answering health checks with
+// SERVING, reports draining to the load balancer, leaves the registry with
+// deregister, and then lets in-flight requests and jobs finish. Whatever is
+// still running when timeout has passed is cancelled.
+func shutdown(server *grpc.Server, healthServer *health.Server, deregister func(), timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	draining.Store(true)
+	close(drainStarted)
+	// Shutdown also ignores any later update, so the status stays put
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthServer.Shutdown()
+	deregister()
+	select {
+	case <-time.After(drainDelay):
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	jobManager.Shutdown(ctx)
+
+	select {
+	case <-stopped:
+		log.Println("All requests finished")
+	case <-ctx.Done():
+		log.Printf("Requests still running after %v, cancelling %d", timeout, requestStats.InFlight())
+		server.Stop()
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TaskSpec describes a kind of task the backend runs. A new kind is a
+// TaskType in load_balancing.proto plus a RegisterTask call.
+type TaskSpec struct {
+	// DefaultArg is used when a request sets useDefault.
+	DefaultArg int64
+	// Validate rejects arguments the task cannot run with, nil accepts all.
+	Validate func(n int64) error
+	// Cost is a rough estimate of the CPU time in milliseconds the task
+	// takes for argument n on one core.
+	Cost func(n int64) float64
+	// Run computes the task, returning ctx.Err() if ctx ends first.
+	Run func(ctx context.Context, n int64) (int64, error)
+}
+
+var taskSpecs = make(map[lbproto.TaskType]*TaskSpec)
+
+// RegisterTask makes a task type runnable. It panics if the type is already
+// registered, so two kinds cannot silently share a number.
+func RegisterTask(taskType lbproto.TaskType, spec TaskSpec) {
+	if _, exists := taskSpecs[taskType]; exists {
+		panic(fmt.Sprintf("task type %v registered twice", taskType))
+	}
+	taskSpecs[taskType] = &spec
+}
+
+// registeredTasks returns the registered task types in order.
+func registeredTasks() []lbproto.TaskType {
+	types := make([]lbproto.TaskType, 0, len(taskSpecs))
+	for taskType := range taskSpecs {
+		types = append(types, taskType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// prepareTask looks up the task and validates its argument, which is the
+// task's default instead of n if useDefault is set. Both failures have code
+// InvalidArgument.
+func prepareTask(taskType lbproto.TaskType, n int64, useDefault bool) (*TaskSpec, int64, error) {
+	spec, exists := taskSpecs[taskType]
+	if !exists {
+		return nil, 0, status.Errorf(codes.InvalidArgument, "unknown task type %d", int32(taskType))
+	}
+	if useDefault {
+		n = spec.DefaultArg
+	}
+	if spec.Validate != nil {
+		if err := spec.Validate(n); err != nil {
+			return nil, 0, status.Errorf(codes.InvalidArgument, "invalid argument for %v: %v", taskType, err)
+		}
+	}
+	return spec, n, nil
+}
+
+// executeTask runs a task until it finishes or ctx is done, in which case it
+// returns ctx.Err().
+func executeTask(ctx context.Context, taskType lbproto.TaskType, n int64) (int64, error) {
+	spec, n, err := prepareTask(taskType, n, false)
+	if err != nil {
+		return 0, err
+	}
+	return spec.Run(ctx, n)
+}
+
+// argRange returns a Validate function accepting min <= n <= max.
+func argRange(min, max int64) func(int64) error {
+	return func(n int64) error {
+		if n < min || n > max {
+			return fmt.Errorf("%d is outside [%d, %d]", n, min, max)
+		}
+		return nil
+	}
+}
+
+func init() {
+	RegisterTask(lbproto.TaskType_SUM, TaskSpec{
+		DefaultArg: 1e9,
+		Validate:   argRange(1, 3e9), // keeps the sum within an int64
+		Cost:       func(n int64) float64 { return float64(n) / 1e6 },
+		Run:        Sum,
+	})
+	RegisterTask(lbproto.TaskType_NTH_PRIME, TaskSpec{
+		DefaultArg: 1e6,
+		Validate:   argRange(1, 1e8),
+		Cost: func(n int64) float64 {
+			// trial division of the ~n ln n numbers below the n-th prime
+			p := float64(n) * math.Log(float64(n)+2)
+			return p * math.Sqrt(p) / 3e6
+		},
+		Run: nthPrime,
+	})
+	RegisterTask(lbproto.TaskType_FIBONACCI, TaskSpec{
+		DefaultArg: 45,
+		Validate:   argRange(1, 92), // fibonacci(93) overflows an int64
+		Cost:       func(n int64) float64 { return math.Pow(math.Phi, float64(n)) / 2.5e5 },
+		Run:        fibonacci,
+	})
+	RegisterTask(lbproto.TaskType_MATRIX_MULTIPLY, TaskSpec{
+		DefaultArg: 300,
+		Validate:   argRange(1, 2000),
+		Cost:       func(n int64) float64 { return float64(n*n*n) / 1e6 },
+		Run:        matrixMultiply,
+	})
+	RegisterTask(lbproto.TaskType_PROOF_OF_WORK, TaskSpec{
+		DefaultArg: 20,
+		Validate:   argRange(1, 32),
+		Cost:       func(n int64) float64 { return math.Exp2(float64(n)) / 7e3 },
+		Run:        proofOfWork,
+	})
+	RegisterTask(lbproto.TaskType_SORT, TaskSpec{
+		DefaultArg: 1e6,
+		Validate:   argRange(1, 5e7),
+		Cost:       func(n int64) float64 { return float64(n) * math.Log2(float64(n)+1) / 1e5 },
+		Run:        sortRandom,
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExecuteTaskInvalidArgument(t *testing.T) {
+	tests := []struct {
+		tasktype lbproto.TaskType
+		n        int64
+	}{
+		{lbproto.TaskType(42), 1},
+		{lbproto.TaskType(-1), 1},
+		{lbproto.TaskType_SUM, 0}, // 0 is an argument, not a request for the default
+		{lbproto.TaskType_FIBONACCI, 93},
+		{lbproto.TaskType_NTH_PRIME, -5},
+		{lbproto.TaskType_MATRIX_MULTIPLY, 1e6},
+		{lbproto.TaskType_PROOF_OF_WORK, 64},
+	}
+	for _, test := range tests {
+		_, err := executeTask(context.Background(), test.tasktype, test.n)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("executeTask(%v, %d) error = %v, want code %v", test.tasktype, test.n, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestRegisteredTasksHaveSpecs(t *testing.T) {
+	for taskType := range lbproto.TaskType_name {
+		spec, n, err := prepareTask(lbproto.TaskType(taskType), 0, true)
+		if err != nil {
+			t.Errorf("%v: default argument rejected: %v", lbproto.TaskType(taskType), err)
+			continue
+		}
+		if cost := spec.Cost(n); cost <= 0 {
+			t.Errorf("%v: cost hint %v for the default argument", lbproto.TaskType(taskType), cost)
+		}
+	}
+}
+
+func TestMatrixMultiply(t *testing.T) {
+	const n = 7
+	rng := pseudoRandom(n)
+	var a, b [n][n]int64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			a[i][j], b[i][j] = rng.Int64N(100), rng.Int64N(100)
+		}
+	}
+	want := int64(0)
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			want += a[i][k] * b[k][i]
+		}
+	}
+
+	got, err := executeTask(context.Background(), lbproto.TaskType_MATRIX_MULTIPLY, n)
+	if err != nil || got != want {
+		t.Errorf("matrixMultiply(%d) = %d, %v, want %d", n, got, err, want)
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	const zeroBits = 12
+	nonce, err := executeTask(context.Background(), lbproto.TaskType_PROOF_OF_WORK, zeroBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := binary.LittleEndian.AppendUint64([]byte("q1-pow:"), uint64(nonce))
+	if hash := sha256.Sum256(message); leadingZeroBits(hash[:]) < zeroBits {
+		t.Errorf("hash of nonce %d is %x, want %d leading zero bits", nonce, hash, zeroBits)
+	}
+}
+
+func TestSortRandom(t *testing.T) {
+	const n = 1001
+	median, err := executeTask(context.Background(), lbproto.TaskType_SORT, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := pseudoRandom(n)
+	below := 0
+	for i := 0; i < n; i++ {
+		if rng.Int64() < median {
+			below++
+		}
+	}
+	if below != n/2 {
+		t.Errorf("sortRandom(%d) = %d, which has %d numbers below it, want %d", n, median, below, n/2)
+	}
+}
@@ -1,52 +1,187 @@
 package main
 
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+)
+
+// checkInterval is how many loop iterations a task runs between two checks
+// of its context.
+const checkInterval = 1 << 10
+
+// cancelCheck lets a loop poll its context cheaply. Once the context is done
+// every later call reports it.
+type cancelCheck struct {
+	ctx   context.Context
+	steps int
+	err   error
+}
+
+func (c *cancelCheck) cancelled() bool {
+	if c.err != nil {
+		return true
+	}
+	c.steps++
+	if c.steps%checkInterval == 0 {
+		c.err = c.ctx.Err()
+	}
+	return c.err != nil
+}
+
 func isPrime(n int64) bool {
-    if n < 2 {
-        return false
-    }
-    for i := int64(2); i*i <= n; i++ { 
-        if n%i == 0 {
-            return false
-        }
-    }
-    return true
-}
-
-func nthPrime(n int64) int64 {
-    if n < 1 {
-        return -1 
-    }
+	if n < 2 {
+		return false
+	}
+	for i := int64(2); i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func nthPrime(ctx context.Context, n int64) (int64, error) {
+	if n < 1 {
+		return -1, nil
+	}
+	check := &cancelCheck{ctx: ctx}
 	cnt, num := int64(0), int64(1)
-    for cnt < n {
-        num++
-        if isPrime(num) {
-            cnt++
-        }
-    }
-    return num
+	for cnt < n {
+		if check.cancelled() {
+			return 0, check.err
+		}
+		num++
+		if isPrime(num) {
+			cnt++
+		}
+	}
+	return num, nil
+}
+
+// fibCheckDepth is the n below which fibonacci recurses without checking its
+// context. fibonacci(fibCheckDepth) takes under a millisecond, and checking
+// on every call would double the cost of the recursion.
+const fibCheckDepth = 24
+
+func fibonacci(ctx context.Context, n int64) (int64, error) {
+	if n < fibCheckDepth {
+		return fib(n), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	a, err := fibonacci(ctx, n-1)
+	if err != nil {
+		return 0, err
+	}
+	b, err := fibonacci(ctx, n-2)
+	if err != nil {
+		return 0, err
+	}
+	return a + b, nil
 }
 
-func fibonacci(n int64) int64{
-    if n <= 1 {
-        return n
-    }
-    return fibonacci(n-1) + fibonacci(n-2)
+func fib(n int64) int64 {
+	if n <= 1 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
 }
 
-func Sum(n int64) int64 {
+func Sum(ctx context.Context, n int64) (int64, error) {
+	check := &cancelCheck{ctx: ctx}
 	sum := int64(0)
-	for i:= int64(0) ; i < n ; i++{
+	for i := int64(0); i < n; i++ {
+		if check.cancelled() {
+			return 0, check.err
+		}
 		sum += (i + 1)
 	}
-	return sum
+	return sum, nil
+}
+
+// pseudoRandom returns a deterministic generator seeded by n, so a task's
+// result depends only on its argument.
+func pseudoRandom(n int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(n), 0x9e3779b97f4a7c15))
+}
+
+// matrixMultiply multiplies two n x n matrices of small pseudo-random
+// integers and returns the trace of the product.
+func matrixMultiply(ctx context.Context, n int64) (int64, error) {
+	rng := pseudoRandom(n)
+	a, b := make([]int64, n*n), make([]int64, n*n)
+	for i := range a {
+		a[i], b[i] = rng.Int64N(100), rng.Int64N(100)
+	}
+
+	product := make([]int64, n*n)
+	for i := int64(0); i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		row := product[i*n : (i+1)*n]
+		for k := int64(0); k < n; k++ {
+			aik, bRow := a[i*n+k], b[k*n:(k+1)*n]
+			for j := range row {
+				row[j] += aik * bRow[j]
+			}
+		}
+	}
+
+	trace := int64(0)
+	for i := int64(0); i < n; i++ {
+		trace += product[i*n+i]
+	}
+	return trace, nil
+}
+
+// proofOfWork finds the smallest nonce for which SHA-256 of "q1-pow:" and
+// the nonce as 8 little-endian bytes starts with zeroBits zero bits.
+func proofOfWork(ctx context.Context, zeroBits int64) (int64, error) {
+	check := &cancelCheck{ctx: ctx}
+	message := []byte("q1-pow:\x00\x00\x00\x00\x00\x00\x00\x00")
+	for nonce := int64(0); ; nonce++ {
+		if check.cancelled() {
+			return 0, check.err
+		}
+		binary.LittleEndian.PutUint64(message[len(message)-8:], uint64(nonce))
+		hash := sha256.Sum256(message)
+		if leadingZeroBits(hash[:]) >= zeroBits {
+			return nonce, nil
+		}
+	}
+}
+
+func leadingZeroBits(hash []byte) int64 {
+	zeros := int64(0)
+	for _, b := range hash {
+		if b != 0 {
+			return zeros + int64(bits.LeadingZeros8(b))
+		}
+		zeros += 8
+	}
+	return zeros
 }
 
-func executeTask(tasktype int32, n int64) (int64){
-	if tasktype == 0 {
-		return Sum(n)
-	} else if tasktype == 1 {
-		return nthPrime(n)
-	} else {
-		return fibonacci(n)
+// sortRandom sorts n pseudo-random numbers and returns the median. The sort
+// itself cannot be interrupted; ctx is checked before and after it.
+func sortRandom(ctx context.Context, n int64) (int64, error) {
+	rng := pseudoRandom(n)
+	numbers := make([]int64, n)
+	for i := range numbers {
+		numbers[i] = rng.Int64()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
-}
\ No newline at end of file
+	slices.Sort(numbers)
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return numbers[n/2], nil
+}
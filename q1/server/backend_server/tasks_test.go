@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	lbproto "q1/protofiles"
+)
+
+// fibonacci(50) takes minutes, so a task that ignored its context would
+// time the test out.
+const cancelDeadline = time.Second
+
+func TestFibonacciCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := fibonacci(ctx, 50)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("fibonacci(50) error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > cancelDeadline {
+		t.Errorf("fibonacci(50) returned %v after being cancelled", elapsed)
+	}
+}
+
+func TestFibonacciDeadlineExceeded(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fibonacci(ctx, 50)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("fibonacci(50) error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > cancelDeadline {
+		t.Errorf("fibonacci(50) returned %v after its deadline", elapsed)
+	}
+}
+
+func TestExecuteTaskCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tasktype := range registeredTasks() {
+		if _, err := executeTask(ctx, tasktype, taskSpecs[tasktype].DefaultArg); !errors.Is(err, context.Canceled) {
+			t.Errorf("executeTask(%v) error = %v, want %v", tasktype, err, context.Canceled)
+		}
+	}
+}
+
+func TestExecuteTaskResults(t *testing.T) {
+	tests := []struct {
+		tasktype lbproto.TaskType
+		n        int64
+		want     int64
+	}{
+		{lbproto.TaskType_SUM, 100, 5050},
+		{lbproto.TaskType_NTH_PRIME, 10, 29},
+		{lbproto.TaskType_FIBONACCI, 30, 832040},
+		{lbproto.TaskType_FIBONACCI, 1, 1},
+	}
+	for _, test := range tests {
+		got, err := executeTask(context.Background(), test.tasktype, test.n)
+		if err != nil || got != test.want {
+			t.Errorf("executeTask(%v, %d) = %d, %v, want %d", test.tasktype, test.n, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdminServer implements AdminService, the operator calls lbctl makes. The
+// calls act on this load balancer only; in --ha mode a standby keeps its own
+// policy and drained backends.
+type AdminServer struct {
+	lbproto.UnimplementedAdminServiceServer
+}
+
+func (s *AdminServer) SetPolicy(ctx context.Context, req *lbproto.SetPolicyRequest) (*lbproto.SetPolicyResponse, error) {
+	policy, err := lbpolicy.New(req.GetPolicy())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	previous := backendServersInfo.setPolicy(req.GetPolicy(), policy)
+	log.Printf("Load Balancer - Policy changed from %s to %s", previous, req.GetPolicy())
+	return &lbproto.SetPolicyResponse{Previous: previous, Policy: req.GetPolicy()}, nil
+}
+
+func (s *AdminServer) ListBackends(ctx context.Context, req *lbproto.Empty) (*lbproto.BackendList, error) {
+	return backendServersInfo.backendList(), nil
+}
+
+func (s *AdminServer) DrainBackend(ctx context.Context, req *lbproto.DrainBackendRequest) (*lbproto.BackendInfo, error) {
+	backendInfo, err := backendServersInfo.drain(req.GetAddr(), !req.GetResume())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetResume() {
+		log.Printf("Load Balancer - Backend server %s resumed", req.GetAddr())
+	} else {
+		log.Printf("Load Balancer - Draining backend server %s, %d requests in flight", req.GetAddr(), backendInfo.GetInFlight())
+	}
+	return backendInfo, nil
+}
+
+func (s *AdminServer) GetStats(ctx context.Context, req *lbproto.Empty) (*lbproto.LoadBalancerStats, error) {
+	stats := backendServersInfo.stats()
+	stats.Leader = leadership.isLeader()
+	return stats, nil
+}
+
+// setPolicy replaces the policy and returns the name of the previous one.
+// Picks hold mutexLock, so each pick runs entirely under one policy. The new
+// policy starts without the old one's state, such as the round robin
+// position.
+func (info *BackendServerInfo) setPolicy(name string, policy lbpolicy.Policy) string {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	previous := info.policyName
+	info.policy, info.policyName = policy, name
+	info.policyChanges++
+	info.membersChanged()
+	return previous
+}
+
+// drain stops or restarts picking a backend. A backend that leaves the
+// registry and joins again starts undrained.
+func (info *BackendServerInfo) drain(serverAddr string, draining bool) (*lbproto.BackendInfo, error) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	state, exists := info.backends[serverAddr]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "no registered backend server %s", serverAddr)
+	}
+	state.draining = draining
+	return info.backendInfo(state, time.Now()), nil
+}
+
+// backendInfo describes a backend for AdminService. The caller must hold
+// mutexLock.
+func (info *BackendServerInfo) backendInfo(state *backendState, now time.Time) *lbproto.BackendInfo {
+	backendInfo := &lbproto.BackendInfo{
+		Addr:         state.backend.Addr,
+		Weight:       state.backend.Weight,
+		Tasks:        registry.FormatTasks(state.instance.Tasks),
+		Load:         state.backend.Load,
+		Metric:       state.loadMetric,
+		InFlight:     state.backend.InFlight,
+		QueueDepth:   state.backend.QueueDepth,
+		Latency:      float32(state.backend.Latency.Value()),
+		LoadStale:    state.loadStale,
+		Healthy:      state.healthy,
+		FailedChecks: int32(state.failedChecks),
+		Draining:     state.draining,
+		ShuttingDown: state.leaving,
+		Picks:        state.picks,
+		Errors:       state.errors,
+		Zone:         state.instance.Zone,
+		Region:       state.instance.Region,
+	}
+	if !state.lastReport.IsZero() {
+		backendInfo.LastReport = state.lastReport.UnixNano()
+	}
+	if until := info.outliers.EjectedUntil(state.backend.Addr, now); !until.IsZero() {
+		backendInfo.EjectedUntil = until.UnixNano()
+	}
+	return backendInfo
+}
+
+func (info *BackendServerInfo) backendList() *lbproto.BackendList {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	now := time.Now()
+	list := &lbproto.BackendList{Policy: info.policyName}
+	for _, serverAddr := range info.availableServers {
+		list.Backends = append(list.Backends, info.backendInfo(info.backends[serverAddr], now))
+	}
+	return list
+}
+
+func (info *BackendServerInfo) stats() *lbproto.LoadBalancerStats {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	stats := &lbproto.LoadBalancerStats{
+		Policy:        info.policyName,
+		StartedAt:     info.startedAt.UnixNano(),
+		Picks:         info.picks,
+		PickErrors:    info.pickErrors,
+		Proxied:       info.proxied,
+		ProxyErrors:   info.proxyErrors,
+		PolicyChanges: info.policyChanges,
+		Ejections:     info.ejections,
+		Outcomes:      info.outcomes,
+		Spillovers:    info.spillovers,
+		Backends:      int32(len(info.availableServers)),
+	}
+	now := time.Now()
+	for serverAddr, state := range info.backends {
+		if info.outliers.Ejected(serverAddr, now) {
+			stats.Ejected++
+		}
+		if state.healthy {
+			stats.Healthy++
+		}
+		if state.draining {
+			stats.Draining++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pickTokenTTL is how long after a pick its outcome may be reported.
+const pickTokenTTL = 10 * time.Minute
+
+type BackendServerInfo struct {
+	availableServers []string // in registration order
+	mutexLock        sync.Mutex
+	backends         map[string]*backendState
+	policy           lbpolicy.Policy
+	policyName       string
+	loadTTL          time.Duration // age after which a load report is ignored
+	outliers         *lbpolicy.OutlierDetector
+	spillover        float64 // in flight per unit of weight past which picks leave the caller's zone
+
+	// picks handed to clients whose outcome is not reported yet, by pick
+	// token, and their tokens in the order they were handed out
+	outstanding map[uint64]outstandingPick
+	pickTokens  []uint64
+
+	// counters for GetStats
+	startedAt     time.Time
+	picks         uint64
+	pickErrors    uint64
+	proxied       uint64
+	proxyErrors   uint64
+	policyChanges uint64
+	ejections     uint64
+	outcomes      uint64
+	spillovers    uint64
+}
+
+// backendState is the load balancer's view of one registered backend: what
+// the policies see plus what decides whether the backend is a candidate.
+type backendState struct {
+	backend      *lbpolicy.Backend
+	instance     registry.Instance  // latest registration
+	loadMetric   lbproto.LoadMetric // what backend.Load measures
+	lastReport   time.Time          // when the last load report arrived, zero if none
+	lastSeq      uint64
+	lastMeasured int64 // timestamp of the last load report
+	loadStale    bool  // no report for loadTTL, load is an estimate
+	healthy      bool
+	failedChecks int  // consecutive failed health checks
+	draining     bool // set with DrainBackend, not picked until resumed
+	leaving      bool // the backend reported it is shutting down
+	picks        uint64
+	errors       uint64 // failed proxied requests
+}
+
+func newBackendState(inst registry.Instance) *backendState {
+	return &backendState{
+		backend:  &lbpolicy.Backend{Addr: inst.Addr, Weight: inst.EffectiveWeight(), Zone: inst.Zone, Region: inst.Region},
+		instance: inst,
+		healthy:  true, // until health checks say otherwise
+	}
+}
+
+// register applies a new registration of the backend.
+func (state *backendState) register(inst registry.Instance) {
+	state.instance = inst
+	state.backend.Weight = inst.EffectiveWeight()
+	state.backend.Zone, state.backend.Region = inst.Zone, inst.Region
+}
+
+// available reports whether policies may pick the backend.
+func (state *backendState) available() bool {
+	return state.healthy && !state.draining && !state.leaving
+}
+
+// releasePick undoes the InFlight increment of pick.
+func (state *backendState) releasePick() {
+	if state.backend.InFlight > 0 {
+		state.backend.InFlight--
+	}
+}
+
+// outstandingPick is a backend LoadBalancerRPC gave a client for a call whose
+// outcome the client has not reported yet.
+type outstandingPick struct {
+	addr     string
+	taskType int32
+	at       time.Time
+}
+
+func NewBackendServerInfo(policyName string, policy lbpolicy.Policy, loadTTL time.Duration, outliers *lbpolicy.OutlierDetector, spillover float64) *BackendServerInfo {
+	return &BackendServerInfo{
+		backends:    make(map[string]*backendState),
+		policy:      policy,
+		policyName:  policyName,
+		loadTTL:     loadTTL,
+		outliers:    outliers,
+		spillover:   spillover,
+		startedAt:   time.Now(),
+		outstanding: make(map[uint64]outstandingPick),
+	}
+}
+
+// setServers replaces the known backend set, keeping the state of servers
+// that are still present. It returns the servers that were dropped.
+func (info *BackendServerInfo) setServers(instances []registry.Instance) []string {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	updatedBackends := make(map[string]*backendState)
+	servers := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		state, exists := info.backends[inst.Addr]
+		if !exists {
+			state = newBackendState(inst)
+		}
+		state.register(inst)
+		updatedBackends[inst.Addr] = state
+		servers = append(servers, inst.Addr)
+		info.outliers.Add(inst.Addr)
+	}
+	var removed []string
+	for serverAddr, state := range info.backends {
+		if _, exists := updatedBackends[serverAddr]; !exists {
+			removed = append(removed, serverAddr)
+			info.outliers.Remove(serverAddr)
+			deleteLoadMetrics(state)
+		}
+	}
+	info.backends = updatedBackends
+	info.availableServers = servers
+	info.membersChanged()
+	return removed
+}
+
+// addServer adds a registered server, or updates its registration if it is
+// already known. It reports whether the server is new.
+func (info *BackendServerInfo) addServer(inst registry.Instance) bool {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	if state, exists := info.backends[inst.Addr]; exists {
+		state.register(inst)
+		info.membersChanged() // the weight may have changed
+		return false
+	}
+	info.backends[inst.Addr] = newBackendState(inst)
+	info.availableServers = append(info.availableServers, inst.Addr)
+	info.outliers.Add(inst.Addr)
+	info.membersChanged()
+	return true
+}
+
+func (info *BackendServerInfo) removeServer(serverAddr string) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	state, exists := info.backends[serverAddr]
+	if !exists {
+		return
+	}
+	delete(info.backends, serverAddr)
+	info.outliers.Remove(serverAddr)
+	deleteLoadMetrics(state)
+	for i, server := range info.availableServers {
+		if server == serverAddr {
+			info.availableServers = append(info.availableServers[:i:i], info.availableServers[i+1:]...)
+			break
+		}
+	}
+	info.membersChanged()
+}
+
+// membersChanged tells a policy that keeps state over every registered
+// backend, such as the CH ring, what they now are. The caller must hold
+// mutexLock.
+func (info *BackendServerInfo) membersChanged() {
+	watcher, ok := info.policy.(lbpolicy.MembershipWatcher)
+	if !ok {
+		return
+	}
+	members := make([]*lbpolicy.Backend, 0, len(info.availableServers))
+	for _, serverAddr := range info.availableServers {
+		members = append(members, info.backends[serverAddr].backend)
+	}
+	watcher.SetMembers(members)
+}
+
+// servers returns the addresses of all registered backends.
+func (info *BackendServerInfo) servers() []string {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	return append([]string(nil), info.availableServers...)
+}
+
+// updateLoad records a load report. Reports from servers that are not
+// registered and reports older than the last one are ignored.
+func (info *BackendServerInfo) updateLoad(status *lbproto.LoadStatus) {
+	info.applyLoad(status, time.Now(), false)
+}
+
+// mirrorLoad records a backend's load from the leader's LoadReport, as of
+// when the leader received it, so a standby ages it out as the leader does.
+// Backends the leader has no report from are left alone, and so is the
+// leader's admin drain: a standby keeps its own drained backends.
+func (info *BackendServerInfo) mirrorLoad(status *lbproto.LoadStatus) {
+	if status.GetReportedAt() == 0 {
+		return
+	}
+	info.applyLoad(status, time.Unix(0, status.GetReportedAt()), true)
+}
+
+// applyLoad records a load report received at reportedAt. The latency of a
+// mirrored report is the leader's average, which is copied rather than
+// averaged again.
+func (info *BackendServerInfo) applyLoad(status *lbproto.LoadStatus, reportedAt time.Time, mirrored bool) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	state, exists := info.backends[status.GetServerAddr()]
+	if !exists {
+		return
+	}
+	// A restarted backend starts again at seq 1 but with a later timestamp
+	if status.GetSeq() != 0 && status.GetSeq() <= state.lastSeq && status.GetTimestamp() <= state.lastMeasured {
+		return
+	}
+	state.lastSeq, state.lastMeasured = status.GetSeq(), status.GetTimestamp()
+	state.lastReport = reportedAt
+	if state.loadStale && time.Since(reportedAt) <= info.loadTTL {
+		state.loadStale = false
+		log.Printf("Backend server %s is reporting load again", status.GetServerAddr())
+	}
+	if status.GetDraining() != state.leaving {
+		state.leaving = status.GetDraining()
+		if state.leaving {
+			log.Printf("Backend server %s is draining, excluding it", status.GetServerAddr())
+		}
+	}
+	previousMetric := loadMetricLabel(state)
+	state.backend.Load = status.GetLoad()
+	state.backend.InFlight = status.GetInFlight()
+	state.backend.QueueDepth = status.GetQueueDepth()
+	state.loadMetric = status.GetMetric()
+	setLoadMetrics(state, previousMetric)
+	latencyMs := status.GetLatency()
+	if mirrored {
+		state.backend.Latency.Set(float64(latencyMs), time.Now())
+	} else if latencyMs > 0 {
+		state.backend.Latency.Observe(float64(latencyMs), time.Now())
+	}
+}
+
+// requestDone records the outcome of a request the load balancer forwarded
+// itself (proxy mode), where it sees the real latency.
+func (info *BackendServerInfo) requestDone(serverAddr string, taskType int32, latency time.Duration, err error) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	info.proxied++
+	if err != nil {
+		info.proxyErrors++
+	}
+	state, exists := info.backends[serverAddr]
+	if !exists {
+		return
+	}
+	state.releasePick()
+	if err != nil {
+		state.errors++
+	} else {
+		state.backend.Latency.Observe(float64(latency.Microseconds())/1000, time.Now())
+	}
+	info.recordOutcome(serverAddr, taskType, status.Code(err), latency, time.Now())
+}
+
+// trackPick hands out the token a client reports the outcome of a call to
+// serverAddr with. Picks whose outcome has not come after pickTokenTTL are
+// forgotten.
+func (info *BackendServerInfo) trackPick(serverAddr string, taskType int32, now time.Time) uint64 {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	for len(info.pickTokens) > 0 {
+		pick, exists := info.outstanding[info.pickTokens[0]]
+		if exists && now.Sub(pick.at) < pickTokenTTL {
+			break
+		}
+		delete(info.outstanding, info.pickTokens[0])
+		info.pickTokens = info.pickTokens[1:]
+	}
+	var token uint64
+	for {
+		token = rand.Uint64()
+		if _, taken := info.outstanding[token]; token != 0 && !taken {
+			break
+		}
+	}
+	info.outstanding[token] = outstandingPick{addr: serverAddr, taskType: taskType, at: now}
+	info.pickTokens = append(info.pickTokens, token)
+	return token
+}
+
+// outcomeReported records the outcome of a call a client made to a backend
+// it was given. The call is over, so like requestDone it undoes the pick's
+// in-flight count rather than leave it until the next load report; without
+// that a busy zone would look overloaded and spill over. Only the first
+// outcome of a pick still outstanding counts, so a client cannot eject a
+// backend with outcomes of calls it was never given.
+func (info *BackendServerInfo) outcomeReported(outcome *lbproto.CallOutcome) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	pick, exists := info.outstanding[outcome.GetPickToken()]
+	if !exists || pick.addr != outcome.GetServerAddr() {
+		return
+	}
+	delete(info.outstanding, outcome.GetPickToken())
+	state, exists := info.backends[pick.addr]
+	if !exists {
+		return
+	}
+	state.releasePick()
+	// a pick the client did not use, or whose call it cancelled, says
+	// nothing about the backend
+	if codes.Code(outcome.GetCode()) == codes.Canceled {
+		return
+	}
+	latency := time.Duration(float64(outcome.GetLatency()) * float64(time.Millisecond))
+	info.recordOutcome(pick.addr, pick.taskType, codes.Code(outcome.GetCode()), latency, time.Now())
+}
+
+// recordOutcome feeds a call outcome to outlier detection. The caller must
+// hold mutexLock.
+func (info *BackendServerInfo) recordOutcome(serverAddr string, taskType int32, code codes.Code, latency time.Duration, now time.Time) {
+	info.outcomes++
+	if ev := info.outliers.Record(serverAddr, taskType, lbpolicy.OutlierFailure(code), latency, now); ev != nil {
+		info.outlierEvent(*ev)
+	}
+}
+
+// releasePick undoes the in-flight count of a pick whose request is not
+// followed by requestDone.
+func (info *BackendServerInfo) releasePick(serverAddr string) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	if state, exists := info.backends[serverAddr]; exists {
+		state.releasePick()
+	}
+}
+
+// registered reports whether serverAddr is a registered backend.
+func (info *BackendServerInfo) registered(serverAddr string) bool {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	_, exists := info.backends[serverAddr]
+	return exists
+}
+
+// pick chooses a backend for req with the current policy, among the
+// available backends that serve req.TaskType and are not ejected by outlier
+// detection, preferring those in the caller's zone or region. Backends in
+// exclude are only picked when no other one is left. When there is none the
+// error has code Unavailable.
+func (info *BackendServerInfo) pick(req lbpolicy.PickRequest, exclude []string) (string, error) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	if len(info.availableServers) == 0 {
+		info.pickFailed()
+		return "", status.Error(codes.Unavailable, "no available backend servers")
+	}
+	now := time.Now()
+	info.ageOutLoads(now)
+	for _, ev := range info.outliers.Sweep(now) {
+		info.outlierEvent(ev)
+	}
+
+	capable, draining := 0, 0
+	candidates := make([]*lbpolicy.Backend, 0, len(info.availableServers))
+	var ejected, excluded, slowStarting []*lbpolicy.Backend
+	for _, serverAddr := range info.availableServers {
+		state := info.backends[serverAddr]
+		if !state.instance.Supports(req.TaskType) {
+			continue
+		}
+		capable++
+		if state.draining || state.leaving {
+			draining++
+		}
+		switch {
+		case !state.available():
+		case info.outliers.Ejected(serverAddr, now):
+			ejected = append(ejected, state.backend)
+		case slices.Contains(exclude, serverAddr):
+			excluded = append(excluded, state.backend)
+		case !info.outliers.Admit(serverAddr, now):
+			slowStarting = append(slowStarting, state.backend)
+		default:
+			candidates = append(candidates, state.backend)
+		}
+	}
+	// Rather than fail, use backends that are slow starting, then excluded
+	// ones, then ejected ones
+	for _, fallback := range [][]*lbpolicy.Backend{slowStarting, excluded, ejected} {
+		if len(candidates) == 0 {
+			candidates = fallback
+		}
+	}
+	if capable == 0 {
+		info.pickFailed()
+		return "", status.Errorf(codes.Unavailable, "no backend server supports task type %d (%d registered)", req.TaskType, len(info.availableServers))
+	}
+	if len(candidates) == 0 {
+		info.pickFailed()
+		return "", status.Errorf(codes.Unavailable, "no healthy backend servers for task type %d (%d capable, %d draining)", req.TaskType, capable, draining)
+	}
+
+	candidates, tier := lbpolicy.LocalBackends(req, candidates, info.spillover)
+	if tier > req.NearestTier() {
+		info.spillovers++
+		spilloverCount.Inc()
+	}
+
+	backend := info.policy.Pick(req, candidates)
+	backend.InFlight++ // until the next report from the backend counts it
+	info.picks++
+	pickCount.WithLabelValues(backend.Addr, info.policyName).Inc()
+	info.backends[backend.Addr].picks++
+	return backend.Addr, nil
+}
+
+// pickFailed counts a request no backend could be picked for. The caller
+// must hold mutexLock.
+func (info *BackendServerInfo) pickFailed() {
+	info.pickErrors++
+	pickErrorCount.WithLabelValues(info.policyName).Inc()
+}
+
+// ageOutLoads stops trusting load reports older than loadTTL. A stale
+// backend is given the mean load of the backends that are reporting and an
+// empty queue, so load-aware policies neither prefer nor avoid it; backends
+// that never reported keep load 0, like newly started ones. The caller must
+// hold mutexLock.
+func (info *BackendServerInfo) ageOutLoads(now time.Time) {
+	freshLoad, fresh := float32(0), 0
+	for _, state := range info.backends {
+		if !state.lastReport.IsZero() && now.Sub(state.lastReport) <= info.loadTTL {
+			freshLoad += state.backend.Load
+			fresh++
+		}
+	}
+	meanLoad := float32(0)
+	if fresh > 0 {
+		meanLoad = freshLoad / float32(fresh)
+	}
+
+	for serverAddr, state := range info.backends {
+		if state.lastReport.IsZero() || now.Sub(state.lastReport) <= info.loadTTL {
+			continue
+		}
+		if !state.loadStale {
+			state.loadStale = true
+			log.Printf("No load report from backend server %s for %v, ignoring its last load", serverAddr, now.Sub(state.lastReport).Round(time.Second))
+		}
+		state.backend.Load = meanLoad
+		state.backend.QueueDepth = 0
+	}
+}
+
+// loadReport returns the load of every backend, for SubscribeLoad.
+func (info *BackendServerInfo) loadReport() *lbproto.LoadReport {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	report := &lbproto.LoadReport{}
+	for _, serverAddr := range info.availableServers {
+		state := info.backends[serverAddr]
+		reportedAt := int64(0)
+		if !state.lastReport.IsZero() {
+			reportedAt = state.lastReport.UnixNano()
+		}
+		report.Backends = append(report.Backends, &lbproto.LoadStatus{
+			ServerAddr: state.backend.Addr,
+			Load:       state.backend.Load,
+			InFlight:   state.backend.InFlight,
+			QueueDepth: state.backend.QueueDepth,
+			Latency:    float32(state.backend.Latency.Value()),
+			Metric:     state.loadMetric,
+			Seq:        state.lastSeq,
+			Timestamp:  state.lastMeasured,
+			Draining:   state.leaving,
+			Drained:    state.draining,
+			ReportedAt: reportedAt,
+		})
+	}
+	return report
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOutcomeNeedsOutstandingPick(t *testing.T) {
+	const addr, other = "localhost:6301", "localhost:6302"
+	policy, _ := lbpolicy.New("PF")
+	outliers := lbpolicy.NewOutlierDetector(lbpolicy.OutlierConfig{ConsecutiveErrors: 1, BaseEjectionTime: time.Minute, MaxEjectionTime: time.Minute, MaxEjectionPercent: 100})
+	info := NewBackendServerInfo("PF", policy, time.Minute, outliers, 0)
+	info.setServers([]registry.Instance{{Addr: addr}, {Addr: other}})
+	failure := func(serverAddr string, token uint64) *lbproto.CallOutcome {
+		return &lbproto.CallOutcome{ServerAddr: serverAddr, Code: int32(codes.Unavailable), PickToken: token}
+	}
+	inFlight := func() int32 {
+		info.mutexLock.Lock()
+		defer info.mutexLock.Unlock()
+		return info.backends[addr].backend.InFlight
+	}
+
+	if _, err := info.pick(lbpolicy.PickRequest{}, nil); err != nil {
+		t.Fatalf("pick failed: %v", err)
+	}
+	now := time.Now()
+	token := info.trackPick(addr, 0, now)
+	// outcomes of picks the load balancer did not hand out are ignored
+	info.outcomeReported(failure(addr, 0))
+	info.outcomeReported(failure(addr, token+1))
+	info.outcomeReported(failure(other, token))
+	if info.outcomes != 0 || inFlight() != 1 || outliers.Ejected(addr, now) {
+		t.Fatalf("outcomes without a valid token counted: %d outcomes, %d in flight", info.outcomes, inFlight())
+	}
+
+	info.outcomeReported(failure(addr, token))
+	if info.outcomes != 1 || inFlight() != 0 || !outliers.Ejected(addr, time.Now()) {
+		t.Fatalf("outcome of the pick not counted: %d outcomes, %d in flight", info.outcomes, inFlight())
+	}
+	// a pick's outcome counts once
+	info.outcomeReported(failure(addr, token))
+	if info.outcomes != 1 || inFlight() != 0 {
+		t.Errorf("second outcome of a pick counted: %d outcomes, %d in flight", info.outcomes, inFlight())
+	}
+
+	// picks not reported on within pickTokenTTL are forgotten
+	old := info.trackPick(other, 0, now)
+	info.trackPick(other, 0, now.Add(pickTokenTTL))
+	info.outcomeReported(failure(other, old))
+	if info.outcomes != 1 {
+		t.Errorf("outcome of an expired pick counted")
+	}
+}
+
+func TestPickFallbacks(t *testing.T) {
+	type backend struct {
+		addr  string
+		tasks []registry.TaskCapability // all task types if empty
+		state string                    // "ejected", "slow starting", "draining" or "" for healthy
+	}
+	onlyTask := func(taskType int32) []registry.TaskCapability {
+		return []registry.TaskCapability{{TaskType: taskType}}
+	}
+	tests := []struct {
+		name     string
+		backends []backend // PF picks the first candidate
+		exclude  []string
+		taskType int32
+		want     string // empty for Unavailable
+	}{
+		{
+			name:     "backend without the task skipped",
+			backends: []backend{{addr: "a", tasks: onlyTask(1)}, {addr: "b", tasks: onlyTask(2)}},
+			taskType: 2,
+			want:     "b",
+		},
+		{
+			name:     "no capable backend",
+			backends: []backend{{addr: "a", tasks: onlyTask(1)}},
+			taskType: 2,
+		},
+		{
+			name:     "capable backend ejected rather than an incapable one",
+			backends: []backend{{addr: "a", tasks: onlyTask(1)}, {addr: "b", tasks: onlyTask(2), state: "ejected"}},
+			taskType: 2,
+			want:     "b",
+		},
+		{
+			name:     "healthy first",
+			backends: []backend{{addr: "e", state: "ejected"}, {addr: "x"}, {addr: "h"}, {addr: "s", state: "slow starting"}},
+			exclude:  []string{"x"},
+			want:     "h",
+		},
+		{
+			name:     "slow starting before excluded and ejected",
+			backends: []backend{{addr: "e", state: "ejected"}, {addr: "x"}, {addr: "s", state: "slow starting"}},
+			exclude:  []string{"x"},
+			want:     "s",
+		},
+		{
+			name:     "excluded before ejected",
+			backends: []backend{{addr: "e", state: "ejected"}, {addr: "x"}},
+			exclude:  []string{"x"},
+			want:     "x",
+		},
+		{
+			name:     "ejected as a last resort",
+			backends: []backend{{addr: "e", state: "ejected"}},
+			want:     "e",
+		},
+		{
+			name:     "draining never picked",
+			backends: []backend{{addr: "d", state: "draining"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, _ := lbpolicy.New("PF")
+			outliers := lbpolicy.NewOutlierDetector(lbpolicy.OutlierConfig{
+				ConsecutiveErrors:  1,
+				BaseEjectionTime:   time.Hour,
+				MaxEjectionTime:    time.Hour,
+				MaxEjectionPercent: 100,
+				SlowStart:          10 * time.Hour,
+			})
+			info := NewBackendServerInfo("PF", policy, time.Minute, outliers, 0)
+			var instances []registry.Instance
+			for _, b := range tt.backends {
+				instances = append(instances, registry.Instance{Addr: b.addr, Tasks: b.tasks})
+			}
+			info.setServers(instances)
+			now := time.Now()
+			for _, b := range tt.backends {
+				switch b.state {
+				case "ejected":
+					outliers.Record(b.addr, 0, true, 0, now)
+				case "slow starting":
+					// ejected two hours ago for an hour
+					outliers.Record(b.addr, 0, true, 0, now.Add(-2*time.Hour))
+				case "draining":
+					if _, err := info.drain(b.addr, true); err != nil {
+						t.Fatalf("drain failed: %v", err)
+					}
+				}
+			}
+
+			got, err := info.pick(lbpolicy.PickRequest{TaskType: tt.taskType}, tt.exclude)
+			if tt.want == "" {
+				if status.Code(err) != codes.Unavailable {
+					t.Errorf("pick = %q, %v, want Unavailable", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("pick = %q, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+const embeddedEtcdStartTimeout = 10 * time.Second
+
+// EmbeddedEtcd is a single-node etcd server run inside the load balancer, so
+// a local cluster does not need an external etcd (see --embedded-etcd).
+type EmbeddedEtcd struct {
+	etcd    *embed.Etcd
+	dataDir string
+}
+
+// startEmbeddedEtcd starts etcd serving clients on clientAddr (host:port)
+// with its data in a new temp directory.
+func startEmbeddedEtcd(clientAddr string) (*EmbeddedEtcd, error) {
+	dataDir, err := os.MkdirTemp("", "q1-etcd-")
+	if err != nil {
+		return nil, err
+	}
+
+	clientURL, err := url.Parse("http://" + strings.TrimPrefix(clientAddr, "http://"))
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = dataDir
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{*clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{*clientURL}
+
+	etcd, err := embed.StartEtcd(cfg)
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(embeddedEtcdStartTimeout):
+		etcd.Close()
+		os.RemoveAll(dataDir)
+		return nil, fmt.Errorf("embedded etcd took longer than %v to start", embeddedEtcdStartTimeout)
+	}
+
+	log.Printf("Embedded etcd is serving clients on %s (data in %s)", clientURL.Host, dataDir)
+	return &EmbeddedEtcd{etcd: etcd, dataDir: dataDir}, nil
+}
+
+// Close stops the server and removes its data directory.
+func (e *EmbeddedEtcd) Close() {
+	e.etcd.Close()
+	if err := os.RemoveAll(e.dataDir); err != nil {
+		log.Printf("Failed to remove embedded etcd data: %v", err)
+	}
+}
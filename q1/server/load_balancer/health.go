@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// unhealthyThreshold is the number of consecutive failed checks after which
+// a backend is excluded. One successful check brings it back.
+const unhealthyThreshold = 2
+
+// checkBackendHealth probes every backend's grpc.health.v1.Health service
+// each interval until ctx is done.
+func checkBackendHealth(ctx context.Context, conns *BackendConnPool, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var wg sync.WaitGroup
+		for _, serverAddr := range backendServersInfo.servers() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				backendServersInfo.recordHealth(serverAddr, probeBackend(ctx, conns, serverAddr, timeout))
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// probeBackend reports whether the backend answers its health check with
+// SERVING within timeout.
+func probeBackend(ctx context.Context, conns *BackendConnPool, serverAddr string, timeout time.Duration) bool {
+	conn, release, err := conns.Get(serverAddr)
+	if err != nil {
+		return false
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+}
+
+// recordHealth applies the result of a health check.
+func (info *BackendServerInfo) recordHealth(serverAddr string, ok bool) {
+	info.mutexLock.Lock()
+	defer info.mutexLock.Unlock()
+
+	state, exists := info.backends[serverAddr]
+	if !exists {
+		return
+	}
+	if ok {
+		state.failedChecks = 0
+		if !state.healthy {
+			state.healthy = true
+			log.Printf("Backend server %s is healthy again", serverAddr)
+		}
+		return
+	}
+	state.failedChecks++
+	if state.healthy && state.failedChecks >= unhealthyThreshold {
+		state.healthy = false
+		log.Printf("Backend server %s failed %d health checks, excluding it", serverAddr, state.failedChecks)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	leaderSessionTTL = 2 * time.Second // how long a crashed leader blocks failover
+	mirrorRetryDelay = time.Second
+)
+
+// Leadership tracks whether this instance is the active load balancer.
+// Without --ha there is only one instance and it always leads.
+type Leadership struct {
+	mutexLock sync.Mutex
+	leader    bool
+	changed   chan struct{} // closed and replaced on every change
+}
+
+func NewLeadership(leader bool) *Leadership {
+	return &Leadership{leader: leader, changed: make(chan struct{})}
+}
+
+// get returns the current state and a channel closed when it changes.
+func (l *Leadership) get() (bool, <-chan struct{}) {
+	l.mutexLock.Lock()
+	defer l.mutexLock.Unlock()
+	return l.leader, l.changed
+}
+
+func (l *Leadership) isLeader() bool {
+	leader, _ := l.get()
+	return leader
+}
+
+func (l *Leadership) set(leader bool) {
+	l.mutexLock.Lock()
+	defer l.mutexLock.Unlock()
+	if l.leader == leader {
+		return
+	}
+	l.leader = leader
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// errNotLeader turns away requests that only the leader may serve. Clients
+// and backends retry against the leader they find in etcd.
+var errNotLeader = status.Error(codes.Unavailable, "not the leader load balancer")
+
+// checkLeader rejects requests that only the leader may serve.
+func (l *Leadership) checkLeader() error {
+	if !l.isLeader() {
+		return errNotLeader
+	}
+	return nil
+}
+
+// runElection campaigns for leadership under lbAddr until ctx is done. If
+// the etcd session is lost the instance steps down and campaigns again.
+func runElection(ctx context.Context, reg *registry.EtcdRegistry, lbAddr string) {
+	for ctx.Err() == nil {
+		election, err := reg.NewElection(leaderSessionTTL)
+		if err != nil {
+			log.Printf("Leader election failed, retrying: %v", err)
+			time.Sleep(mirrorRetryDelay)
+			continue
+		}
+		log.Println("Campaigning for leadership as", lbAddr)
+		if err := election.Campaign(ctx, lbAddr); err != nil {
+			election.Close()
+			if ctx.Err() == nil {
+				log.Printf("Leader campaign failed, retrying: %v", err)
+				time.Sleep(mirrorRetryDelay)
+			}
+			continue
+		}
+
+		leadership.set(true)
+		log.Println("Elected leader load balancer")
+		select {
+		case <-ctx.Done():
+		case <-election.Done():
+			log.Println("Lost leadership, etcd session expired")
+		}
+		leadership.set(false)
+		election.Close()
+	}
+}
+
+// mirrorLeader keeps a standby's backend loads in step with the leader's
+// by subscribing to its load reports, so a standby that takes over does not
+// start from an empty view. Membership and health are tracked by every
+// instance on its own.
+func mirrorLeader(ctx context.Context, reg *registry.EtcdRegistry, lbAddr string) {
+	for ctx.Err() == nil {
+		leader, changed := leadership.get()
+		if leader {
+			select {
+			case <-ctx.Done():
+			case <-changed:
+			}
+			continue
+		}
+
+		leaderAddr, err := reg.Leader(ctx)
+		if err == nil && leaderAddr != lbAddr {
+			mirrorCtx, cancel := context.WithCancel(ctx)
+			go func() {
+				// stop mirroring as soon as this instance is elected
+				select {
+				case <-changed:
+				case <-mirrorCtx.Done():
+				}
+				cancel()
+			}()
+			err = mirrorLoads(mirrorCtx, leaderAddr)
+			cancel()
+			if ctx.Err() == nil && err != nil {
+				log.Printf("Stopped mirroring leader %s: %v", leaderAddr, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-time.After(mirrorRetryDelay):
+		}
+	}
+}
+
+// mirrorLoads applies the load reports of the leader at leaderAddr until
+// the stream fails or ctx is done.
+func mirrorLoads(ctx context.Context, leaderAddr string) error {
+	conn, err := grpc.NewClient(leaderAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := lbproto.NewLoadBalancingServiceClient(conn).SubscribeLoad(ctx, &lbproto.SubscribeLoadRequest{})
+	if err != nil {
+		return err
+	}
+	log.Println("Mirroring backend loads from leader", leaderAddr)
+	for {
+		report, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, loadStatus := range report.GetBackends() {
+			backendServersInfo.mirrorLoad(loadStatus)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestBackendServerInfo(instances ...registry.Instance) *BackendServerInfo {
+	policy, _ := lbpolicy.New("RR")
+	info := NewBackendServerInfo("RR", policy, time.Minute, lbpolicy.NewOutlierDetector(lbpolicy.OutlierConfig{}), 0)
+	info.setServers(instances)
+	return info
+}
+
+func TestMirrorLoad(t *testing.T) {
+	instances := []registry.Instance{{Addr: "localhost:6201"}, {Addr: "localhost:6202"}, {Addr: "localhost:6203"}}
+	leader := newTestBackendServerInfo(instances...)
+	standby := newTestBackendServerInfo(instances...)
+
+	// 6201 reports and is drained with lbctl, 6202 reports that it is
+	// shutting down, 6203 never reports
+	leader.updateLoad(&lbproto.LoadStatus{ServerAddr: "localhost:6201", Seq: 1, Load: 0.5, Latency: 100})
+	leader.updateLoad(&lbproto.LoadStatus{ServerAddr: "localhost:6202", Seq: 1, Draining: true})
+	if _, err := leader.drain("localhost:6201", true); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+	for _, loadStatus := range leader.loadReport().GetBackends() {
+		standby.mirrorLoad(loadStatus)
+	}
+
+	for _, addr := range []string{"localhost:6201", "localhost:6202", "localhost:6203"} {
+		got, want := standby.backends[addr], leader.backends[addr]
+		if !got.lastReport.Equal(want.lastReport) {
+			t.Errorf("%s: standby's last report at %v, want the leader's %v", addr, got.lastReport, want.lastReport)
+		}
+		if got.draining {
+			t.Errorf("%s: drained on the standby, want the leader's admin drain kept to the leader", addr)
+		}
+		if got.leaving != want.leaving {
+			t.Errorf("%s: leaving = %v on the standby, want %v", addr, got.leaving, want.leaving)
+		}
+	}
+	if load := standby.backends["localhost:6201"].backend.Load; load != 0.5 {
+		t.Errorf("mirrored load = %v, want 0.5", load)
+	}
+
+	// the leader's latency average is copied, not averaged again: once it
+	// has decayed to 40ms the standby has 40ms too
+	leader.backends["localhost:6201"].backend.Latency.Set(40, time.Now())
+	leader.updateLoad(&lbproto.LoadStatus{ServerAddr: "localhost:6201", Seq: 2, Load: 0.5})
+	for _, loadStatus := range leader.loadReport().GetBackends() {
+		standby.mirrorLoad(loadStatus)
+	}
+	if latency := standby.backends["localhost:6201"].backend.Latency.Value(); latency != 40 {
+		t.Errorf("mirrored latency = %vms, want the leader's 40ms", latency)
+	}
+}
+
+// blockingLoadStream is a load report stream whose backend sends nothing
+// until the stream ends.
+type blockingLoadStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *blockingLoadStream) Context() context.Context { return s.ctx }
+
+func (s *blockingLoadStream) Recv() (*lbproto.LoadStatus, error) {
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+func (s *blockingLoadStream) SendAndClose(*lbproto.Empty) error { return nil }
+
+func TestLoadReportsOnlyToLeader(t *testing.T) {
+	backendServersInfo = newTestBackendServerInfo()
+	leadership = NewLeadership(false)
+	defer func() { leadership = nil }()
+	server := &ReportLoadServer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := server.ReportLoadRPC(ctx, &lbproto.LoadStatus{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("standby took a load report, error %v, want Unavailable", err)
+	}
+	if err := server.ReportLoadStream(&blockingLoadStream{ctx: ctx}); status.Code(err) != codes.Unavailable {
+		t.Errorf("standby took a load report stream, error %v, want Unavailable", err)
+	}
+
+	// a stream open when leadership is lost ends, so the backend looks for
+	// the new leader
+	leadership.set(true)
+	ended := make(chan error, 1)
+	go func() { ended <- server.ReportLoadStream(&blockingLoadStream{ctx: ctx}) }()
+	time.Sleep(10 * time.Millisecond)
+	leadership.set(false)
+	select {
+	case err := <-ended:
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("stream ended with %v, want Unavailable", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("stream still open after leadership was lost")
+	}
+}
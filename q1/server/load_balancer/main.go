@@ -2,37 +2,47 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
-	"os"
-	// "math/rand"
-	"sync"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"q1/lbpolicy"
+	"q1/metrics"
 	lbproto "q1/protofiles"
+	"q1/registry"
 
-	"go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
 )
 
 const (
-	etcdServerAddr = "localhost:2379"
-	etcdKeyPrefix  = "/services/backend/"
-	lbServerAddr   = "localhost:50319" 
+	loadPushInterval = time.Second   // how often SubscribeLoad sends a report
 )
 
 var (
 	loadBalancingPolicy = "PF"
+	registryConfig      registry.Config
+	embeddedEtcd        = flag.Bool("embedded-etcd", false, "run an embedded etcd server on the first --etcd-endpoints address")
+	proxyMode           = flag.Bool("proxy", false, "also serve BackendService and forward requests to the chosen backend")
+	healthInterval      = flag.Duration("health-interval", time.Second, "how often to health check each backend, 0 to disable")
+	healthTimeout       = flag.Duration("health-timeout", 500*time.Millisecond, "deadline for a backend health check")
+	loadTTL             = flag.Duration("load-ttl", 5*time.Second, "how long a backend's load report is trusted")
+	lbAddr              = flag.String("addr", registry.DefaultLoadBalancerAddr, "address to serve on, and to campaign with in --ha mode")
+	haMode              = flag.Bool("ha", false, "elect one active load balancer through etcd; the others stand by")
+	lbZone              = flag.String("zone", "", "zone of this load balancer, preferred for the requests it proxies")
+	lbRegion            = flag.String("region", "", "region of this load balancer, preferred for the requests it proxies")
+	metricsAddr         = flag.String("metrics-addr", "localhost:9319", "address to serve Prometheus metrics on at /metrics, empty to disable")
+	spillover           = flag.Float64("spillover", 4, "send requests outside the caller's zone, then region, once its backends average this many requests in flight per unit of weight; 0 only when they are all unavailable")
+	leadership          *Leadership
+	outlierConfig       lbpolicy.OutlierConfig
+	backendConns        = NewBackendConnPool()
 )
 
-type BackendServerInfo struct {
-	availableServers    []string
-	mutexLock            sync.Mutex
-	loadStatus           map[string]float32
-	rrIndex				 int                       
-}
-
 type LoadBalancingServer struct {
 	lbproto.UnimplementedLoadBalancingServiceServer
 }
@@ -41,162 +51,264 @@ type ReportLoadServer struct {
 	lbproto.UnimplementedReportLoadServiceServer
 }
 
-var backendServersInfo = &BackendServerInfo{}
+var backendServersInfo *BackendServerInfo
 
 
 func (s *LoadBalancingServer) LoadBalancerRPC(ctx context.Context, req *lbproto.LoadBalancerRequest) (*lbproto.LoadBalancerResponse, error) {
+	if err := leadership.checkLeader(); err != nil {
+		return nil, err
+	}
 	tasktype := req.GetTaskType()
 	log.Println("Load Balancer - Task Received from Client:", tasktype)
-	backendAddr, err := getBackendServer()
+	backendAddr, err := backendServersInfo.pick(lbpolicy.PickRequest{
+		TaskType: int32(tasktype),
+		HashKey:  req.GetHashKey(),
+		Zone:     req.GetZone(),
+		Region:   req.GetRegion(),
+	}, req.GetExclude())
 	if err != nil{
 		return &lbproto.LoadBalancerResponse{BestServer: ""}, err
 	}
-	return &lbproto.LoadBalancerResponse{BestServer: backendAddr}, nil
+	token := backendServersInfo.trackPick(backendAddr, int32(tasktype), time.Now())
+	return &lbproto.LoadBalancerResponse{BestServer: backendAddr, PickToken: token}, nil
 }
 
-func (s *ReportLoadServer) ReportLoadRPC(ctx context.Context, req *lbproto.LoadStatus) (*lbproto.Empty, error) {
-	serverAddr, load := req.GetServerAddr(), req.GetLoad()
-	// log.Printf("Load Balancer - Load Status Received from Backend Server-%s, Load:%f\n", serverAddr, load)
-	backendServersInfo.mutexLock.Lock()
-	_, exists := backendServersInfo.loadStatus[serverAddr]
-	if exists {
-		backendServersInfo.loadStatus[serverAddr] = load
+func (s *LoadBalancingServer) ReportOutcome(ctx context.Context, req *lbproto.OutcomeReport) (*lbproto.Empty, error) {
+	for _, outcome := range req.GetOutcomes() {
+		backendServersInfo.outcomeReported(outcome)
 	}
-	backendServersInfo.mutexLock.Unlock()
-
 	return &lbproto.Empty{}, nil
 }
 
-func discoverBackends(client *clientv3.Client) {
+func (s *LoadBalancingServer) SubscribeLoad(req *lbproto.SubscribeLoadRequest, stream grpc.ServerStreamingServer[lbproto.LoadReport]) error {
+	ticker := time.NewTicker(loadPushInterval)
+	defer ticker.Stop()
 	for {
-		resp, err := client.Get(context.Background(), etcdKeyPrefix, clientv3.WithPrefix())
-		if err != nil {
-			log.Printf("Failed to fetch backend servers: %v", err)
-			time.Sleep(5 * time.Second)
-			continue
-		}
-
-		var servers []string
-		for _, kv := range resp.Kvs {
-			servers = append(servers, string(kv.Value))
+		if err := stream.Send(backendServersInfo.loadReport()); err != nil {
+			return err
 		}
-		
-		backendServersInfo.mutexLock.Lock()
-		updatedLoadStatus := make(map[string]float32)
-		
-		for _, serverAddr := range servers {
-			load, exists := backendServersInfo.loadStatus[serverAddr]
-			if exists {
-				updatedLoadStatus[serverAddr] = load
-			} else {
-				updatedLoadStatus[serverAddr] = 0.0
-			}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
 		}
-
-		backendServersInfo.loadStatus = updatedLoadStatus
-		backendServersInfo.availableServers = servers
-		backendServersInfo.mutexLock.Unlock()
-
-		// log.Printf("Updated backend servers: %v", backendServersInfo.availableServers)
-		time.Sleep(2 * time.Second)
 	}
-}	
-
-func usePickFirst() (string){
-	backendServersInfo.mutexLock.Lock()
-	reqServer := backendServersInfo.availableServers[0]
-	backendServersInfo.mutexLock.Unlock()
-	return reqServer
 }
 
-func useRoundRobin() (string){
-	backendServersInfo.mutexLock.Lock()
+// Load reports are only taken by the leader. A standby mirrors the leader's
+// loads instead; turning backends away makes them look the leader up again.
+func (s *ReportLoadServer) ReportLoadRPC(ctx context.Context, req *lbproto.LoadStatus) (*lbproto.Empty, error) {
+	if err := leadership.checkLeader(); err != nil {
+		return nil, err
+	}
+	backendServersInfo.updateLoad(req)
 
-	index := backendServersInfo.rrIndex
-	num_servers := len(backendServersInfo.availableServers)
+	return &lbproto.Empty{}, nil
+}
 
+// ReportLoadStream ends the stream as soon as this instance stops leading,
+// so the backend reconnects to the new leader rather than keep reporting
+// here.
+func (s *ReportLoadServer) ReportLoadStream(stream grpc.ClientStreamingServer[lbproto.LoadStatus, lbproto.Empty]) error {
+	leader, changed := leadership.get()
+	if !leader {
+		return errNotLeader
+	}
+	reports := make(chan *lbproto.LoadStatus)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reports <- req:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
 	for {
-		server := backendServersInfo.availableServers[index]
-		_, exists := backendServersInfo.loadStatus[server]
-		if exists {
-			index = (index + 1) % num_servers
-			break;
+		select {
+		case req := <-reports:
+			backendServersInfo.updateLoad(req)
+		case err := <-errs:
+			if err == io.EOF {
+				return stream.SendAndClose(&lbproto.Empty{})
+			}
+			return err
+		case <-changed:
+			return errNotLeader
 		}
-		index = (index + 1) % num_servers
 	}
-
-	reqServer := backendServersInfo.availableServers[index]
-	backendServersInfo.rrIndex = (index + 1) % num_servers
-
-	backendServersInfo.mutexLock.Unlock()
-	return reqServer
 }
 
-func useLeastLoad() (string){
-	backendServersInfo.mutexLock.Lock()
+// discoverBackends keeps backendServersInfo in sync with the registry. It
+// takes a snapshot of the registered backends and then watches from the
+// snapshot's revision, so membership changes are applied as soon as the
+// registry reports them. If the watch is lost (e.g. the revision was
+// compacted) it starts over with a new snapshot.
+func discoverBackends(ctx context.Context, reg registry.Registry) {
+	for ctx.Err() == nil {
+		instances, rev, err := reg.List(ctx)
+		if err != nil {
+			discoveryRefreshCount.WithLabelValues("error").Inc()
+			log.Printf("Failed to fetch backend servers: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		discoveryRefreshCount.WithLabelValues("ok").Inc()
 
-	reqServer := backendServersInfo.availableServers[0]
-	minLoad := backendServersInfo.loadStatus[reqServer]
+		for _, serverAddr := range backendServersInfo.setServers(instances) {
+			backendConns.Remove(serverAddr)
+		}
 
-	for _, server := range backendServersInfo.availableServers {
-		if backendServersInfo.loadStatus[server] < minLoad {
-			minLoad = backendServersInfo.loadStatus[server]
-			reqServer = server
+		err = watchBackends(ctx, reg, rev+1)
+		if ctx.Err() == nil {
+			log.Printf("Backend watch ended, resyncing: %v", err)
 		}
 	}
-
-	backendServersInfo.mutexLock.Unlock()
-	return reqServer
 }
 
-func getBackendServer() (string, error){
-	if len(backendServersInfo.availableServers) == 0{
-		return "", fmt.Errorf("no available backend servers")
+// watchBackends applies registry events starting at rev until the watch fails.
+func watchBackends(ctx context.Context, reg registry.Registry, rev int64) error {
+	for watchResp := range reg.Watch(ctx, rev) {
+		if watchResp.Err != nil {
+			return watchResp.Err
+		}
+		for _, event := range watchResp.Events {
+			serverAddr := event.Instance.Addr
+			switch event.Type {
+			case registry.EventPut:
+				if backendServersInfo.addServer(event.Instance) {
+					discoveryEventCount.WithLabelValues("join").Inc()
+					log.Printf("Backend server joined: %s (weight %d, tasks %s)", serverAddr, event.Instance.EffectiveWeight(), describeTasks(event.Instance))
+				}
+			case registry.EventDelete:
+				backendServersInfo.removeServer(serverAddr)
+				backendConns.Remove(serverAddr)
+				discoveryEventCount.WithLabelValues("leave").Inc()
+				log.Printf("Backend server left: %s", serverAddr)
+			}
+		}
 	}
+	return fmt.Errorf("watch channel closed")
+}
 
-	if loadBalancingPolicy == "PF" {  // Pick First Policy
-		return usePickFirst(), nil
-	} else if loadBalancingPolicy == "RR" {  // Round Robin Policy
-		return useRoundRobin(), nil
-	} else {
-		return useLeastLoad(), nil   // Least Load Policy
+// describeTasks formats the task types inst serves for the log.
+func describeTasks(inst registry.Instance) string {
+	if len(inst.Tasks) == 0 {
+		return "all"
 	}
+	return registry.FormatTasks(inst.Tasks)
+}
+
+// addOutlierFlags registers the outlier detection flags. The defaults are
+// Envoy's, except that half of the backends may be ejected rather than a
+// tenth, which would be none in a small deployment.
+func addOutlierFlags(fs *flag.FlagSet, c *lbpolicy.OutlierConfig) {
+	fs.IntVar(&c.ConsecutiveErrors, "outlier-consecutive-errors", 5, "eject a backend after this many failed calls in a row, 0 to disable")
+	fs.Float64Var(&c.LatencyFactor, "outlier-latency-factor", 3, "eject a backend whose mean latency for a task type over an interval is this many times the median of the others serving it, 0 to disable")
+	fs.IntVar(&c.MinRequests, "outlier-min-requests", 10, "calls a backend needs in an interval to be judged on latency")
+	fs.DurationVar(&c.Interval, "outlier-interval", 10*time.Second, "how often latency outliers are looked for")
+	fs.DurationVar(&c.BaseEjectionTime, "outlier-ejection-time", 30*time.Second, "ejection time, multiplied by the number of times the backend was ejected")
+	fs.DurationVar(&c.MaxEjectionTime, "outlier-max-ejection-time", 5*time.Minute, "longest ejection")
+	fs.IntVar(&c.MaxEjectionPercent, "outlier-max-ejection-percent", 50, "most backends ejected at once, in percent (one may always be)")
+	fs.DurationVar(&c.SlowStart, "slow-start", 30*time.Second, "how long a backend back from ejection takes to get its full share of requests")
 }
 
 func main() {
-	args := os.Args[1:]
+	registryConfig.AddFlags(flag.CommandLine)
+	addOutlierFlags(flag.CommandLine, &outlierConfig)
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
 
+// run serves until SIGINT or SIGTERM. It returns errors instead of exiting so
+// its deferred cleanup, such as stopping the embedded etcd, always runs.
+func run(args []string) error {
 	if len(args) == 1 {
-		if args[0] != "RR" && args[0] != "PF" && args[0] != "LL"{
-			log.Fatalf("Invalid load balancing policy. Use 'RR' or 'PF' or 'LL'.")
-		}
 		loadBalancingPolicy = args[0] 
 	}
-
-	etcdClient, err := clientv3.New(clientv3.Config{
-		Endpoints:   []string{etcdServerAddr},
-		DialTimeout: 5 * time.Second,
-	})
+	policy, err := lbpolicy.New(loadBalancingPolicy)
 	if err != nil {
-		log.Fatalf("Failed to connect to etcd: %v", err)
+		return err
+	}
+	backendServersInfo = NewBackendServerInfo(loadBalancingPolicy, policy, *loadTTL, lbpolicy.NewOutlierDetector(outlierConfig), *spillover)
+
+	if *embeddedEtcd {
+		if registryConfig.Kind != "etcd" {
+			return fmt.Errorf("--embedded-etcd requires the etcd registry")
+		}
+		etcdServer, err := startEmbeddedEtcd(strings.Split(registryConfig.EtcdEndpoints, ",")[0])
+		if err != nil {
+			return fmt.Errorf("Failed to start embedded etcd: %w", err)
+		}
+		defer etcdServer.Close()
 	}
-	defer etcdClient.Close()
 
-	go discoverBackends(etcdClient)
+	reg, err := registry.Open(registryConfig)
+	if err != nil {
+		return fmt.Errorf("Failed to open service registry: %w", err)
+	}
+	defer reg.Close()
+
+	// Stop on SIGINT/SIGTERM so the deferred cleanup runs
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go discoverBackends(ctx, reg)
+	leadership = NewLeadership(!*haMode)
+	if *haMode {
+		etcdReg, ok := reg.(*registry.EtcdRegistry)
+		if !ok {
+			return fmt.Errorf("--ha requires the etcd registry")
+		}
+		go runElection(ctx, etcdReg, *lbAddr)
+		go mirrorLeader(ctx, etcdReg, *lbAddr)
+	}
+	if *healthInterval > 0 {
+		go checkBackendHealth(ctx, backendConns, *healthInterval, *healthTimeout)
+	}
 
-	listener, err := net.Listen("tcp", lbServerAddr)
+	listener, err := net.Listen("tcp", *lbAddr)
 	if err != nil {
-		log.Fatalf("Load Balancing Server Failed to listen: %v", err)
+		return fmt.Errorf("Load Balancing Server Failed to listen: %w", err)
 	}
 	defer listener.Close()
 
-	lbServer := grpc.NewServer()
+	if *metricsAddr != "" {
+		if addr, err := metrics.Serve(*metricsAddr, metricsRegistry); err != nil {
+			log.Printf("Load Balancer - Not serving metrics: %v", err)
+		} else {
+			log.Printf("Load Balancer - Serving metrics on http://%s/metrics", addr)
+		}
+	}
+
+	lbServer := grpc.NewServer(grpc.StatsHandler(metrics.NewConnCounter(activeConns)))
 
 	lbproto.RegisterLoadBalancingServiceServer(lbServer, &LoadBalancingServer{})
 	lbproto.RegisterReportLoadServiceServer(lbServer, &ReportLoadServer{})
+	lbproto.RegisterAdminServiceServer(lbServer, &AdminServer{})
+	if *proxyMode {
+		lbproto.RegisterBackendServiceServer(lbServer, &ProxyServer{conns: backendConns})
+		log.Println("Proxy mode enabled, forwarding BackendRPC to backends")
+	}
+	defer backendConns.Close()
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Load Balancing Server shutting down")
+		lbServer.Stop()
+	}()
 
-	log.Println("Load Balancing Server is running on", lbServerAddr)
+	log.Println("Load Balancing Server is running on", *lbAddr)
 	if err := lbServer.Serve(listener); err != nil {
-		log.Fatalf("Load Balancing Server Failed to serve: %v", err)
+		return fmt.Errorf("Load Balancing Server Failed to serve: %w", err)
 	}
+	return nil
 }
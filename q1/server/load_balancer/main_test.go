@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+)
+
+// waitForBackends waits until the load balancer knows exactly the backends
+// in want, in order, with their weights.
+func waitForBackends(t *testing.T, want ...registry.Instance) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := backendServersInfo.backendList().GetBackends()
+		matches := len(got) == len(want)
+		for i := 0; matches && i < len(want); i++ {
+			matches = got[i].GetAddr() == want[i].Addr && got[i].GetWeight() == want[i].EffectiveWeight()
+		}
+		if matches {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backends = %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiscoverBackends(t *testing.T) {
+	backendServersInfo = newTestBackendServerInfo()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg := registry.NewMemoryRegistry()
+	defer reg.Close()
+
+	register := func(addr string, weight int32) (registry.Instance, registry.LeaseID) {
+		inst := registry.Instance{Addr: addr, Weight: weight}
+		lease, err := reg.Register(ctx, inst, time.Minute)
+		if err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		return inst, lease
+	}
+
+	// backends registered before discovery starts come from the snapshot
+	a, leaseA := register("localhost:6001", 1)
+	b, _ := register("localhost:6002", 1)
+	discovered := make(chan struct{})
+	go func() {
+		discoverBackends(ctx, reg)
+		close(discovered)
+	}()
+	// stop before the next test replaces backendServersInfo
+	defer func() {
+		cancel()
+		<-discovered
+	}()
+	waitForBackends(t, a, b)
+
+	// later ones from the watch, in the order they happen
+	c, _ := register("localhost:6003", 2)
+	waitForBackends(t, a, b, c)
+	if err := reg.Deregister(ctx, leaseA); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	waitForBackends(t, b, c)
+	b, _ = register(b.Addr, 3)
+	waitForBackends(t, b, c)
+
+	pick := func() string {
+		addr, err := backendServersInfo.pick(lbpolicy.PickRequest{}, nil)
+		if err != nil {
+			t.Fatalf("pick failed: %v", err)
+		}
+		backendServersInfo.releasePick(addr)
+		return addr
+	}
+	picked := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		picked[pick()] = true
+	}
+	if picked[a.Addr] || !picked[b.Addr] || !picked[c.Addr] {
+		t.Errorf("picked %v, want only the registered %s and %s", picked, b.Addr, c.Addr)
+	}
+
+	// a resync drops the backends that left while the watch was down
+	removed := backendServersInfo.setServers([]registry.Instance{c})
+	if fmt.Sprint(removed) != fmt.Sprint([]string{b.Addr}) {
+		t.Errorf("setServers removed %v, want %s", removed, b.Addr)
+	}
+	waitForBackends(t, c)
+}
+
+func TestUpdateLoadOrder(t *testing.T) {
+	const addr = "localhost:6011"
+	base := time.Now().UnixNano()
+	tests := []struct {
+		name     string
+		reports  []*lbproto.LoadStatus
+		wantLoad float32
+	}{
+		{
+			name: "in order",
+			reports: []*lbproto.LoadStatus{
+				{Seq: 1, Timestamp: base, Load: 0.1},
+				{Seq: 2, Timestamp: base + 1, Load: 0.2},
+			},
+			wantLoad: 0.2,
+		},
+		{
+			name: "older seq and timestamp",
+			reports: []*lbproto.LoadStatus{
+				{Seq: 2, Timestamp: base + 1, Load: 0.2},
+				{Seq: 1, Timestamp: base, Load: 0.1},
+			},
+			wantLoad: 0.2,
+		},
+		{
+			name: "repeated report",
+			reports: []*lbproto.LoadStatus{
+				{Seq: 2, Timestamp: base, Load: 0.2},
+				{Seq: 2, Timestamp: base, Load: 0.9},
+			},
+			wantLoad: 0.2,
+		},
+		{
+			// a restarted backend counts from 1 again
+			name: "restarted backend",
+			reports: []*lbproto.LoadStatus{
+				{Seq: 40, Timestamp: base, Load: 0.2},
+				{Seq: 1, Timestamp: base + int64(time.Second), Load: 0.7},
+			},
+			wantLoad: 0.7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newTestBackendServerInfo(registry.Instance{Addr: addr})
+			for _, report := range tt.reports {
+				report.ServerAddr = addr
+				info.updateLoad(report)
+			}
+			if load := info.backends[addr].backend.Load; load != tt.wantLoad {
+				t.Errorf("load = %v, want %v", load, tt.wantLoad)
+			}
+		})
+	}
+}
+
+func TestAgeOutLoads(t *testing.T) {
+	const fresh, stale, silent = "localhost:6021", "localhost:6022", "localhost:6023"
+	info := newTestBackendServerInfo(registry.Instance{Addr: fresh}, registry.Instance{Addr: stale}, registry.Instance{Addr: silent})
+	now := time.Now()
+	info.applyLoad(&lbproto.LoadStatus{ServerAddr: fresh, Seq: 1, Load: 0.4}, now, false)
+	info.applyLoad(&lbproto.LoadStatus{ServerAddr: stale, Seq: 1, Load: 0.9, QueueDepth: 5}, now.Add(-2*info.loadTTL), false)
+
+	info.ageOutLoads(now)
+	if state := info.backends[stale]; !state.loadStale || state.backend.Load != 0.4 || state.backend.QueueDepth != 0 {
+		t.Errorf("stale backend: stale %v, load %v, queue %d; want its load replaced by the mean of fresh loads, 0.4, and no queue",
+			state.loadStale, state.backend.Load, state.backend.QueueDepth)
+	}
+	if info.backends[fresh].loadStale || info.backends[silent].loadStale {
+		t.Error("backend with a fresh report, or none yet, marked stale")
+	}
+
+	// a new report makes the load trusted again
+	info.applyLoad(&lbproto.LoadStatus{ServerAddr: stale, Seq: 2, Load: 0.6}, now, false)
+	info.ageOutLoads(now)
+	if state := info.backends[stale]; state.loadStale || state.backend.Load != 0.6 {
+		t.Errorf("backend reporting again: stale %v, load %v, want its reported load 0.6", state.loadStale, state.backend.Load)
+	}
+}
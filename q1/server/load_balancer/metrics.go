@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"q1/lbpolicy"
+	"q1/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served on --metrics-addr
+var (
+	metricsRegistry = metrics.NewRegistry()
+	metricsFactory  = promauto.With(metricsRegistry)
+
+	pickCount = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "q1_lb_picks_total",
+		Help: "Backends picked for requests, by backend and policy.",
+	}, []string{"backend", "policy"})
+	pickErrorCount = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "q1_lb_pick_errors_total",
+		Help: "Requests no backend could be picked for, by policy.",
+	}, []string{"policy"})
+	spilloverCount = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Name: "q1_lb_spillovers_total",
+		Help: "Picks that looked past the caller's zone or region because it was empty or overloaded.",
+	})
+	ejectionCount = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "q1_lb_outlier_ejections_total",
+		Help: "Ejections by outlier detection, by backend.",
+	}, []string{"backend"})
+
+	backendLoad = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "q1_lb_backend_load",
+		Help: "Load last reported by each backend, in the unit of its load metric.",
+	}, []string{"backend", "metric"})
+	backendInFlight = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "q1_lb_backend_in_flight",
+		Help: "Requests in flight last reported by each backend.",
+	}, []string{"backend"})
+	backendQueueDepth = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "q1_lb_backend_queue_depth",
+		Help: "Requests waiting for a worker last reported by each backend.",
+	}, []string{"backend"})
+
+	discoveryRefreshCount = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "q1_lb_discovery_refreshes_total",
+		Help: "Snapshots of the registered backends, by result (ok or error).",
+	}, []string{"result"})
+	discoveryEventCount = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "q1_lb_discovery_events_total",
+		Help: "Backends that joined or left the registry while watching it, by type (join or leave).",
+	}, []string{"type"})
+	_ = metricsFactory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "q1_lb_backends",
+		Help: "Registered backends.",
+	}, func() float64 { return float64(len(backendServersInfo.servers())) })
+
+	activeConns = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Name: "q1_lb_active_connections",
+		Help: "Open client connections to the load balancer's gRPC server.",
+	})
+)
+
+// loadMetricLabel is the metric label of a backend's load, e.g. cpu.
+func loadMetricLabel(state *backendState) string {
+	return strings.ToLower(state.loadMetric.String())
+}
+
+// setLoadMetrics exports the load a backend reported. The caller must hold
+// mutexLock.
+func setLoadMetrics(state *backendState, previousMetric string) {
+	addr := state.backend.Addr
+	if metric := loadMetricLabel(state); metric != previousMetric {
+		backendLoad.DeleteLabelValues(addr, previousMetric)
+	}
+	backendLoad.WithLabelValues(addr, loadMetricLabel(state)).Set(float64(state.backend.Load))
+	backendInFlight.WithLabelValues(addr).Set(float64(state.backend.InFlight))
+	backendQueueDepth.WithLabelValues(addr).Set(float64(state.backend.QueueDepth))
+}
+
+// deleteLoadMetrics stops exporting the series of a backend that left, so
+// backends coming and going do not pile them up. The caller must hold
+// mutexLock.
+func deleteLoadMetrics(state *backendState) {
+	addr := state.backend.Addr
+	backendLoad.DeleteLabelValues(addr, loadMetricLabel(state))
+	backendInFlight.DeleteLabelValues(addr)
+	backendQueueDepth.DeleteLabelValues(addr)
+	// client_golang 1.11 has no DeletePartialMatch; the policy label is
+	// always one of lbpolicy.Names
+	for _, policy := range lbpolicy.Names {
+		pickCount.DeleteLabelValues(addr, policy)
+	}
+	ejectionCount.DeleteLabelValues(addr)
+}
+
+// outlierEvent logs and counts an ejection or a return. The caller must hold
+// mutexLock.
+func (info *BackendServerInfo) outlierEvent(ev lbpolicy.OutlierEvent) {
+	if ev.Ejected {
+		info.ejections++
+		ejectionCount.WithLabelValues(ev.Addr).Inc()
+	}
+	log.Printf("Load Balancer - %v", ev)
+}
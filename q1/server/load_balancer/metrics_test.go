@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+)
+
+// backendSeries returns the names of the metrics exported with a series for
+// the backend at addr.
+func backendSeries(t *testing.T, addr string) []string {
+	t.Helper()
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	var names []string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "backend" && label.GetValue() == addr {
+					names = append(names, family.GetName())
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestBackendMetricsDeletedWhenBackendLeaves(t *testing.T) {
+	const addr = "localhost:6101"
+	backendServersInfo = newTestBackendServerInfo(registry.Instance{Addr: addr})
+
+	backendServersInfo.updateLoad(&lbproto.LoadStatus{ServerAddr: addr, Seq: 1})
+	if _, err := backendServersInfo.pick(lbpolicy.PickRequest{}, nil); err != nil {
+		t.Fatalf("pick failed: %v", err)
+	}
+	backendServersInfo.mutexLock.Lock()
+	backendServersInfo.outlierEvent(lbpolicy.OutlierEvent{Addr: addr, Ejected: true})
+	backendServersInfo.mutexLock.Unlock()
+	if names := backendSeries(t, addr); len(names) < 5 {
+		t.Fatalf("backend exported %v, want its load, pick and ejection series", names)
+	}
+
+	backendServersInfo.setServers(nil)
+	if names := backendSeries(t, addr); len(names) != 0 {
+		t.Errorf("backend that left still exports %v", names)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+	"q1/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ProxyServer implements BackendService on the load balancer (--proxy). It
+// picks a backend for each request, forwards the request over a pooled
+// connection and returns the backend's response, so clients only need the
+// load balancer's address.
+type ProxyServer struct {
+	lbproto.UnimplementedBackendServiceServer
+	conns *BackendConnPool
+}
+
+// proxyPickRequest is the pick for a proxied request. The load balancer
+// makes the call to the backend, so its own zone and region are preferred.
+func proxyPickRequest(req *lbproto.BackendRequest) lbpolicy.PickRequest {
+	tasktype := int32(req.GetTaskType())
+	return lbpolicy.PickRequest{
+		TaskType: tasktype,
+		HashKey:  lbpolicy.RequestHashKey(tasktype, req.GetNum()),
+		Zone:     *lbZone,
+		Region:   *lbRegion,
+	}
+}
+
+func (s *ProxyServer) BackendRPC(ctx context.Context, req *lbproto.BackendRequest) (*lbproto.BackendResponse, error) {
+	if err := leadership.checkLeader(); err != nil {
+		return nil, err
+	}
+	backendAddr, err := backendServersInfo.pick(proxyPickRequest(req), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, release, err := s.conns.Get(backendAddr)
+	if err != nil {
+		backendServersInfo.requestDone(backendAddr, int32(req.GetTaskType()), 0, err)
+		return nil, status.Errorf(codes.Unavailable, "failed to connect to backend %s: %v", backendAddr, err)
+	}
+	defer release()
+
+	start := time.Now()
+	var header metadata.MD
+	resp, err := lbproto.NewBackendServiceClient(conn).BackendRPC(ctx, req, grpc.Header(&header))
+	backendServersInfo.requestDone(backendAddr, int32(req.GetTaskType()), time.Since(start), err)
+	if servedBy := header.Get(registry.ServerAddrHeader); len(servedBy) > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs(registry.ServerAddrHeader, servedBy[0]))
+	}
+	if err != nil {
+		log.Printf("Load Balancer - Proxied request to %s failed: %v", backendAddr, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SubmitTask picks a backend like BackendRPC and submits the job there. The
+// job runs after the call returns, so only the pick counts as in flight.
+func (s *ProxyServer) SubmitTask(ctx context.Context, req *lbproto.BackendRequest) (*lbproto.TaskStatus, error) {
+	if err := leadership.checkLeader(); err != nil {
+		return nil, err
+	}
+	backendAddr, err := backendServersInfo.pick(proxyPickRequest(req), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer backendServersInfo.releasePick(backendAddr)
+
+	conn, release, err := s.conns.Get(backendAddr)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to connect to backend %s: %v", backendAddr, err)
+	}
+	defer release()
+	return lbproto.NewBackendServiceClient(conn).SubmitTask(ctx, req)
+}
+
+func (s *ProxyServer) GetTaskStatus(ctx context.Context, req *lbproto.TaskID) (*lbproto.TaskStatus, error) {
+	client, release, err := s.jobBackend(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return client.GetTaskStatus(ctx, req)
+}
+
+func (s *ProxyServer) WatchTask(req *lbproto.TaskID, stream grpc.ServerStreamingServer[lbproto.TaskStatus]) error {
+	client, release, err := s.jobBackend(req.GetJobId())
+	if err != nil {
+		return err
+	}
+	defer release()
+	backendStream, err := client.WatchTask(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	for {
+		taskStatus, err := backendStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(taskStatus); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *ProxyServer) CancelTask(ctx context.Context, req *lbproto.TaskID) (*lbproto.TaskStatus, error) {
+	client, release, err := s.jobBackend(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return client.CancelTask(ctx, req)
+}
+
+// jobBackend returns a client for the backend that holds a job, which is
+// named in the job ID, and the function to call when done with it. Only
+// registered backends are dialled.
+func (s *ProxyServer) jobBackend(jobID string) (lbproto.BackendServiceClient, func(), error) {
+	slash := strings.LastIndexByte(jobID, '/')
+	if slash < 0 {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "malformed job ID %q", jobID)
+	}
+	backendAddr := jobID[:slash]
+	if !backendServersInfo.registered(backendAddr) {
+		return nil, nil, status.Errorf(codes.NotFound, "backend %s of job %s is not registered", backendAddr, jobID)
+	}
+	conn, release, err := s.conns.Get(backendAddr)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Unavailable, "failed to connect to backend %s: %v", backendAddr, err)
+	}
+	return lbproto.NewBackendServiceClient(conn), release, nil
+}
+
+// BackendConnPool keeps one client connection per backend. gRPC multiplexes
+// concurrent requests over a connection, so one is enough.
+type BackendConnPool struct {
+	mutexLock sync.Mutex
+	conns     map[string]*pooledConn
+}
+
+type pooledConn struct {
+	conn    *grpc.ClientConn
+	users   int  // calls using conn, guarded by BackendConnPool.mutexLock
+	removed bool // close once the last user is done
+}
+
+func NewBackendConnPool() *BackendConnPool {
+	return &BackendConnPool{conns: make(map[string]*pooledConn)}
+}
+
+// Get returns the connection to addr and a function to call once the call
+// using it has finished.
+func (p *BackendConnPool) Get(addr string) (*grpc.ClientConn, func(), error) {
+	p.mutexLock.Lock()
+	defer p.mutexLock.Unlock()
+
+	pooled, exists := p.conns[addr]
+	if !exists {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, nil, err
+		}
+		pooled = &pooledConn{conn: conn}
+		p.conns[addr] = pooled
+	}
+	pooled.users++
+	return pooled.conn, func() { p.release(pooled) }, nil
+}
+
+func (p *BackendConnPool) release(pooled *pooledConn) {
+	p.mutexLock.Lock()
+	defer p.mutexLock.Unlock()
+
+	pooled.users--
+	if pooled.removed && pooled.users == 0 {
+		pooled.conn.Close()
+	}
+}
+
+// Remove closes the connection to a backend that has left. Calls still
+// running on it, such as those of a draining backend, finish first.
+func (p *BackendConnPool) Remove(addr string) {
+	p.mutexLock.Lock()
+	defer p.mutexLock.Unlock()
+
+	pooled, exists := p.conns[addr]
+	if !exists {
+		return
+	}
+	delete(p.conns, addr)
+	pooled.removed = true
+	if pooled.users == 0 {
+		pooled.conn.Close()
+	}
+}
+
+func (p *BackendConnPool) Close() {
+	p.mutexLock.Lock()
+	defer p.mutexLock.Unlock()
+
+	for addr, pooled := range p.conns {
+		pooled.conn.Close()
+		delete(p.conns, addr)
+	}
+}
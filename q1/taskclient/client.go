@@ -0,0 +1,284 @@
+// Package taskclient sends tasks to q1 backends the way a lookaside client
+// does: it asks the load balancer for a backend, then calls it. A call that
+// fails with a transient error is retried with exponential backoff, asking
+// the load balancer for a backend other than the ones that failed; a slow
+// call may be hedged with a second one to another backend. The outcome of
+// every call is reported to the load balancer for its outlier detection, and
+// every pick is reported once so the load balancer can release it.
+package taskclient
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"q1/lbpolicy"
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	outcomeReportTimeout = time.Second
+	// backoffJitter spreads each retry wait over ±20%, so clients that
+	// failed together do not retry together
+	backoffJitter = 0.2
+)
+
+// Config controls retries and hedging.
+type Config struct {
+	// MaxAttempts is the most times a task is tried, counting the first.
+	MaxAttempts int
+	// The n-th retry waits InitialBackoff * 2^(n-1), up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// HedgeDelay sends the task to a second backend when the first has not
+	// answered after it, using whichever answers first. 0 disables hedging.
+	HedgeDelay time.Duration
+	// Zone and Region are where the caller runs; the load balancer prefers
+	// backends there.
+	Zone   string
+	Region string
+	// Logf, if set, is told about retries and hedges.
+	Logf func(format string, args ...any)
+}
+
+// DefaultConfig tries a task three times, without hedging.
+func DefaultConfig() Config {
+	return Config{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+}
+
+// Client sends tasks. It is safe for concurrent use.
+type Client struct {
+	lbClient lbproto.LoadBalancingServiceClient
+	config   Config
+	// backend returns a client for the backend at addr; tests replace it
+	backend func(addr string) (lbproto.BackendServiceClient, error)
+
+	mutexLock sync.Mutex
+	conns     map[string]*grpc.ClientConn
+	pending   sync.WaitGroup // calls, such as ones that lost a hedge, and outcome reports
+}
+
+func New(lbClient lbproto.LoadBalancingServiceClient, config Config) *Client {
+	c := &Client{lbClient: lbClient, config: config, conns: make(map[string]*grpc.ClientConn)}
+	c.backend = c.connect
+	return c
+}
+
+// Result is a task's response and how it was obtained.
+type Result struct {
+	Response *lbproto.BackendResponse
+	Addr     string // the backend that answered
+	Calls    int    // calls sent, counting retries and hedges
+}
+
+// Retryable reports whether a call that failed with err may succeed on
+// another backend or later: the backend was unreachable, overloaded or
+// draining, or it gave up on the call while the caller was still waiting.
+func Retryable(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	case codes.DeadlineExceeded:
+		return ctx.Err() == nil
+	}
+	return false
+}
+
+// Send sends req and returns the first successful response. When every
+// attempt fails it returns the last error, keeping its gRPC status, and a
+// Result without Response naming the backend of the last failed call, if
+// any call was sent.
+func (c *Client) Send(ctx context.Context, req *lbproto.BackendRequest) (*Result, error) {
+	var exclude []string
+	calls := 0
+	backoff := c.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		answer, sent, failed := c.try(ctx, req, exclude)
+		calls += sent
+		if answer.err == nil {
+			return &Result{Response: answer.resp, Addr: answer.addr, Calls: calls}, nil
+		}
+		if attempt >= c.config.MaxAttempts || !Retryable(ctx, answer.err) {
+			return &Result{Addr: answer.addr, Calls: calls}, answer.err
+		}
+
+		exclude = append(exclude, failed...)
+		wait := time.Duration(float64(backoff) * (1 - backoffJitter + 2*backoffJitter*rand.Float64()))
+		c.logf("attempt %d of %v failed, retrying in %v: %v", attempt, req.GetTaskType(), wait.Round(time.Millisecond), answer.err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return &Result{Addr: answer.addr, Calls: calls}, answer.err
+		}
+		backoff = min(backoff*2, c.config.MaxBackoff)
+	}
+}
+
+// answer is the outcome of one call to a backend.
+type answer struct {
+	addr string
+	resp *lbproto.BackendResponse
+	err  error
+}
+
+// try makes one attempt at req: a call to a backend the load balancer picks
+// outside exclude, hedged after HedgeDelay by a call to another one. It
+// returns the first successful answer, or else the last failure, with the
+// number of calls sent and the backends whose call failed.
+func (c *Client) try(ctx context.Context, req *lbproto.BackendRequest, exclude []string) (answer, int, []string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the call that lost the race
+
+	first, firstToken, err := c.pick(ctx, req, exclude)
+	if err != nil {
+		return answer{err: err}, 0, nil
+	}
+	answers := make(chan answer, 2)
+	c.goCall(ctx, first, firstToken, req, answers)
+	sent, running := 1, 1
+
+	var hedge <-chan time.Time
+	if c.config.HedgeDelay > 0 {
+		timer := time.NewTimer(c.config.HedgeDelay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+	var failed []string
+	for {
+		select {
+		case <-hedge:
+			hedge = nil
+			// the load balancer returns first again when no other backend can
+			// take the task; hedging to it would only add load
+			second, secondToken, err := c.pick(ctx, req, append(slices.Clone(exclude), first))
+			if err != nil {
+				continue
+			}
+			if second == first {
+				c.releasePick(second, secondToken, req.GetTaskType())
+				continue
+			}
+			c.logf("%s has not answered after %v, hedging %v on %s", first, c.config.HedgeDelay, req.GetTaskType(), second)
+			c.goCall(ctx, second, secondToken, req, answers)
+			sent++
+			running++
+		case a := <-answers:
+			running--
+			if a.err == nil {
+				return a, sent, failed
+			}
+			failed = append(failed, a.addr)
+			if running == 0 {
+				return a, sent, failed
+			}
+		}
+	}
+}
+
+// pick asks the load balancer for a backend, returning its address and the
+// token to report the call's outcome with.
+func (c *Client) pick(ctx context.Context, req *lbproto.BackendRequest, exclude []string) (string, uint64, error) {
+	lbReq := &lbproto.LoadBalancerRequest{
+		TaskType: req.GetTaskType(),
+		HashKey:  lbpolicy.RequestHashKey(int32(req.GetTaskType()), req.GetNum()),
+		Exclude:  exclude,
+		Zone:     c.config.Zone,
+		Region:   c.config.Region,
+	}
+	resp, err := c.lbClient.LoadBalancerRPC(ctx, lbReq)
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.GetBestServer(), resp.GetPickToken(), nil
+}
+
+// goCall calls the backend at addr in a goroutine, sending the answer to
+// answers.
+func (c *Client) goCall(ctx context.Context, addr string, token uint64, req *lbproto.BackendRequest, answers chan<- answer) {
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		answers <- c.call(ctx, addr, token, req)
+	}()
+}
+
+func (c *Client) call(ctx context.Context, addr string, token uint64, req *lbproto.BackendRequest) answer {
+	backend, err := c.backend(addr)
+	if err != nil {
+		c.releasePick(addr, token, req.GetTaskType())
+		return answer{addr: addr, err: status.Error(codes.Unavailable, err.Error())}
+	}
+	start := time.Now()
+	resp, err := backend.BackendRPC(ctx, req)
+	// a call cancelled because the other one of a hedge won is reported as
+	// Canceled, which the load balancer does not hold against the backend
+	c.reportOutcome(addr, token, req.GetTaskType(), err, time.Since(start))
+	return answer{addr: addr, resp: resp, err: err}
+}
+
+// releasePick tells the load balancer a pick of addr was not called, so it
+// stops counting it in flight.
+func (c *Client) releasePick(addr string, token uint64, taskType lbproto.TaskType) {
+	c.reportOutcome(addr, token, taskType, status.Error(codes.Canceled, "pick not used"), 0)
+}
+
+// reportOutcome tells the load balancer how a call went, without holding up
+// the caller. Close waits for the reports still being sent.
+func (c *Client) reportOutcome(addr string, token uint64, taskType lbproto.TaskType, err error, latency time.Duration) {
+	outcome := &lbproto.CallOutcome{
+		ServerAddr: addr,
+		TaskType:   taskType,
+		Code:       int32(status.Code(err)),
+		Latency:    float32(latency.Microseconds()) / 1000,
+		PickToken:  token,
+	}
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), outcomeReportTimeout)
+		defer cancel()
+		c.lbClient.ReportOutcome(ctx, &lbproto.OutcomeReport{Outcomes: []*lbproto.CallOutcome{outcome}})
+	}()
+}
+
+// connect returns a client for the backend at addr, reusing its connection.
+func (c *Client) connect(addr string) (lbproto.BackendServiceClient, error) {
+	c.mutexLock.Lock()
+	defer c.mutexLock.Unlock()
+	conn, exists := c.conns[addr]
+	if !exists {
+		var err error
+		conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, err
+		}
+		c.conns[addr] = conn
+	}
+	return lbproto.NewBackendServiceClient(conn), nil
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.config.Logf != nil {
+		c.config.Logf(format, args...)
+	}
+}
+
+// Close waits for the calls and outcome reports still running and closes
+// the connections to the backends.
+func (c *Client) Close() error {
+	c.pending.Wait()
+	c.mutexLock.Lock()
+	defer c.mutexLock.Unlock()
+	for addr, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, addr)
+	}
+	return nil
+}
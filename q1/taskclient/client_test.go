@@ -0,0 +1,224 @@
+package taskclient
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	lbproto "q1/protofiles"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeLB picks the first backend not excluded, like the load balancer
+// falling back to an excluded one when none is left, and records picks and
+// outcomes. The token of a pick is its position in picks, from 1.
+type fakeLB struct {
+	lbproto.LoadBalancingServiceClient
+	backends []string
+
+	mutexLock sync.Mutex
+	excludes  [][]string
+	picks     []string
+	outcomes  []*lbproto.CallOutcome
+}
+
+func (lb *fakeLB) LoadBalancerRPC(ctx context.Context, req *lbproto.LoadBalancerRequest, opts ...grpc.CallOption) (*lbproto.LoadBalancerResponse, error) {
+	lb.mutexLock.Lock()
+	defer lb.mutexLock.Unlock()
+	lb.excludes = append(lb.excludes, req.GetExclude())
+	picked := lb.backends[0]
+	for _, addr := range lb.backends {
+		if !slices.Contains(req.GetExclude(), addr) {
+			picked = addr
+			break
+		}
+	}
+	lb.picks = append(lb.picks, picked)
+	return &lbproto.LoadBalancerResponse{BestServer: picked, PickToken: uint64(len(lb.picks))}, nil
+}
+
+// checkOutcomePerPick fails t unless every pick got one outcome reported
+// with its token and backend, so the load balancer releases all of them.
+func (lb *fakeLB) checkOutcomePerPick(t *testing.T) {
+	t.Helper()
+	lb.mutexLock.Lock()
+	defer lb.mutexLock.Unlock()
+	picks, outcomes := make(map[uint64]string), make(map[uint64]string)
+	for i, addr := range lb.picks {
+		picks[uint64(i+1)] = addr
+	}
+	for _, outcome := range lb.outcomes {
+		if _, reported := outcomes[outcome.GetPickToken()]; reported {
+			t.Errorf("outcome of pick %d reported twice", outcome.GetPickToken())
+		}
+		outcomes[outcome.GetPickToken()] = outcome.GetServerAddr()
+	}
+	if !maps.Equal(picks, outcomes) {
+		t.Errorf("picks %v but outcomes %v, want one outcome per pick", picks, outcomes)
+	}
+}
+
+// outcomeCode returns the code reported for addr, if any was.
+func (lb *fakeLB) outcomeCode(addr string) (codes.Code, bool) {
+	lb.mutexLock.Lock()
+	defer lb.mutexLock.Unlock()
+	for _, outcome := range lb.outcomes {
+		if outcome.GetServerAddr() == addr {
+			return codes.Code(outcome.GetCode()), true
+		}
+	}
+	return 0, false
+}
+
+func (lb *fakeLB) ReportOutcome(ctx context.Context, req *lbproto.OutcomeReport, opts ...grpc.CallOption) (*lbproto.Empty, error) {
+	lb.mutexLock.Lock()
+	defer lb.mutexLock.Unlock()
+	lb.outcomes = append(lb.outcomes, req.GetOutcomes()...)
+	return &lbproto.Empty{}, nil
+}
+
+// fakeBackend answers after delay, with err if set.
+type fakeBackend struct {
+	lbproto.BackendServiceClient
+	addr  string
+	delay time.Duration
+	err   error
+}
+
+func (b *fakeBackend) BackendRPC(ctx context.Context, req *lbproto.BackendRequest, opts ...grpc.CallOption) (*lbproto.BackendResponse, error) {
+	select {
+	case <-time.After(b.delay):
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &lbproto.BackendResponse{Output: 1}, nil
+}
+
+func newTestClient(config Config, backends ...*fakeBackend) (*Client, *fakeLB) {
+	lb := &fakeLB{}
+	byAddr := make(map[string]*fakeBackend)
+	for _, b := range backends {
+		lb.backends = append(lb.backends, b.addr)
+		byAddr[b.addr] = b
+	}
+	c := New(lb, config)
+	c.backend = func(addr string) (lbproto.BackendServiceClient, error) { return byAddr[addr], nil }
+	return c, lb
+}
+
+func testConfig() Config {
+	return Config{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+}
+
+func TestRetryExcludesFailedBackends(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+	c, lb := newTestClient(testConfig(),
+		&fakeBackend{addr: "a", err: unavailable},
+		&fakeBackend{addr: "b", err: status.Error(codes.ResourceExhausted, "busy")},
+		&fakeBackend{addr: "c"})
+
+	result, err := c.Send(context.Background(), &lbproto.BackendRequest{})
+	c.Close()
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if result.Addr != "c" || result.Calls != 3 {
+		t.Errorf("answered by %s after %d calls, want c after 3", result.Addr, result.Calls)
+	}
+	want := [][]string{nil, {"a"}, {"a", "b"}}
+	for i := range want {
+		if !slices.Equal(lb.excludes[i], want[i]) {
+			t.Errorf("pick %d excluded %v, want %v", i, lb.excludes[i], want[i])
+		}
+	}
+	if len(lb.outcomes) != 3 {
+		t.Errorf("%d outcomes reported, want 3", len(lb.outcomes))
+	}
+	lb.checkOutcomePerPick(t)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	invalid := status.Error(codes.InvalidArgument, "bad task")
+	c, _ := newTestClient(testConfig(), &fakeBackend{addr: "a", err: invalid}, &fakeBackend{addr: "b"})
+	if _, err := c.Send(context.Background(), &lbproto.BackendRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("non-retryable error gave %v, want it returned at once", err)
+	}
+
+	unavailable := status.Error(codes.Unavailable, "down")
+	c, lb := newTestClient(testConfig(), &fakeBackend{addr: "a", err: unavailable})
+	if _, err := c.Send(context.Background(), &lbproto.BackendRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("failing backend gave %v, want Unavailable", err)
+	}
+	if len(lb.excludes) != 3 {
+		t.Errorf("%d attempts, want MaxAttempts 3", len(lb.excludes))
+	}
+}
+
+func TestHedging(t *testing.T) {
+	config := testConfig()
+	config.HedgeDelay = 20 * time.Millisecond
+	c, lb := newTestClient(config, &fakeBackend{addr: "slow", delay: time.Second}, &fakeBackend{addr: "fast"})
+
+	start := time.Now()
+	result, err := c.Send(context.Background(), &lbproto.BackendRequest{})
+	c.Close()
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if result.Addr != "fast" || result.Calls != 2 {
+		t.Errorf("answered by %s after %d calls, want the hedge to fast", result.Addr, result.Calls)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("hedged call took %v, the slow backend was waited for", elapsed)
+	}
+	// the cancelled call to slow is reported as such, releasing its pick
+	// without holding it against slow
+	if code, reported := lb.outcomeCode("slow"); !reported || code != codes.Canceled {
+		t.Errorf("slow's call reported as %v (reported %v), want Canceled", code, reported)
+	}
+	lb.checkOutcomePerPick(t)
+
+	// with a single backend there is nothing to hedge to, but the hedge's
+	// pick of it is still released
+	c, lb = newTestClient(config, &fakeBackend{addr: "only", delay: 50 * time.Millisecond})
+	result, err = c.Send(context.Background(), &lbproto.BackendRequest{})
+	c.Close()
+	if err != nil || result.Calls != 1 {
+		t.Errorf("single backend: %v calls, error %v, want 1 call", result, err)
+	}
+	if len(lb.picks) != 2 {
+		t.Errorf("%d picks, want the call's and the hedge's", len(lb.picks))
+	}
+	lb.checkOutcomePerPick(t)
+}
+
+func TestUnreachableBackendPickReleased(t *testing.T) {
+	c, lb := newTestClient(testConfig(), &fakeBackend{addr: "a"}, &fakeBackend{addr: "b"})
+	backend := c.backend
+	c.backend = func(addr string) (lbproto.BackendServiceClient, error) {
+		if addr == "a" {
+			return nil, errors.New("bad address")
+		}
+		return backend(addr)
+	}
+
+	result, err := c.Send(context.Background(), &lbproto.BackendRequest{})
+	c.Close()
+	if err != nil || result.Addr != "b" {
+		t.Fatalf("answered by %v with error %v, want b after a could not be reached", result, err)
+	}
+	if code, reported := lb.outcomeCode("a"); !reported || code != codes.Canceled {
+		t.Errorf("a's pick reported as %v (reported %v), want Canceled", code, reported)
+	}
+	lb.checkOutcomePerPick(t)
+}
@@ -7,10 +7,17 @@ NUM_SERVERS=5
 NUM_CLIENTS=10
 REQUEST_TYPES=(0 1 2)  # Different request types
 POLICY="PF"
+# Set EMBEDDED_ETCD=1 to run etcd inside the load balancer instead of Docker
+EMBEDDED_ETCD=${EMBEDDED_ETCD:-0}
+
+LB_FLAGS=""
+if [ "$EMBEDDED_ETCD" = "1" ]; then
+    LB_FLAGS="--embedded-etcd"
+fi
 
 # Start the Load Balancer
 echo "Starting Load Balancer..."
-make server POLICY=$POLICY &
+make server POLICY=$POLICY LB_FLAGS="$LB_FLAGS" &
 LB_PID=$! 
 sleep 2  # Give some time for the load balancer to initialize
 